@@ -0,0 +1,338 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+)
+
+var logger = plog.NewBizLogger("breaker")
+
+var (
+	// ErrOpen is returned when the circuit is open.
+	ErrOpen = errors.New("breaker: circuit is open")
+	// ErrTooManyRequests is returned when the circuit is half-open and the
+	// trial requests are already running.
+	ErrTooManyRequests = errors.New("breaker: too many requests in half-open state")
+)
+
+// State of a circuit breaker.
+type State int
+
+const (
+	// StateClosed let every request through.
+	StateClosed State = iota
+	// StateHalfOpen let a few trial requests through after OpenTimeout.
+	StateHalfOpen
+	// StateOpen reject every request.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Settings struct contain options of Breaker.
+type Settings struct {
+	// Name is used in logs and by Group.
+	Name string
+	// ConsecutiveFailures trip the breaker after this many failures in a row, default to 5.
+	ConsecutiveFailures uint32
+	// FailureRatio trip the breaker when the ratio of failures over Window
+	// reach it, e.g. 0.5. 0 means disabled.
+	FailureRatio float64
+	// MinRequests is the number of requests in Window before FailureRatio is checked, default to 20.
+	MinRequests uint32
+	// Window is the rolling window of FailureRatio, default to 1 minute.
+	Window time.Duration
+	// Buckets is the number of buckets of Window, default to 10.
+	Buckets int
+	// OpenTimeout is how long the breaker stay open before half-open, default to 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of trial requests in half-open state,
+	// the breaker close when all of them succeed. Default to 1.
+	HalfOpenRequests uint32
+	// IsFailure report whether an error count as a failure, default to
+	// every error except context.Canceled. It's not called on success.
+	IsFailure func(err error) bool
+	// OnStateChange is called after the state changed, transitions are also logged.
+	OnStateChange func(name string, from, to State)
+}
+
+// Breaker is a circuit breaker, it stop calling a failing dependency for a
+// while so its outage does not cascade to the callers.
+type Breaker struct {
+	settings Settings
+
+	mu          sync.Mutex
+	state       State
+	generation  uint64
+	consecutive uint32
+	window      *window
+	openUntil   time.Time
+	// trials running and succeeded in half-open state
+	trials    uint32
+	successes uint32
+}
+
+type transition struct {
+	from, to State
+}
+
+// New return new breaker in closed state.
+// EX used: b := breaker.New(breaker.Settings{Name: "payment", FailureRatio: 0.5})
+func New(settings Settings) *Breaker {
+	if settings.ConsecutiveFailures == 0 {
+		settings.ConsecutiveFailures = 5
+	}
+	if settings.MinRequests == 0 {
+		settings.MinRequests = 20
+	}
+	if settings.Window <= 0 {
+		settings.Window = time.Minute
+	}
+	if settings.Buckets <= 0 {
+		settings.Buckets = 10
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenRequests == 0 {
+		settings.HalfOpenRequests = 1
+	}
+	if settings.IsFailure == nil {
+		settings.IsFailure = isFailure
+	}
+
+	return &Breaker{
+		settings: settings,
+		window:   newWindow(settings.Window, settings.Buckets),
+	}
+}
+
+func isFailure(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+// Name return the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.settings.Name
+}
+
+// State return the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state, changed := b.currentState(time.Now())
+	b.mu.Unlock()
+
+	b.notify(changed)
+	return state
+}
+
+// Allow report whether a request can be made, done must be called with
+// the result of the request when it's allowed.
+// EX used:
+//
+//	done, err := b.Allow()
+//	if err != nil {
+//		return err
+//	}
+//	err = call()
+//	done(err)
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	state, changed := b.currentState(time.Now())
+	switch {
+	case state == StateOpen:
+		err = ErrOpen
+	case state == StateHalfOpen && b.trials >= b.settings.HalfOpenRequests:
+		err = ErrTooManyRequests
+	case state == StateHalfOpen:
+		b.trials++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+
+	b.notify(changed)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(generation, err) })
+	}, nil
+}
+
+// Execute call fn if the breaker allow it, it return ErrOpen or
+// ErrTooManyRequests without calling fn otherwise.
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// a panic count as a failure
+		if r := recover(); r != nil {
+			done(errors.New("breaker: panic"))
+			panic(r)
+		}
+	}()
+
+	err = fn()
+	done(err)
+	return err
+}
+
+// ExecuteWithFallback call fn through the breaker, fallback is called with
+// the error when the breaker reject the call or fn fail.
+func (b *Breaker) ExecuteWithFallback(fn func() error, fallback func(err error) error) error {
+	err := b.Execute(fn)
+	if err != nil && fallback != nil {
+		return fallback(err)
+	}
+	return err
+}
+
+// Do call fn through the breaker and return its result, fallback is called
+// with the error when the breaker reject the call or fn fail. fallback may be nil.
+// EX used: user, err := breaker.Do(b, func() (*User, error) { return fetch(id) }, func(err error) (*User, error) { return cached(id) })
+func Do[T any](b *Breaker, fn func() (T, error), fallback func(err error) (T, error)) (T, error) {
+	var res T
+	err := b.Execute(func() error {
+		var err error
+		res, err = fn()
+		return err
+	})
+	if err != nil && fallback != nil {
+		return fallback(err)
+	}
+	return res, err
+}
+
+// record count the result of a request allowed in the generation, results
+// of requests started before the last state change are ignored.
+func (b *Breaker) record(generation uint64, err error) {
+	now := time.Now()
+	failure := err != nil && b.settings.IsFailure(err)
+
+	b.mu.Lock()
+	state, changed := b.currentState(now)
+	if generation == b.generation {
+		switch state {
+		case StateClosed:
+			b.window.add(now, failure)
+			if !failure {
+				b.consecutive = 0
+			} else if b.consecutive++; b.tripped(now) {
+				changed = b.setState(StateOpen, now)
+			}
+		case StateHalfOpen:
+			if failure {
+				changed = b.setState(StateOpen, now)
+			} else if b.successes++; b.successes >= b.settings.HalfOpenRequests {
+				changed = b.setState(StateClosed, now)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(changed)
+}
+
+// tripped report whether a failure threshold is reached, the lock must be held.
+func (b *Breaker) tripped(now time.Time) bool {
+	if b.consecutive >= b.settings.ConsecutiveFailures {
+		return true
+	}
+	if b.settings.FailureRatio <= 0 {
+		return false
+	}
+	requests, failures := b.window.totals(now)
+	return requests >= b.settings.MinRequests && float64(failures)/float64(requests) >= b.settings.FailureRatio
+}
+
+// currentState move an open breaker to half-open after OpenTimeout, the lock must be held.
+func (b *Breaker) currentState(now time.Time) (State, *transition) {
+	if b.state == StateOpen && !now.Before(b.openUntil) {
+		return StateHalfOpen, b.setState(StateHalfOpen, now)
+	}
+	return b.state, nil
+}
+
+// setState reset the counters of the new state, the lock must be held.
+func (b *Breaker) setState(state State, now time.Time) *transition {
+	if b.state == state {
+		return nil
+	}
+
+	changed := &transition{from: b.state, to: state}
+	b.state = state
+	b.generation++
+	b.consecutive = 0
+	b.trials = 0
+	b.successes = 0
+	b.window.reset()
+	if state == StateOpen {
+		b.openUntil = now.Add(b.settings.OpenTimeout)
+	}
+	return changed
+}
+
+// notify log the transition and call OnStateChange, outside of the lock.
+func (b *Breaker) notify(changed *transition) {
+	if changed == nil {
+		return
+	}
+
+	event := logger.Info()
+	if changed.to == StateOpen {
+		event = logger.Warn()
+	}
+	event.Str("name", b.settings.Name).Str("from", changed.from.String()).Str("to", changed.to.String()).Msg("circuit breaker state changed")
+
+	if b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(b.settings.Name, changed.from, changed.to)
+	}
+}
+
+// Group create breakers with the same settings by name, e.g. one per
+// host or per grpc method.
+type Group struct {
+	settings Settings
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup return new group, Settings.Name is replaced by the name of each breaker.
+func NewGroup(settings Settings) *Group {
+	return &Group{settings: settings, breakers: make(map[string]*Breaker)}
+}
+
+// Get return the breaker of the name, it's created on first use.
+func (g *Group) Get(name string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.breakers[name]
+	if !ok {
+		settings := g.settings
+		settings.Name = name
+		b = New(settings)
+		g.breakers[name] = b
+	}
+	return b
+}
@@ -0,0 +1,107 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("down")
+
+func TestConsecutiveFailures(t *testing.T) {
+	var changes []State
+	b := New(Settings{
+		Name:                "test",
+		ConsecutiveFailures: 3,
+		OpenTimeout:         20 * time.Millisecond,
+		OnStateChange:       func(name string, from, to State) { changes = append(changes, to) },
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := b.Execute(func() error { return errDown }); err != errDown {
+			t.Fatalf("expected call error, got %v", err)
+		}
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+
+	called := false
+	err := b.ExecuteWithFallback(func() error { called = true; return nil }, func(err error) error {
+		if err != ErrOpen {
+			t.Fatalf("expected ErrOpen, got %v", err)
+		}
+		return nil
+	})
+	if err != nil || called {
+		t.Fatalf("expected fallback without call, got %v %v", err, called)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("expected trial request, got %v", err)
+	}
+	if _, err = b.Allow(); err != ErrTooManyRequests {
+		t.Fatalf("expected ErrTooManyRequests, got %v", err)
+	}
+	done(nil)
+
+	if b.State() != StateClosed {
+		t.Fatalf("expected closed, got %s", b.State())
+	}
+	want := []State{StateOpen, StateHalfOpen, StateClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("unexpected transitions: %v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("unexpected transitions: %v", changes)
+		}
+	}
+}
+
+func TestFailureRatio(t *testing.T) {
+	b := New(Settings{ConsecutiveFailures: 100, FailureRatio: 0.5, MinRequests: 10})
+
+	for i := 0; i < 10; i++ {
+		fail := i%2 == 1
+		v, _ := Do(b, func() (int, error) {
+			if fail {
+				return 0, errDown
+			}
+			return 1, nil
+		}, nil)
+		if !fail && v != 1 {
+			t.Fatalf("unexpected result %d", v)
+		}
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("expected open at 50%% failures, got %s", b.State())
+	}
+}
+
+func TestLateResult(t *testing.T) {
+	b := New(Settings{ConsecutiveFailures: 1, OpenTimeout: time.Hour})
+
+	slow, _ := b.Allow()
+	b.Execute(func() error { return errDown })
+	// results of requests started before the breaker opened are ignored
+	slow(nil)
+	if b.State() != StateOpen {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+}
+
+func TestIsFailureNotCalledOnSuccess(t *testing.T) {
+	b := New(Settings{ConsecutiveFailures: 1, IsFailure: func(err error) bool { return true }})
+
+	b.Execute(func() error { return nil })
+	if b.State() != StateClosed {
+		t.Fatalf("expected closed, got %s", b.State())
+	}
+	b.Execute(func() error { return errDown })
+	if b.State() != StateOpen {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+}
@@ -0,0 +1,46 @@
+package breaker
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StatusError is the failure recorded for a server error response.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("breaker: server error %d", e.StatusCode)
+}
+
+// Transport wrap next with the breaker, transport errors and 5xx responses
+// count as failures. Rejected requests fail with ErrOpen or ErrTooManyRequests.
+// If next is nil, http.DefaultTransport is used.
+// EX used: client := &http.Client{Transport: breaker.Transport(b, nil)}
+func Transport(b *Breaker, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{breaker: b, next: next}
+}
+
+type transport struct {
+	breaker *Breaker
+	next    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		done(&StatusError{StatusCode: resp.StatusCode})
+	} else {
+		done(err)
+	}
+	return resp, err
+}
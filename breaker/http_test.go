@@ -0,0 +1,58 @@
+package breaker
+
+import (
+	"net/http"
+	"testing"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		err     error
+		failure bool
+	}{
+		{"ok", http.StatusOK, nil, false},
+		{"not found", http.StatusNotFound, nil, false},
+		{"too many requests", http.StatusTooManyRequests, nil, false},
+		{"server error", http.StatusInternalServerError, nil, true},
+		{"bad gateway", http.StatusBadGateway, nil, true},
+		{"transport error", 0, errDown, true},
+	}
+	for _, tt := range tests {
+		b := New(Settings{ConsecutiveFailures: 1})
+		client := &http.Client{Transport: Transport(b, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return &http.Response{StatusCode: tt.status, Body: http.NoBody}, nil
+		}))}
+
+		resp, err := client.Get("http://example.test")
+		if err == nil {
+			resp.Body.Close()
+		}
+		if open := b.State() == StateOpen; open != tt.failure {
+			t.Errorf("%s: expected failure %v, got state %s", tt.name, tt.failure, b.State())
+		}
+	}
+
+	// rejected without calling next
+	b := New(Settings{ConsecutiveFailures: 1})
+	b.Execute(func() error { return errDown })
+	called := false
+	rt := Transport(b, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return nil, nil
+	}))
+	req, _ := http.NewRequest(http.MethodGet, "http://example.test", nil)
+	if _, err := rt.RoundTrip(req); err != ErrOpen || called {
+		t.Fatalf("expected ErrOpen without call, got %v, %v", err, called)
+	}
+}
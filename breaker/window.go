@@ -0,0 +1,65 @@
+package breaker
+
+import "time"
+
+// window count requests and failures over a rolling window split in
+// buckets, buckets older than the window are dropped as time moves.
+type window struct {
+	size    time.Duration
+	buckets []bucket
+	// current is the index of the last bucket written, in units of size since the epoch
+	current int64
+}
+
+type bucket struct {
+	requests uint32
+	failures uint32
+}
+
+func newWindow(span time.Duration, buckets int) *window {
+	size := span / time.Duration(buckets)
+	if size <= 0 {
+		size = 1
+	}
+	return &window{size: size, buckets: make([]bucket, buckets)}
+}
+
+// advance clear the buckets between the last write and now.
+func (w *window) advance(now time.Time) {
+	current := now.UnixNano() / int64(w.size)
+	elapsed := current - w.current
+	if elapsed <= 0 {
+		return
+	}
+	if elapsed > int64(len(w.buckets)) {
+		elapsed = int64(len(w.buckets))
+	}
+	for i := int64(1); i <= elapsed; i++ {
+		w.buckets[(w.current+i)%int64(len(w.buckets))] = bucket{}
+	}
+	w.current = current
+}
+
+func (w *window) add(now time.Time, failure bool) {
+	w.advance(now)
+	b := &w.buckets[w.current%int64(len(w.buckets))]
+	b.requests++
+	if failure {
+		b.failures++
+	}
+}
+
+func (w *window) totals(now time.Time) (requests, failures uint32) {
+	w.advance(now)
+	for _, b := range w.buckets {
+		requests += b.requests
+		failures += b.failures
+	}
+	return requests, failures
+}
+
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
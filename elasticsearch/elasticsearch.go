@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ponlv/go-kit/breaker"
 	"github.com/ponlv/go-kit/elasticsearch/utils"
 
 	el "github.com/olivere/elastic/v7"
@@ -29,6 +30,8 @@ type Config struct {
 	RetriesTimeout        int
 	ResponseHeaderTimeout int
 	Index                 string
+	// Breaker trip on transport errors and 5xx responses of every call, nil means disabled.
+	Breaker *breaker.Breaker
 }
 
 type ElasticClient struct {
@@ -46,6 +49,7 @@ type ElasticClient struct {
 	maxRetries            int
 	retriesTimeout        int
 	responseHeaderTimeout int
+	breaker               *breaker.Breaker
 	Client                *el.Client
 	Index                 string
 }
@@ -79,6 +83,7 @@ func NewElasticClient(config Config) (IElasticConnector, error) {
 		maxRetries:            config.MaxRetries,
 		retriesTimeout:        config.RetriesTimeout,
 		responseHeaderTimeout: config.ResponseHeaderTimeout,
+		breaker:               config.Breaker,
 	}
 	err := elasticPool.GetConn()
 	if err != nil {
@@ -88,14 +93,18 @@ func NewElasticClient(config Config) (IElasticConnector, error) {
 }
 
 func (e *ElasticClient) GetConn() error {
-	client, err := el.NewClient(
+	options := []el.ClientOptionFunc{
 		el.SetBasicAuth(e.user, e.password),
 		el.SetURL(strings.Join(e.host[:], ",")),
 		el.SetSniff(false),
 		// el.SetMaxRetries(e.maxRetries),
-		el.SetHealthcheckInterval(time.Duration(e.responseHeaderTimeout)*time.Second),
-		el.SetHealthcheckTimeout(time.Duration(e.responseHeaderTimeout)*time.Second),
-	)
+		el.SetHealthcheckInterval(time.Duration(e.responseHeaderTimeout) * time.Second),
+		el.SetHealthcheckTimeout(time.Duration(e.responseHeaderTimeout) * time.Second),
+	}
+	if e.breaker != nil {
+		options = append(options, el.SetHttpClient(&http.Client{Transport: breaker.Transport(e.breaker, nil)}))
+	}
+	client, err := el.NewClient(options...)
 	if err != nil {
 		// Handle error
 		log.Error("ElasticClient - GetConn => Error : ", err.Error())
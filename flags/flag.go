@@ -0,0 +1,119 @@
+package flags
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"time"
+)
+
+// Flag is a feature flag or a dynamic setting. Values are JSON, so a flag
+// can hold a bool, a number, a string or an object.
+// EX used:
+//
+//	flag := &flags.Flag{
+//		Key:     "new-checkout",
+//		Enabled: true,
+//		Value:   json.RawMessage(`true`),
+//		Default: json.RawMessage(`false`),
+//		Rules:   []flags.Rule{{Tenants: []string{"7"}}, {Percentage: flags.Percent(10)}},
+//	}
+type Flag struct {
+	Key string `json:"key"`
+	// Enabled false serve Default to everyone.
+	Enabled bool `json:"enabled"`
+	// Value is served to the subjects matching a rule, or to everyone when there is no rule.
+	Value json.RawMessage `json:"value,omitempty"`
+	// Default is served when the flag is disabled or no rule matches.
+	Default json.RawMessage `json:"default,omitempty"`
+	// Rules are checked in order, the first matching rule is used.
+	Rules       []Rule    `json:"rules,omitempty"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Rule target subjects by user, tenant and percentage, empty conditions
+// match every subject.
+type Rule struct {
+	Users   []string `json:"users,omitempty"`
+	Tenants []string `json:"tenants,omitempty"`
+	// Percentage of the matching subjects between 0 and 100, nil means all
+	// of them and 0 none of them. Subjects are bucketed by user, or tenant
+	// without user, so a subject keep the same answer while the percentage
+	// grows.
+	Percentage *float64 `json:"percentage,omitempty"`
+	// Value override Flag.Value for this rule.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Evaluate return the value of the flag for the subject, nil if the flag
+// has no value for it.
+func (f *Flag) Evaluate(subject Subject) json.RawMessage {
+	if !f.Enabled {
+		return f.Default
+	}
+	if len(f.Rules) == 0 {
+		return f.Value
+	}
+
+	for _, rule := range f.Rules {
+		if rule.match(f.Key, subject) {
+			if len(rule.Value) > 0 {
+				return rule.Value
+			}
+			return f.Value
+		}
+	}
+	return f.Default
+}
+
+func (r *Rule) match(key string, subject Subject) bool {
+	if len(r.Users) > 0 && !contains(r.Users, subject.UserID) {
+		return false
+	}
+	if len(r.Tenants) > 0 && !contains(r.Tenants, subject.TenantID) {
+		return false
+	}
+	if r.Percentage == nil || *r.Percentage >= 100 {
+		return true
+	}
+	if *r.Percentage <= 0 {
+		return false
+	}
+
+	id := subject.UserID
+	if id == "" {
+		id = subject.TenantID
+	}
+	if id == "" {
+		return false
+	}
+	return bucket(key, id) < *r.Percentage
+}
+
+// Percent return a pointer to the percentage, for Rule.Percentage.
+func Percent(p float64) *float64 {
+	return &p
+}
+
+// bucket return a stable number in [0, 100) for the flag and the subject,
+// the flag key is part of the hash so rollouts of different flags do not
+// target the same subjects.
+func bucket(key, id string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func contains(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
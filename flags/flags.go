@@ -0,0 +1,338 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+	searedis "github.com/ponlv/go-kit/redis"
+	seapubsub "github.com/ponlv/go-kit/redis/pubsub"
+	"github.com/ponlv/go-kit/ristretto"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var logger = plog.NewBizLogger("flags")
+
+var (
+	// ErrNotFound is returned by Store.Get when the flag does not exist.
+	ErrNotFound = errors.New("flags: flag not found")
+	// ErrNotInitialized is returned by Default when Init was not called.
+	ErrNotInitialized = errors.New("flags: store is not initialized")
+)
+
+// Options struct contain options of Store.
+type Options struct {
+	// Namespace separate the flags of services, default to "default".
+	Namespace string
+	// CacheTTL bound how long a pod may serve a stale flag when a
+	// notification is missed, default to 1 minute.
+	CacheTTL time.Duration
+	// Subject return who flags are evaluated for, default to
+	// SubjectFromContext which needs UnaryServerInterceptor on gRPC servers.
+	Subject func(ctx context.Context) Subject
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Store keep the flags of a namespace in a redis hash, each pod read them
+// through a local cache that is evicted by pub/sub notifications on change.
+type Store struct {
+	opts  Options
+	cache *ristretto.Cache[string, *Flag]
+	sub   *seapubsub.Subscriber
+}
+
+type notification struct {
+	Key string `json:"key"`
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultStore *Store
+)
+
+// Init create the default store used by the package functions such as Bool.
+// EX used: err := flags.Init(ctx, &flags.Options{Namespace: "checkout"})
+func Init(ctx context.Context, opts *Options) error {
+	s, err := NewStore(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defaultMu.Lock()
+	prev := defaultStore
+	defaultStore = s
+	defaultMu.Unlock()
+
+	if prev != nil {
+		prev.Close()
+	}
+	return nil
+}
+
+// Default return the store created by Init.
+func Default() (*Store, error) {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultStore == nil {
+		return nil, ErrNotInitialized
+	}
+	return defaultStore, nil
+}
+
+// NewStore return new store subscribed to the changes of its namespace.
+func NewStore(ctx context.Context, opts *Options) (*Store, error) {
+	s := &Store{}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Namespace == "" {
+		s.opts.Namespace = "default"
+	}
+	if s.opts.CacheTTL <= 0 {
+		s.opts.CacheTTL = time.Minute
+	}
+	if s.opts.Subject == nil {
+		s.opts.Subject = SubjectFromContext
+	}
+	if s.opts.Client == nil {
+		s.opts.Client = searedis.GetClient()
+	}
+
+	cache, err := ristretto.New[string, *Flag](ristretto.Config[*Flag]{
+		NumCounters: 1e5,
+		MaxCost:     1e4,
+		TTL:         s.opts.CacheTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.cache = cache
+
+	s.sub, err = seapubsub.NewSubscriber(&seapubsub.Options{Client: s.opts.Client})
+	if err != nil {
+		s.cache.Close()
+		return nil, err
+	}
+	err = seapubsub.Subscribe(ctx, s.sub, s.channel(), func(ctx context.Context, channel string, n notification) error {
+		s.cache.Delete(n.Key)
+		return nil
+	})
+	if err != nil {
+		s.cache.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get return the flag, from the local cache when possible.
+func (s *Store) Get(ctx context.Context, key string) (*Flag, error) {
+	flag, err := s.cache.GetOrLoad(ctx, key, func(ctx context.Context) (*Flag, error) {
+		return s.load(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if flag == nil {
+		return nil, ErrNotFound
+	}
+	return flag, nil
+}
+
+// load read the flag from redis, a missing flag is nil so it's cached too.
+func (s *Store) load(ctx context.Context, key string) (*Flag, error) {
+	data, err := s.opts.Client.HGet(ctx, s.hashKey(), key).Bytes()
+	if err == goredislib.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	flag := &Flag{}
+	if err = json.Unmarshal(data, flag); err != nil {
+		return nil, err
+	}
+	return flag, nil
+}
+
+// List return every flag of the namespace from redis.
+func (s *Store) List(ctx context.Context) ([]*Flag, error) {
+	values, err := s.opts.Client.HGetAll(ctx, s.hashKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Flag, 0, len(values))
+	for key, data := range values {
+		flag := &Flag{}
+		if err = json.Unmarshal([]byte(data), flag); err != nil {
+			logger.Error().Err(err).Var("key", key).Msg("error when decode flag")
+			continue
+		}
+		list = append(list, flag)
+	}
+	return list, nil
+}
+
+// Set save the flag and notify every pod to reload it.
+func (s *Store) Set(ctx context.Context, flag *Flag) error {
+	if flag.Key == "" {
+		return errors.New("flags: key is required")
+	}
+	flag.UpdatedAt = time.Now()
+	data, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+
+	if err = s.opts.Client.HSet(ctx, s.hashKey(), flag.Key, data).Err(); err != nil {
+		return err
+	}
+	return s.notify(ctx, flag.Key)
+}
+
+// Delete remove the flag and notify every pod.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if err := s.opts.Client.HDel(ctx, s.hashKey(), key).Err(); err != nil {
+		return err
+	}
+	return s.notify(ctx, key)
+}
+
+func (s *Store) notify(ctx context.Context, key string) error {
+	// evicted locally now, the notification reaches the other pods
+	s.cache.Delete(key)
+	return seapubsub.PublishWithClient(ctx, s.opts.Client, s.channel(), notification{Key: key})
+}
+
+// Evaluate return the value of the flag for the subject of ctx, false if
+// the flag does not exist or has no value for it.
+func (s *Store) Evaluate(ctx context.Context, key string) (json.RawMessage, bool) {
+	flag, err := s.Get(ctx, key)
+	if err != nil {
+		if err != ErrNotFound {
+			logger.Error().Err(err).Var("key", key).Msg("error when get flag")
+		}
+		return nil, false
+	}
+
+	value := flag.Evaluate(s.opts.Subject(ctx))
+	return value, len(value) > 0
+}
+
+// Bool return the flag as a bool, false if it does not exist.
+func (s *Store) Bool(ctx context.Context, key string) bool {
+	return Value(ctx, s, key, false)
+}
+
+// String return the flag as a string, def if it does not exist.
+func (s *Store) String(ctx context.Context, key, def string) string {
+	return Value(ctx, s, key, def)
+}
+
+// Int return the flag as an int64, def if it does not exist.
+func (s *Store) Int(ctx context.Context, key string, def int64) int64 {
+	return Value(ctx, s, key, def)
+}
+
+// Float return the flag as a float64, def if it does not exist.
+func (s *Store) Float(ctx context.Context, key string, def float64) float64 {
+	return Value(ctx, s, key, def)
+}
+
+// Duration return the flag as a duration such as "1m30s", def if it does not exist.
+func (s *Store) Duration(ctx context.Context, key string, def time.Duration) time.Duration {
+	value := s.String(ctx, key, "")
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Error().Err(err).Var("key", key).Msg("error when decode flag")
+		return def
+	}
+	return d
+}
+
+// Close stop listening for changes.
+func (s *Store) Close() error {
+	err := s.sub.Close()
+	s.cache.Close()
+	return err
+}
+
+// Value return the flag decoded as T, def if it does not exist or can not be decoded.
+// EX used: limits := flags.Value(ctx, store, "upload-limits", defaultLimits)
+func Value[T any](ctx context.Context, s *Store, key string, def T) T {
+	data, ok := s.Evaluate(ctx, key)
+	if !ok {
+		return def
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		logger.Error().Err(err).Var("key", key).Msg("error when decode flag")
+		return def
+	}
+	return value
+}
+
+// Bool return the flag of the default store as a bool, false if it does not exist.
+// EX used: if flags.Bool(ctx, "new-checkout") {...}
+func Bool(ctx context.Context, key string) bool {
+	s, err := Default()
+	if err != nil {
+		return false
+	}
+	return s.Bool(ctx, key)
+}
+
+// String return the flag of the default store as a string, def if it does not exist.
+func String(ctx context.Context, key, def string) string {
+	s, err := Default()
+	if err != nil {
+		return def
+	}
+	return s.String(ctx, key, def)
+}
+
+// Int return the flag of the default store as an int64, def if it does not exist.
+func Int(ctx context.Context, key string, def int64) int64 {
+	s, err := Default()
+	if err != nil {
+		return def
+	}
+	return s.Int(ctx, key, def)
+}
+
+// Float return the flag of the default store as a float64, def if it does not exist.
+func Float(ctx context.Context, key string, def float64) float64 {
+	s, err := Default()
+	if err != nil {
+		return def
+	}
+	return s.Float(ctx, key, def)
+}
+
+// Duration return the flag of the default store as a duration, def if it does not exist.
+func Duration(ctx context.Context, key string, def time.Duration) time.Duration {
+	s, err := Default()
+	if err != nil {
+		return def
+	}
+	return s.Duration(ctx, key, def)
+}
+
+// hashKey return the hash holding the flags of the namespace.
+func (s *Store) hashKey() string {
+	return "flags:{" + s.opts.Namespace + "}"
+}
+
+func (s *Store) channel() string {
+	return "flags:{" + s.opts.Namespace + "}:changed"
+}
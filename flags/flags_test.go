@@ -0,0 +1,173 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+
+	"google.golang.org/grpc"
+)
+
+func TestEvaluate(t *testing.T) {
+	flag := &Flag{
+		Key:     "new-checkout",
+		Enabled: true,
+		Value:   json.RawMessage(`true`),
+		Default: json.RawMessage(`false`),
+		Rules: []Rule{
+			{Users: []string{"42"}, Value: json.RawMessage(`"beta"`)},
+			{Tenants: []string{"7"}},
+			{Percentage: Percent(25)},
+		},
+	}
+
+	cases := []struct {
+		subject Subject
+		want    string
+	}{
+		{Subject{UserID: "42", TenantID: "1"}, `"beta"`},
+		{Subject{UserID: "1", TenantID: "7"}, `true`},
+		{Subject{}, `false`},
+	}
+	for _, c := range cases {
+		if got := string(flag.Evaluate(c.subject)); got != c.want {
+			t.Fatalf("subject %+v: expected %s, got %s", c.subject, c.want, got)
+		}
+	}
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		if string(flag.Evaluate(Subject{UserID: "u" + strconv.Itoa(i)})) == "true" {
+			enabled++
+		}
+	}
+	if enabled < 200 || enabled > 300 {
+		t.Fatalf("expected about 25%% of users enabled, got %d", enabled)
+	}
+
+	flag.Enabled = false
+	if got := string(flag.Evaluate(Subject{UserID: "42"})); got != `false` {
+		t.Fatalf("expected default when disabled, got %s", got)
+	}
+}
+
+func TestEvaluatePercentage(t *testing.T) {
+	flag := &Flag{
+		Key:     "new-checkout",
+		Enabled: true,
+		Value:   json.RawMessage(`true`),
+		Default: json.RawMessage(`false`),
+		Rules:   []Rule{{Percentage: Percent(0)}},
+	}
+	for i := 0; i < 100; i++ {
+		if got := string(flag.Evaluate(Subject{UserID: "u" + strconv.Itoa(i)})); got != `false` {
+			t.Fatalf("expected nobody enabled at 0%%, got %s", got)
+		}
+	}
+
+	// a rule without percentage match everyone, including a subject without id
+	flag.Rules = nil
+	if err := json.Unmarshal([]byte(`[{"tenants":["7"]}]`), &flag.Rules); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(flag.Evaluate(Subject{TenantID: "7"})); got != `true` {
+		t.Fatalf("expected everyone enabled without percentage, got %s", got)
+	}
+
+	flag.Rules = nil
+	if err := json.Unmarshal([]byte(`[{"percentage":0}]`), &flag.Rules); err != nil {
+		t.Fatal(err)
+	}
+	if flag.Rules[0].Percentage == nil {
+		t.Fatal("expected percentage 0 to be decoded")
+	}
+	if got := string(flag.Evaluate(Subject{UserID: "42"})); got != `false` {
+		t.Fatalf("expected nobody enabled at 0%%, got %s", got)
+	}
+}
+
+func TestSubjectFromContext(t *testing.T) {
+	ctx := WithSubject(context.Background(), Subject{UserID: "42", TenantID: "7"})
+	if s := SubjectFromContext(ctx); s.UserID != "42" || s.TenantID != "7" {
+		t.Fatalf("unexpected subject: %+v", s)
+	}
+	if s := SubjectFromContext(context.Background()); s != (Subject{}) {
+		t.Fatalf("expected empty subject, got %+v", s)
+	}
+}
+
+func TestSubjectInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		// the first read resolves the subject, the next ones reuse it
+		lazy := ctx.Value(lazySubjectKey{}).(*lazySubject)
+		lazy.once.Do(func() { lazy.subject = Subject{UserID: "42"} })
+		if s := SubjectFromContext(ctx); s.UserID != "42" {
+			t.Fatalf("expected the cached subject, got %+v", s)
+		}
+		if s := SubjectFromContext(WithSubject(ctx, Subject{UserID: "7"})); s.UserID != "7" {
+			t.Fatalf("expected WithSubject to take precedence, got %+v", s)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreInvalidation(t *testing.T) {
+	_, client := redistest.NewServer(t, redistest.MemoryHandler())
+	ctx := context.Background()
+
+	writer, err := NewStore(ctx, &Options{Namespace: "test", CacheTTL: time.Hour, Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	reader, err := NewStore(ctx, &Options{Namespace: "test", CacheTTL: time.Hour, Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	set := func(value string) {
+		if err := writer.Set(ctx, &Flag{Key: "limit", Enabled: true, Value: json.RawMessage(value)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("1")
+	if got := reader.Int(ctx, "limit", 0); got != 1 {
+		t.Fatalf("expected 1, got %d", got)
+	}
+	reader.cache.Wait()
+
+	// changed without notification, the reader still serves its cache
+	data, _ := json.Marshal(&Flag{Key: "limit", Enabled: true, Value: json.RawMessage("2")})
+	client.HSet(ctx, writer.hashKey(), "limit", data)
+	if got := reader.Int(ctx, "limit", 0); got != 1 {
+		t.Fatalf("expected the cached 1, got %d", got)
+	}
+
+	set("3")
+	deadline := time.Now().Add(2 * time.Second)
+	for reader.Int(ctx, "limit", 0) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("reader cache was not invalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err = writer.Delete(ctx, "limit"); err != nil {
+		t.Fatal(err)
+	}
+	for reader.Int(ctx, "limit", 0) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("reader cache was not invalidated on delete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
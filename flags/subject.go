@@ -0,0 +1,76 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	kitgrpc "github.com/ponlv/go-kit/grpc"
+
+	"google.golang.org/grpc"
+)
+
+// Subject is who a flag is evaluated for.
+type Subject struct {
+	UserID   string
+	TenantID string
+}
+
+type subjectKey struct{}
+
+// lazySubject is set by UnaryServerInterceptor, the subject is resolved by
+// the first flag read of the request and reused by the next ones.
+type lazySubject struct {
+	once    sync.Once
+	subject Subject
+}
+
+type lazySubjectKey struct{}
+
+// WithSubject return a context evaluating flags for the subject, it take
+// precedence over the JWT claims.
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext return the subject set by WithSubject, or else the
+// user of the gRPC JWT claims. The tenant is read from the "tenant_id"
+// field of the claims metadata when it is a JSON object.
+// UnaryServerInterceptor must be installed on gRPC servers reading flags,
+// otherwise every flag read of a request verifies the JWT again.
+func SubjectFromContext(ctx context.Context) Subject {
+	if subject, ok := ctx.Value(subjectKey{}).(Subject); ok {
+		return subject
+	}
+	if lazy, ok := ctx.Value(lazySubjectKey{}).(*lazySubject); ok {
+		lazy.once.Do(func() { lazy.subject = claimsSubject(ctx) })
+		return lazy.subject
+	}
+	return claimsSubject(ctx)
+}
+
+// claimsSubject return the subject of the gRPC JWT claims.
+func claimsSubject(ctx context.Context) Subject {
+	claims := kitgrpc.GetJWTContent(ctx)
+	if claims == nil {
+		return Subject{}
+	}
+
+	subject := Subject{UserID: claims.UserID}
+	var metadata struct {
+		TenantID string `json:"tenant_id"`
+	}
+	if json.Unmarshal([]byte(claims.Metadata), &metadata) == nil {
+		subject.TenantID = metadata.TenantID
+	}
+	return subject
+}
+
+// UnaryServerInterceptor resolve the subject once per request, on the first
+// flag read, so the next reads of the handler do not verify the JWT again.
+// EX used: grpc.ChainUnaryInterceptor(..., flags.UnaryServerInterceptor())
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(context.WithValue(ctx, lazySubjectKey{}, &lazySubject{}), req)
+	}
+}
@@ -0,0 +1,41 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/ponlv/go-kit/breaker"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// breakerFailureCodes are the codes of a failing server, other codes such
+// as NotFound or InvalidArgument are answers of a healthy server.
+var breakerFailureCodes = map[codes.Code]bool{
+	codes.Unknown:           true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Internal:          true,
+	codes.Unavailable:       true,
+}
+
+// BreakerUnaryClientInterceptor call each method through its breaker of the group,
+// calls fail with Unavailable without being sent while the circuit is open.
+// EX used: grpc.WithUnaryInterceptor(BreakerUnaryClientInterceptor(breaker.NewGroup(breaker.Settings{FailureRatio: 0.5})))
+func BreakerUnaryClientInterceptor(group *breaker.Group) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, err := group.Get(method).Allow()
+		if err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		if breakerFailureCodes[status.Code(err)] {
+			done(err)
+		} else {
+			done(nil)
+		}
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ponlv/go-kit/breaker"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreakerUnaryClientInterceptor(t *testing.T) {
+	tests := []struct {
+		err     error
+		failure bool
+	}{
+		{nil, false},
+		{status.Error(codes.NotFound, "not found"), false},
+		{status.Error(codes.InvalidArgument, "bad request"), false},
+		{status.Error(codes.PermissionDenied, "denied"), false},
+		{status.Error(codes.Canceled, "canceled"), false},
+		{status.Error(codes.Unavailable, "down"), true},
+		{status.Error(codes.Internal, "panic"), true},
+		{status.Error(codes.DeadlineExceeded, "timeout"), true},
+		{status.Error(codes.ResourceExhausted, "overloaded"), true},
+		{errors.New("not a status"), true},
+	}
+	for _, tt := range tests {
+		group := breaker.NewGroup(breaker.Settings{ConsecutiveFailures: 1})
+		interceptor := BreakerUnaryClientInterceptor(group)
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return tt.err
+		}
+
+		if err := interceptor(context.Background(), "/svc/Get", nil, nil, nil, invoker); err != tt.err {
+			t.Fatalf("expected %v, got %v", tt.err, err)
+		}
+		if open := group.Get("/svc/Get").State() == breaker.StateOpen; open != tt.failure {
+			t.Errorf("%v: expected failure %v", tt.err, tt.failure)
+		}
+	}
+
+	// rejected without calling the server
+	group := breaker.NewGroup(breaker.Settings{ConsecutiveFailures: 1})
+	interceptor := BreakerUnaryClientInterceptor(group)
+	called := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		called++
+		return status.Error(codes.Unavailable, "down")
+	}
+	interceptor(context.Background(), "/svc/Get", nil, nil, nil, invoker)
+	err := interceptor(context.Background(), "/svc/Get", nil, nil, nil, invoker)
+	if status.Code(err) != codes.Unavailable || called != 1 {
+		t.Fatalf("expected Unavailable without call, got %v after %d calls", err, called)
+	}
+	// breakers are per method
+	if err = interceptor(context.Background(), "/svc/List", nil, nil, nil, invoker); called != 2 {
+		t.Fatalf("expected /svc/List to be called, got %v", err)
+	}
+}
@@ -31,8 +31,8 @@ type grpcServer struct {
 var grpcInstance *grpcServer
 var log = plog.NewBizLogger("grpc")
 
-// init service grpc
-func Initial(name, host, port, tokenKey string, whitelist []string) {
+// init service grpc, interceptors are chained after the authentication interceptor
+func Initial(name, host, port, tokenKey string, whitelist []string, interceptors ...grpc.UnaryServerInterceptor) {
 
 	if grpcInstance != nil {
 		log.Warn().Msg("grpc server is alrealdy declare")
@@ -52,7 +52,9 @@ func Initial(name, host, port, tokenKey string, whitelist []string) {
 	grpcInstance.service = grpc.NewServer(
 		grpc.MaxRecvMsgSize(maxMsgSize),
 		grpc.MaxSendMsgSize(maxMsgSize),
-		grpc.UnaryInterceptor(grpc_auth.UnaryServerInterceptor(authFunc)), //middleware verify authen
+		grpc.ChainUnaryInterceptor(append([]grpc.UnaryServerInterceptor{
+			grpc_auth.UnaryServerInterceptor(authFunc), //middleware verify authen
+		}, interceptors...)...),
 	)
 }
 
@@ -139,7 +141,10 @@ func authFunc(ctx context.Context) (context.Context, error) {
 	}
 
 	// verify token
-	claims, err := jwt.VerifyJWTToken(grpcInstance.tokenKey, token)
+	claims, err := jwt.VerifyJWTTokenWithCtx(ctx, grpcInstance.tokenKey, token)
+	if err == jwt.ErrTokenRevoked {
+		return nil, err
+	}
 	if err != nil {
 		return nil, errors.New("_TOKEN_EXPIRED_")
 	}
@@ -150,9 +155,12 @@ func authFunc(ctx context.Context) (context.Context, error) {
 			ctx = metadata.AppendToOutgoingContext(ctx, "userid", claims.UserID)
 		}
 	}
-	return ctx, nil
+	return context.WithValue(ctx, claimsCtxKey{}, claims), nil
 }
 
+// claimsCtxKey carry the claims verified by the authentication interceptor.
+type claimsCtxKey struct{}
+
 func CtxWithToken(ctx context.Context, token string, args ...string) context.Context {
 	md := metadata.Pairs(
 		"authorization", fmt.Sprintf("%s %v", "bearer", token),
@@ -169,13 +177,21 @@ func FowardCtx(ctx context.Context) context.Context {
 	return CtxWithToken(ctx, token)
 }
 
+// GetJWTContent return the verified claims of the request, nil if the
+// token is missing or invalid.
 func GetJWTContent(ctx context.Context) *jwt.CustomClaims {
+	if claims, ok := ctx.Value(claimsCtxKey{}).(*jwt.CustomClaims); ok {
+		return claims
+	}
+	if grpcInstance == nil {
+		return nil
+	}
 	token, err := grpc_auth.AuthFromMD(ctx, "bearer")
 	//fmt.Println(token)
 	if err != nil {
 		return nil
 	}
-	claims, err_v := jwt.VerifyJWTToken(grpcInstance.tokenKey, token)
+	claims, err_v := jwt.VerifyJWTTokenWithCtx(ctx, grpcInstance.tokenKey, token)
 	if err_v != nil {
 		return nil
 	}
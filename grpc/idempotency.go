@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+
+	protov1 "github.com/golang/protobuf/proto"
+	seaidempotency "github.com/ponlv/go-kit/redis/idempotency"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// IdempotencyKeyHeader is the metadata key of the client idempotency key.
+const IdempotencyKeyHeader = "idempotency-key"
+
+// IdempotencyUnaryInterceptor process requests with the same "idempotency-key"
+// metadata once per method and user and replay the stored response to
+// retries. The user is taken from the verified jwt claims, requests without
+// a valid token share the keys of the method.
+// A key reused with a different request fails with InvalidArgument, a
+// duplicate of an in-progress request fails with Aborted. Requests without
+// the key are not affected. If methods is empty, all methods are covered.
+func IdempotencyUnaryInterceptor(store *seaidempotency.Store, methods ...string) grpc.UnaryServerInterceptor {
+	covered := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		covered[m] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if len(covered) > 0 && !covered[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(IdempotencyKeyHeader)
+		if len(keys) == 0 || keys[0] == "" {
+			return handler(ctx, req)
+		}
+		var user string
+		if claims := GetJWTContent(ctx); claims != nil {
+			user = claims.UserID
+		}
+		key := idempotencyKey(info.FullMethod, user, keys[0])
+
+		payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(protov1.MessageV2(req))
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		claim, stored, err := store.Begin(ctx, key, seaidempotency.Fingerprint([]byte(info.FullMethod), payload))
+		switch err {
+		case nil:
+		case seaidempotency.ErrKeyReused:
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case seaidempotency.ErrInProgress:
+			return nil, status.Error(codes.Aborted, err.Error())
+		default:
+			// fail closed, processing twice is worse than failing
+			log.Error().Err(err).Str("method", info.FullMethod).Msg("idempotency check failed")
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+
+		if claim == nil {
+			resp, err := unmarshalAny(stored)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return protov1.MessageV1(resp), nil
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			_ = claim.Abort(context.Background())
+			return resp, err
+		}
+
+		wrapped, err := anypb.New(protov1.MessageV2(resp))
+		if err == nil {
+			var data []byte
+			if data, err = proto.Marshal(wrapped); err == nil {
+				err = claim.Complete(ctx, data)
+			}
+		}
+		if err != nil {
+			// the request succeeded, only the replay is lost
+			log.Error().Err(err).Str("method", info.FullMethod).Msg("store idempotent response failed")
+		}
+		return resp, nil
+	}
+}
+
+// idempotencyKey prefix each component with its length, a user or key
+// containing the separator cannot collide with another tuple.
+func idempotencyKey(method, user, key string) string {
+	return fmt.Sprintf("%d:%s:%d:%s:%d:%s", len(method), method, len(user), user, len(key), key)
+}
+
+// unmarshalAny decode a response stored as google.protobuf.Any.
+func unmarshalAny(data []byte) (proto.Message, error) {
+	wrapped := &anypb.Any{}
+	if err := proto.Unmarshal(data, wrapped); err != nil {
+		return nil, err
+	}
+	return wrapped.UnmarshalNew()
+}
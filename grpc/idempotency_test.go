@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+	seaidempotency "github.com/ponlv/go-kit/redis/idempotency"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestIdempotencyUnaryInterceptor(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	store := seaidempotency.NewStore(&seaidempotency.Options{Client: client})
+	interceptor := IdempotencyUnaryInterceptor(store)
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Create"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "k1"))
+
+	calls := 0
+	var handlerErr error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		if handlerErr != nil {
+			return nil, handlerErr
+		}
+		return wrapperspb.String("created " + req.(*wrapperspb.StringValue).Value), nil
+	}
+
+	// failed requests are not stored
+	handlerErr = errors.New("failed")
+	if _, err := interceptor(ctx, wrapperspb.String("a"), info, handler); err != handlerErr {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	handlerErr = nil
+
+	resp, err := interceptor(ctx, wrapperspb.String("a"), info, handler)
+	if err != nil || calls != 2 {
+		t.Fatalf("unexpected result %v, %v after %d calls", resp, err, calls)
+	}
+	replay, err := interceptor(ctx, wrapperspb.String("a"), info, handler)
+	if err != nil || calls != 2 {
+		t.Fatalf("expected a replay, got %v after %d calls", err, calls)
+	}
+	if !proto.Equal(replay.(proto.Message), resp.(proto.Message)) {
+		t.Fatalf("unexpected replay %v, want %v", replay, resp)
+	}
+
+	if _, err = interceptor(ctx, wrapperspb.String("b"), info, handler); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+
+	// requests without the key are not affected
+	for i := 0; i < 2; i++ {
+		if _, err = interceptor(context.Background(), wrapperspb.String("a"), info, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 4 {
+		t.Fatalf("expected requests without key handled, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyUnaryInterceptorInProgress(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	store := seaidempotency.NewStore(&seaidempotency.Options{Client: client})
+	interceptor := IdempotencyUnaryInterceptor(store, "/svc/Create")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "k1"))
+	req := wrapperspb.String("a")
+
+	var dupErr error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		// a duplicate arrives while the first request is in progress
+		_, dupErr = interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/svc/Create"}, nil)
+		return wrapperspb.String("created"), nil
+	}
+	if _, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/svc/Create"}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if status.Code(dupErr) != codes.Aborted {
+		t.Fatalf("expected Aborted, got %v", dupErr)
+	}
+
+	// other methods are not covered
+	called := false
+	_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/svc/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	if err != nil || !called {
+		t.Fatalf("expected the handler called, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+
+	searatelimit "github.com/ponlv/go-kit/redis/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimitKeyFunc return the rate limit key of a request,
+// empty key means the request is not limited.
+type RateLimitKeyFunc func(ctx context.Context, fullMethod string) string
+
+// MethodUserKey limit each user per method, the user is taken from the
+// verified jwt claims. Requests without a valid token, e.g. on whitelisted
+// routes, share the limit of the method.
+func MethodUserKey(ctx context.Context, fullMethod string) string {
+	if claims := GetJWTContent(ctx); claims != nil && claims.UserID != "" {
+		return fullMethod + ":" + claims.UserID
+	}
+	return fullMethod
+}
+
+// RateLimitUnaryInterceptor reject requests over the limit with
+// ResourceExhausted and a "retry-after" header in seconds.
+// If keyFunc is nil, MethodUserKey is used.
+func RateLimitUnaryInterceptor(limiter *searatelimit.Limiter, limit searatelimit.Limit, keyFunc RateLimitKeyFunc) grpc.UnaryServerInterceptor {
+	if keyFunc == nil {
+		keyFunc = MethodUserKey
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := keyFunc(ctx, info.FullMethod)
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		res, err := limiter.Allow(ctx, key, limit)
+		if err != nil {
+			// fail open, redis outage should not take the service down
+			log.Error().Err(err).Str("method", info.FullMethod).Msg("rate limit check failed")
+			return handler(ctx, req)
+		}
+		if !res.Allowed {
+			retryAfter := int(res.RetryAfter.Seconds() + 0.999)
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %ds", retryAfter)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitUnaryClientInterceptor wait until outbound calls are allowed by the limit,
+// calls fail with ResourceExhausted when ctx is done before that, and are not
+// limited when the limiter fails. If keyFunc is nil, the full method name is used.
+func RateLimitUnaryClientInterceptor(limiter *searatelimit.Limiter, limit searatelimit.Limit, keyFunc RateLimitKeyFunc) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		key := method
+		if keyFunc != nil {
+			key = keyFunc(ctx, method)
+		}
+
+		if key != "" {
+			err := limiter.Wait(ctx, key, limit)
+			if err == searatelimit.ErrLimited {
+				return status.Error(codes.ResourceExhausted, err.Error())
+			}
+			if err != nil {
+				// fail open, like the server interceptor
+				log.Error().Err(err).Str("method", method).Msg("rate limit check failed")
+			}
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+	searatelimit "github.com/ponlv/go-kit/redis/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitUnaryInterceptor(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	limit := searatelimit.Limit{Rate: 1, Period: time.Minute, Algorithm: searatelimit.FixedWindow}
+	interceptor := RateLimitUnaryInterceptor(searatelimit.NewLimiter(client), limit, nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Get"}
+	called := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called++
+		return "ok", nil
+	}
+
+	if res, err := interceptor(context.Background(), nil, info, handler); err != nil || res != "ok" {
+		t.Fatalf("unexpected result %v, %v", res, err)
+	}
+	_, err := interceptor(context.Background(), nil, info, handler)
+	if status.Code(err) != codes.ResourceExhausted || called != 1 {
+		t.Fatalf("expected ResourceExhausted without call, got %v after %d calls", err, called)
+	}
+
+	// fail open when redis is down
+	m.Close()
+	if res, err := interceptor(context.Background(), nil, info, handler); err != nil || res != "ok" || called != 2 {
+		t.Fatalf("expected the handler to be called, got %v, %v after %d calls", res, err, called)
+	}
+}
+
+func TestRateLimitUnaryClientInterceptor(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	limit := searatelimit.Limit{Rate: 1, Period: time.Minute, Algorithm: searatelimit.FixedWindow}
+	interceptor := RateLimitUnaryClientInterceptor(searatelimit.NewLimiter(client), limit, nil)
+	called := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		called++
+		return nil
+	}
+
+	if err := interceptor(context.Background(), "/svc/Get", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := interceptor(ctx, "/svc/Get", nil, nil, nil, invoker)
+	if status.Code(err) != codes.ResourceExhausted || called != 1 {
+		t.Fatalf("expected ResourceExhausted without call, got %v after %d calls", err, called)
+	}
+
+	// fail open when redis is down
+	m.Close()
+	if err := interceptor(context.Background(), "/svc/Get", nil, nil, nil, invoker); err != nil || called != 2 {
+		t.Fatalf("expected the call to be made, got %v after %d calls", err, called)
+	}
+}
@@ -0,0 +1,324 @@
+// Package redistest run a minimal redis server speaking RESP, so tests can
+// use a real client without a redis instance.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// Handler reply to a command with nil, a status string, []byte, int, int64,
+// error or []interface{}.
+type Handler func(args []string) interface{}
+
+// Server is a fake redis server, handle reply to each command. MULTI and
+// EXEC are handled by the server like redis does: commands are queued and
+// run on EXEC, the errors of some commands do not abort the others.
+// SUBSCRIBE, UNSUBSCRIBE and PUBLISH are handled by the server too.
+type Server struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	handle Handler
+	calls  [][]string
+	subs   map[string]map[*conn]bool
+}
+
+type conn struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (c *conn) write(reply interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeReply(c.w, reply)
+	return c.w.Flush()
+}
+
+// NewServer start a server closed with the test and return a client of it.
+func NewServer(t testing.TB, handle Handler) (*Server, goredislib.UniversalClient) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{ln: ln, handle: handle, subs: make(map[string]map[*conn]bool)}
+	go s.serve()
+
+	c := goredislib.NewClient(&goredislib.Options{Addr: ln.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() {
+		c.Close()
+		ln.Close()
+	})
+	return s, c
+}
+
+// NewMiniredis start an in-memory redis running lua scripts, closed with the
+// test, and return a client of it. Use Server to script the replies instead.
+func NewMiniredis(t testing.TB) (*miniredis.Miniredis, goredislib.UniversalClient) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := goredislib.NewClient(&goredislib.Options{Addr: m.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		c.Close()
+		m.Close()
+	})
+	return m, c
+}
+
+// Commands return the names of the received commands.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(s.calls))
+	for i, args := range s.calls {
+		names[i] = strings.ToUpper(args[0])
+	}
+	return names
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(nc)
+	}
+}
+
+func (s *Server) serveConn(nc net.Conn) {
+	c := &conn{w: bufio.NewWriter(nc)}
+	defer func() {
+		s.unsubscribe(c, nil)
+		nc.Close()
+	}()
+	r := bufio.NewReader(nc)
+
+	var queued [][]string
+	multi := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.calls = append(s.calls, args)
+		s.mu.Unlock()
+
+		var reply interface{}
+		switch name := strings.ToUpper(args[0]); {
+		case name == "SUBSCRIBE":
+			for _, channel := range args[1:] {
+				if err = c.write(s.subscribe(c, channel)); err != nil {
+					return
+				}
+			}
+			continue
+		case name == "UNSUBSCRIBE":
+			for _, reply := range s.unsubscribe(c, args[1:]) {
+				if err = c.write(reply); err != nil {
+					return
+				}
+			}
+			continue
+		case name == "PUBLISH":
+			reply = s.publish(args[1], args[2])
+		case name == "MULTI":
+			multi, queued, reply = true, nil, "OK"
+		case name == "EXEC":
+			replies := make([]interface{}, len(queued))
+			for i, q := range queued {
+				replies[i] = s.run(q)
+			}
+			multi, queued, reply = false, nil, replies
+		case multi:
+			queued = append(queued, args)
+			reply = "QUEUED"
+		default:
+			reply = s.run(args)
+		}
+		if err = c.write(reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) run(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.ToUpper(args[0]) == "PING" {
+		return "PONG"
+	}
+	return s.handle(args)
+}
+
+func (s *Server) subscribe(c *conn, channel string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[channel] == nil {
+		s.subs[channel] = make(map[*conn]bool)
+	}
+	s.subs[channel][c] = true
+	return []interface{}{[]byte("subscribe"), []byte(channel), int64(s.count(c))}
+}
+
+// unsubscribe remove the channels of the connection, every channel if nil.
+func (s *Server) unsubscribe(c *conn, channels []string) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if channels == nil {
+		for channel, conns := range s.subs {
+			if conns[c] {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	replies := make([]interface{}, len(channels))
+	for i, channel := range channels {
+		delete(s.subs[channel], c)
+		replies[i] = []interface{}{[]byte("unsubscribe"), []byte(channel), int64(s.count(c))}
+	}
+	return replies
+}
+
+// count return the number of channels of the connection, the lock must be held.
+func (s *Server) count(c *conn) int {
+	n := 0
+	for _, conns := range s.subs {
+		if conns[c] {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) publish(channel, payload string) interface{} {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.subs[channel]))
+	for c := range s.subs[channel] {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.write([]interface{}{[]byte("message"), []byte(channel), []byte(payload)})
+	}
+	return int64(len(conns))
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case string:
+		w.WriteString("+" + v + "\r\n")
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case error:
+		w.WriteString("-" + v.Error() + "\r\n")
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, e := range v {
+			writeReply(w, e)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported reply %T", reply))
+	}
+}
+
+// MemoryHandler keep string and hash keys in memory, it supports GET, SET,
+// PTTL, HGET, HSET, HDEL and HGETALL.
+func MemoryHandler() Handler {
+	data := map[string][]byte{}
+	hashes := map[string]map[string][]byte{}
+	return func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if v, ok := data[args[1]]; ok {
+				return v
+			}
+			return nil
+		case "SET":
+			data[args[1]] = []byte(args[2])
+			return "OK"
+		case "PTTL":
+			if _, ok := data[args[1]]; ok {
+				return int64(-1)
+			}
+			return int64(-2)
+		case "HGET":
+			if v, ok := hashes[args[1]][args[2]]; ok {
+				return v
+			}
+			return nil
+		case "HSET":
+			if hashes[args[1]] == nil {
+				hashes[args[1]] = map[string][]byte{}
+			}
+			added := 0
+			for i := 2; i+1 < len(args); i += 2 {
+				if _, ok := hashes[args[1]][args[i]]; !ok {
+					added++
+				}
+				hashes[args[1]][args[i]] = []byte(args[i+1])
+			}
+			return added
+		case "HDEL":
+			deleted := 0
+			for _, field := range args[2:] {
+				if _, ok := hashes[args[1]][field]; ok {
+					delete(hashes[args[1]], field)
+					deleted++
+				}
+			}
+			return deleted
+		case "HGETALL":
+			var values []interface{}
+			for field, v := range hashes[args[1]] {
+				values = append(values, []byte(field), v)
+			}
+			return values
+		}
+		return fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+}
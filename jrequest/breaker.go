@@ -0,0 +1,32 @@
+package jrequest
+
+import (
+	"github.com/ponlv/go-kit/breaker"
+)
+
+// SetBreaker call this request through the circuit breaker, transport
+// errors and 5xx responses count as failures. The request fails with
+// breaker.ErrOpen without being sent while the circuit is open.
+func (ts *JRequest) SetBreaker(b *breaker.Breaker) *JRequest {
+	ts.breaker = b
+	return ts
+}
+
+// allowBreaker report whether the request can be sent, done must be called
+// with the transport error and status code.
+func (ts *JRequest) allowBreaker() (done func(err error, statusCode int), err error) {
+	if ts.breaker == nil {
+		return func(error, int) {}, nil
+	}
+
+	allowed, err := ts.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	return func(err error, statusCode int) {
+		if err == nil && statusCode >= 500 {
+			err = &breaker.StatusError{StatusCode: statusCode}
+		}
+		allowed(err)
+	}, nil
+}
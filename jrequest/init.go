@@ -1,6 +1,7 @@
 package jrequest
 
 import (
+	"github.com/ponlv/go-kit/breaker"
 	"github.com/valyala/fasthttp"
 	"time"
 )
@@ -15,6 +16,9 @@ type Client struct {
 	Statsd         *StatsdConfig
 	ProfilerConfig *ProfilerConfig
 	Timeout        *time.Duration
+	RateLimit      *RateLimitConfig
+	// Breaker is the default circuit breaker of the requests, see JRequest.SetBreaker.
+	Breaker *breaker.Breaker
 }
 
 func DefaultClient() *Client {
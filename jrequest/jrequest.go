@@ -6,6 +6,8 @@ import (
 	"log"
 	"time"
 
+	"github.com/ponlv/go-kit/breaker"
+
 	"github.com/labstack/echo/v4"
 	"github.com/valyala/fasthttp"
 )
@@ -18,18 +20,22 @@ type JRequest struct {
 	metricsSkipper bool
 	statsd         *StatsdConfig
 	*fasthttp.Response
-	req     *fasthttp.Request
-	client  *fasthttp.Client
-	Timeout *time.Duration
+	req       *fasthttp.Request
+	client    *fasthttp.Client
+	Timeout   *time.Duration
+	rateLimit *RateLimitConfig
+	breaker   *breaker.Breaker
 }
 
 // NewHTTPTransport new instance
 func (c *Client) NewJRequest() *JRequest {
 	hts := &JRequest{
-		client:  c.Client,
-		req:     fasthttp.AcquireRequest(),
-		statsd:  c.Statsd,
-		Timeout: c.Timeout,
+		client:    c.Client,
+		req:       fasthttp.AcquireRequest(),
+		statsd:    c.Statsd,
+		Timeout:   c.Timeout,
+		rateLimit: c.RateLimit,
+		breaker:   c.Breaker,
 	}
 	return hts
 }
@@ -142,6 +148,15 @@ func (ts *JRequest) PATCHX(url string, body []byte, timeOut time.Duration) (*JRe
 }
 
 func (ts *JRequest) makeHttpRequest(url string, body []byte, timeout *time.Duration) error {
+	if err := ts.waitRateLimit(timeout); err != nil {
+		ts.Err = err
+		return err
+	}
+	done, err := ts.allowBreaker()
+	if err != nil {
+		ts.Err = err
+		return err
+	}
 	ts.SetUserAgent(UserAgent)
 	isStats := !ts.metricsSkipper && ts.statsd != nil
 	var t statsd.Timing
@@ -154,14 +169,17 @@ func (ts *JRequest) makeHttpRequest(url string, body []byte, timeout *time.Durat
 	resp := fasthttp.AcquireResponse()
 	if timeout == nil {
 		if err := ts.client.Do(ts.req, resp); err != nil {
+			done(err, 0)
 			ts.Err = err
 			return err
 		}
 	} else {
 		if err := ts.client.DoTimeout(ts.req, resp, *timeout); err != nil {
+			done(err, 0)
 			return err
 		}
 	}
+	done(nil, resp.StatusCode())
 	if string(resp.Header.Peek("Content-Encoding")) == "gzip" {
 		ts.BodyByte, _ = resp.BodyGunzip()
 	} else {
@@ -0,0 +1,54 @@
+package jrequest
+
+import (
+	"context"
+	"log"
+	"time"
+
+	searatelimit "github.com/ponlv/go-kit/redis/ratelimit"
+)
+
+// RateLimitConfig limit outbound requests across pods.
+type RateLimitConfig struct {
+	Limiter *searatelimit.Limiter
+	Key     string
+	Limit   searatelimit.Limit
+}
+
+// SetRateLimit limit this request with the given key and limit,
+// the request waits until it is allowed or the timeout is over.
+func (ts *JRequest) SetRateLimit(limiter *searatelimit.Limiter, key string, limit searatelimit.Limit) *JRequest {
+	ts.rateLimit = &RateLimitConfig{
+		Limiter: limiter,
+		Key:     key,
+		Limit:   limit,
+	}
+	return ts
+}
+
+// waitRateLimit block until the request is allowed by the rate limit.
+// The request is not limited when redis fails, a redis outage should not
+// stop the outbound calls.
+func (ts *JRequest) waitRateLimit(timeout *time.Duration) error {
+	if ts.rateLimit == nil || ts.rateLimit.Limiter == nil {
+		return nil
+	}
+
+	if timeout == nil {
+		timeout = ts.Timeout
+	}
+
+	ctx := context.Background()
+	if timeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	err := ts.rateLimit.Limiter.Wait(ctx, ts.rateLimit.Key, ts.rateLimit.Limit)
+	if err != nil && err != searatelimit.ErrLimited {
+		log.Println(`[utils.JRequest] rate limit check failed:`, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package jrequest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+	searatelimit "github.com/ponlv/go-kit/redis/ratelimit"
+)
+
+func TestWaitRateLimit(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	limit := searatelimit.Limit{Rate: 1, Period: time.Minute, Algorithm: searatelimit.FixedWindow}
+	timeout := 50 * time.Millisecond
+	ts := (&JRequest{}).SetRateLimit(searatelimit.NewLimiter(client), "partner", limit)
+
+	if err := ts.waitRateLimit(&timeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.waitRateLimit(&timeout); err != searatelimit.ErrLimited {
+		t.Fatalf("expected ErrLimited, got %v", err)
+	}
+
+	// fail open when redis is down
+	m.Close()
+	if err := ts.waitRateLimit(&timeout); err != nil {
+		t.Fatalf("expected the request to be allowed, got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -15,7 +18,30 @@ type CustomClaims struct {
 	jwt.RegisteredClaims
 }
 
+// ErrTokenRevoked is returned by VerifyJWTToken when the token id is in the denylist.
+var ErrTokenRevoked = errors.New("_TOKEN_REVOKED_")
+
+// Denylist report whether a token id (jti) was revoked.
+type Denylist interface {
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+var denylist Denylist
+
+// SetDenylist make VerifyJWTToken reject revoked tokens, nil disables the check.
+func SetDenylist(d Denylist) {
+	denylist = d
+}
+
+// GenerateJWTToken generate token with a random token id (jti), so it can be revoked.
 func GenerateJWTToken(key_sign, user_id, email, metadata, issuer string, expired int) (string, error) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return GenerateJWTTokenWithID(key_sign, user_id, email, metadata, issuer, hex.EncodeToString(id), expired)
+}
+
+// GenerateJWTTokenWithID generate token with the token id (jti).
+func GenerateJWTTokenWithID(key_sign, user_id, email, metadata, issuer, token_id string, expired int) (string, error) {
 	signingKey := []byte(key_sign)
 	// Create the claims
 	claims := CustomClaims{
@@ -26,6 +52,7 @@ func GenerateJWTToken(key_sign, user_id, email, metadata, issuer string, expired
 			// A usual scenario is to set the expiration time relative to the current time
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expired) * time.Second)),
 			Issuer:    issuer,
+			ID:        token_id,
 		},
 	}
 	//fmt.Printf("%+v\r\n",claims)
@@ -56,7 +83,13 @@ func TokenExpiredTime(key, token_string string) float64 {
 	return 0
 }
 
+// VerifyJWTToken verify the token, see VerifyJWTTokenWithCtx.
 func VerifyJWTToken(key, token_string string) (*CustomClaims, error) {
+	return VerifyJWTTokenWithCtx(context.Background(), key, token_string)
+}
+
+// VerifyJWTTokenWithCtx verify the token and, if a denylist is set, that it's not revoked.
+func VerifyJWTTokenWithCtx(ctx context.Context, key, token_string string) (*CustomClaims, error) {
 	if key == "" {
 		return nil, errors.New("_KEY_IS_EMPTY_")
 	}
@@ -73,8 +106,19 @@ func VerifyJWTToken(key, token_string string) (*CustomClaims, error) {
 		return nil, errors.New("PARSE_TOKEN_ERROR")
 	}
 	claim, ok := token.Claims.(*CustomClaims)
-	if ok && token.Valid {
-		return claim, nil
+	if !ok || !token.Valid {
+		return nil, err
+	}
+
+	if denylist != nil && claim.ID != "" {
+		revoked, err := denylist.IsRevoked(ctx, claim.ID)
+		if err != nil {
+			// fail closed, a revoked token must not pass while the denylist is down
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
 	}
-	return nil, err
+	return claim, nil
 }
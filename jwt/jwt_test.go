@@ -0,0 +1,35 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+)
+
+type fakeDenylist map[string]bool
+
+func (d fakeDenylist) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	return d[tokenID], nil
+}
+
+func TestVerifyJWTTokenDenylist(t *testing.T) {
+	token, err := GenerateJWTTokenWithID("secret", "42", "a@b.c", "", "test", "jti-1", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetDenylist(fakeDenylist{})
+	defer SetDenylist(nil)
+
+	claims, err := VerifyJWTToken("secret", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID != "jti-1" || claims.UserID != "42" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	SetDenylist(fakeDenylist{"jti-1": true})
+	if _, err = VerifyJWTToken("secret", token); err != ErrTokenRevoked {
+		t.Fatalf("expected ErrTokenRevoked, got %v", err)
+	}
+}
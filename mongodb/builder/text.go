@@ -0,0 +1,47 @@
+package builder
+
+import (
+	"github.com/ponlv/go-kit/mongodb/field"
+
+	o "github.com/kamva/mgm/v3/operator"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Text function return mongo $text operator to using in filters and $match stages.
+func Text(search string, language, caseSensitive, diacriticSensitive interface{}) Operator {
+	m := bson.M{field.Search: search}
+
+	appendIfHasVal(m, field.Language, language)
+	appendIfHasVal(m, field.CaseSensitive, caseSensitive)
+	appendIfHasVal(m, field.DiacriticSensitive, diacriticSensitive)
+
+	return New(o.Text, m)
+}
+
+// Meta function return mongo $meta expression, e.g Meta(field.TextScore).
+func Meta(keyword string) bson.M {
+	return bson.M{o.Meta: keyword}
+}
+
+// Search function return Atlas $search operator to using in aggregates.
+func Search(index interface{}, params bson.M) Operator {
+	m := bson.M{}
+
+	appendIfHasVal(m, field.Index, index)
+
+	for key, val := range params {
+		appendIfHasVal(m, key, val)
+	}
+
+	return New(field.Search, m)
+}
+
+// SearchText function return Atlas $search operator with text operator to using in aggregates.
+func SearchText(index, query, path interface{}) Operator {
+	text := bson.M{}
+
+	appendIfHasVal(text, field.Query, query)
+	appendIfHasVal(text, field.Path, path)
+
+	return Search(index, bson.M{field.Text: text})
+}
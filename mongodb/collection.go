@@ -15,6 +15,8 @@ import (
 // Collection performs operations on models and given Mongodb collection
 type Collection struct {
 	*mongo.Collection
+
+	tenant *TenantConfig
 }
 
 // FindByID method find a doc and decode it to model, otherwise return error.
@@ -147,13 +149,7 @@ func (coll *Collection) SimpleFind(results interface{}, filter interface{}, opts
 
 // SimpleFindWithCtx find and decode result to results.
 func (coll *Collection) SimpleFindWithCtx(ctx context.Context, results interface{}, filter interface{}, opts ...*options.FindOptions) error {
-	cur, err := coll.Find(ctx, filter, opts...)
-
-	if err != nil {
-		return err
-	}
-
-	return cur.All(ctx, results)
+	return findMany(ctx, coll, filter, results, opts...)
 }
 
 //--------------------------------
@@ -163,35 +159,65 @@ func (coll *Collection) SimpleFindWithCtx(ctx context.Context, results interface
 // SimpleAggregateFirst does simple aggregation and decode first aggregate result to the provided result param.
 // stages value can be Operator|bson.M
 // Note: you can not use this method in a transaction because it does not get context.
-// So you should use the regular aggregation method in transactions.
+// So you should use SimpleAggregateFirstWithCtx in transactions and on tenant-scoped collections.
 func (coll *Collection) SimpleAggregateFirst(result interface{}, stages ...interface{}) (bool, error) {
-	cur, err := coll.SimpleAggregateCursor(stages...)
+	return coll.SimpleAggregateFirstWithCtx(ctx(), result, stages...)
+}
+
+// SimpleAggregateFirstWithCtx does simple aggregation and decode first aggregate result to the provided result param.
+// stages value can be Operator|bson.M
+func (coll *Collection) SimpleAggregateFirstWithCtx(ctx context.Context, result interface{}, stages ...interface{}) (bool, error) {
+	cur, err := coll.SimpleAggregateCursorWithCtx(ctx, stages...)
 	if err != nil {
 		return false, err
 	}
-	if cur.Next(ctx()) {
+	defer cur.Close(ctx)
+
+	if cur.Next(ctx) {
 		return true, cur.Decode(result)
 	}
-	return false, nil
+	return false, cur.Err()
 }
 
 // SimpleAggregate does simple aggregation and decode aggregate result to the results.
 // stages value can be Operator|bson.M
 // Note: you can not use this method in a transaction because it does not get context.
-// So you should use the regular aggregation method in transactions.
+// So you should use SimpleAggregateWithCtx in transactions and on tenant-scoped collections.
 func (coll *Collection) SimpleAggregate(results interface{}, stages ...interface{}) error {
-	cur, err := coll.SimpleAggregateCursor(stages...)
+	return coll.SimpleAggregateWithCtx(ctx(), results, stages...)
+}
+
+// SimpleAggregateWithCtx does simple aggregation and decode aggregate result to the results.
+// stages value can be Operator|bson.M
+func (coll *Collection) SimpleAggregateWithCtx(ctx context.Context, results interface{}, stages ...interface{}) error {
+	cur, err := coll.SimpleAggregateCursorWithCtx(ctx, stages...)
 	if err != nil {
 		return err
 	}
 
-	return cur.All(ctx(), results)
+	return cur.All(ctx, results)
 }
 
 // SimpleAggregateCursor doing simple aggregation and return cursor.
 // Note: you can not use this method in a transaction because it does not get context.
-// So you should use the regular aggregation method in transactions.
+// So you should use SimpleAggregateCursorWithCtx in transactions and on tenant-scoped collections.
 func (coll *Collection) SimpleAggregateCursor(stages ...interface{}) (*mongo.Cursor, error) {
+	return coll.SimpleAggregateCursorWithCtx(ctx(), stages...)
+}
+
+// SimpleAggregateCursorWithCtx doing simple aggregation and return cursor.
+// On tenant-scoped collections the pipeline is scoped to the tenant of ctx.
+func (coll *Collection) SimpleAggregateCursorWithCtx(ctx context.Context, stages ...interface{}) (*mongo.Cursor, error) {
+	c, pipeline, err := coll.scopePipeline(ctx, aggregatePipeline(stages...))
+	if err != nil {
+		return nil, err
+	}
+	return c.Aggregate(ctx, pipeline, nil)
+}
+
+// aggregatePipeline convert stages to aggregation pipeline.
+// stages value can be Operator|bson.M
+func aggregatePipeline(stages ...interface{}) bson.A {
 	pipeline := bson.A{}
 
 	for _, stage := range stages {
@@ -202,5 +228,5 @@ func (coll *Collection) SimpleAggregateCursor(stages ...interface{}) (*mongo.Cur
 		}
 	}
 
-	return coll.Aggregate(ctx(), pipeline, nil)
+	return pipeline
 }
@@ -17,6 +17,11 @@ var dbName string
 type Config struct {
 	// Set to 10 second (10*time.Second) for example.
 	CtxTimeout time.Duration
+
+	// Tenant enable tenant-aware mode for models that implement
+	// `TenantModel`, leave it nil to disable. Operations on those models
+	// must use the WithCtx methods with a context from `WithTenant`.
+	Tenant *TenantConfig
 }
 
 // NewCtx function create and return new context with your specified timeout.
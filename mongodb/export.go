@@ -0,0 +1,258 @@
+package mongodb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ponlv/go-kit/mongodb/field"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportFormat is the Extended JSON mode used by `Export`.
+type ExportFormat int
+
+const (
+	// ExportRelaxed write documents as relaxed Extended JSON.
+	ExportRelaxed ExportFormat = iota
+	// ExportCanonical write documents as canonical Extended JSON.
+	ExportCanonical
+)
+
+// ImportMode define how `Import` write documents into collection.
+type ImportMode int
+
+const (
+	// ImportInsert insert every document, duplicated ids fail the import.
+	ImportInsert ImportMode = iota
+	// ImportUpsert replace documents by `_id`, insert them if not exists.
+	ImportUpsert
+)
+
+// ErrImportMode is returned by `Import` for an unknown import mode.
+var ErrImportMode = errors.New("mongodb: unknown import mode")
+
+// defaultImportBatchSize is the number of documents written per request.
+const defaultImportBatchSize = 500
+
+// maxImportLineSize is the max size of a line, it is bigger than the
+// 16MB document limit because Extended JSON is more verbose than bson.
+const maxImportLineSize = 64 * 1024 * 1024
+
+// ImportOptions struct contain extra options of `Import`.
+type ImportOptions struct {
+	// BatchSize is the number of documents written per request.
+	BatchSize int
+	// Progress is called after each written batch with
+	// total number of imported documents.
+	Progress func(imported int64)
+}
+
+// Export write documents matched the filter to w, one Extended JSON
+// document per line, and return number of exported documents.
+func Export(ctx context.Context, coll *Collection, filter interface{}, w io.Writer, format ExportFormat, opts ...*options.FindOptions) (int64, error) {
+	cur, err := findCursor(ctx, coll, filter, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return exportCursor(ctx, cur, w, format)
+}
+
+// exportCursor write documents of the cursor to w and always close the cursor.
+func exportCursor(ctx context.Context, cur *mongo.Cursor, w io.Writer, format ExportFormat) (int64, error) {
+	defer cur.Close(ctx)
+
+	bw := bufio.NewWriter(w)
+	canonical := format == ExportCanonical
+
+	var n int64
+	for cur.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cur.Current, canonical, false)
+		if err != nil {
+			return n, err
+		}
+		if _, err = bw.Write(line); err != nil {
+			return n, err
+		}
+		if err = bw.WriteByte('\n'); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := cur.Err(); err != nil {
+		return n, err
+	}
+
+	return n, bw.Flush()
+}
+
+// Import read Extended JSON lines from r and write them into collection,
+// return number of imported documents. Both canonical and relaxed
+// formats are accepted, empty lines are skipped. Documents of a batch are
+// written unordered, a failed batch does not stop the other documents of
+// the batch and only the written ones are counted.
+func Import(ctx context.Context, coll *Collection, r io.Reader, mode ImportMode, opts ...*ImportOptions) (int64, error) {
+	if mode != ImportInsert && mode != ImportUpsert {
+		return 0, ErrImportMode
+	}
+	opt := mergeImportOptions(opts...)
+
+	c, _, tenantID, err := coll.scope(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var prepare func(doc bson.D) bson.D
+	if tenantID != "" && coll.tenant.Strategy == TenantFieldStrategy {
+		prepare = func(doc bson.D) bson.D {
+			return setDocField(doc, coll.tenant.field(), tenantID)
+		}
+	}
+
+	return importLines(r, opt, prepare, func(batch []bson.D) (int64, error) {
+		return writeImportBatch(ctx, c, batch, mode)
+	})
+}
+
+// importLines decode Extended JSON lines of r into batches and write them.
+func importLines(r io.Reader, opt *ImportOptions, prepare func(doc bson.D) bson.D, write func(batch []bson.D) (int64, error)) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLineSize)
+
+	var imported int64
+	var lineNo int
+	batch := make([]bson.D, 0, opt.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		written, err := write(batch)
+		imported += written
+		if err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if opt.Progress != nil {
+			opt.Progress(imported)
+		}
+		return nil
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(line, false, &doc); err != nil {
+			return imported, fmt.Errorf("mongodb: import line %d: %w", lineNo, err)
+		}
+		if prepare != nil {
+			doc = prepare(doc)
+		}
+
+		batch = append(batch, doc)
+		if len(batch) >= opt.BatchSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+
+	return imported, flush()
+}
+
+func mergeImportOptions(opts ...*ImportOptions) *ImportOptions {
+	opt := &ImportOptions{BatchSize: defaultImportBatchSize}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.BatchSize > 0 {
+			opt.BatchSize = o.BatchSize
+		}
+		if o.Progress != nil {
+			opt.Progress = o.Progress
+		}
+	}
+	return opt
+}
+
+// writeImportBatch write the batch and return the number of written documents.
+func writeImportBatch(ctx context.Context, c *Collection, batch []bson.D, mode ImportMode) (int64, error) {
+	switch mode {
+	case ImportInsert:
+		docs := make([]interface{}, len(batch))
+		for i, doc := range batch {
+			docs[i] = doc
+		}
+		_, err := c.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		return writtenCount(len(batch), err), err
+
+	case ImportUpsert:
+		models := make([]mongo.WriteModel, len(batch))
+		for i, doc := range batch {
+			id, ok := docField(doc, field.ID)
+			if !ok {
+				models[i] = mongo.NewInsertOneModel().SetDocument(doc)
+				continue
+			}
+			filter := bson.M{field.ID: id}
+			if c.tenant != nil && c.tenant.Strategy == TenantFieldStrategy {
+				tenantID, _ := docField(doc, c.tenant.field())
+				filter[c.tenant.field()] = tenantID
+			}
+			models[i] = mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true)
+		}
+		_, err := c.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		return writtenCount(len(batch), err), err
+	}
+
+	return 0, ErrImportMode
+}
+
+// writtenCount return the number of documents written by an unordered
+// write of n documents, none are counted when the failure is unknown.
+func writtenCount(n int, err error) int64 {
+	if err == nil {
+		return int64(n)
+	}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		return int64(n - len(bulkErr.WriteErrors))
+	}
+	return 0
+}
+
+// docField return value of the top-level key in doc.
+func docField(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// setDocField set value of the top-level key in doc.
+func setDocField(doc bson.D, key string, value interface{}) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			doc[i].Value = value
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
@@ -0,0 +1,102 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestExportCursor(t *testing.T) {
+	docs := []interface{}{bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "a"}}}
+
+	for format, want := range map[ExportFormat]string{
+		ExportRelaxed:   `{"_id":1,"name":"a"}` + "\n",
+		ExportCanonical: `{"_id":{"$numberInt":"1"},"name":"a"}` + "\n",
+	} {
+		cur, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		n, err := exportCursor(context.Background(), cur, &buf, format)
+		if err != nil || n != 1 {
+			t.Fatalf("unexpected result %d, %v", n, err)
+		}
+		if buf.String() != want {
+			t.Fatalf("unexpected output: %s", buf.String())
+		}
+	}
+}
+
+func TestImportLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"_id":1,"name":"a"}`,
+		``,
+		`{"_id":{"$numberInt":"2"},"name":"b"}`,
+		`{"_id":3}`,
+	}, "\n")
+
+	var batches [][]bson.D
+	var progress []int64
+	prepare := func(doc bson.D) bson.D {
+		return setDocField(doc, "tenantId", "t1")
+	}
+	n, err := importLines(strings.NewReader(input), &ImportOptions{
+		BatchSize: 2,
+		Progress:  func(imported int64) { progress = append(progress, imported) },
+	}, prepare, func(batch []bson.D) (int64, error) {
+		batches = append(batches, append([]bson.D(nil), batch...))
+		return int64(len(batch)), nil
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("unexpected result %d, %v", n, err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+	want := bson.D{{Key: "_id", Value: int32(2)}, {Key: "name", Value: "b"}, {Key: "tenantId", Value: "t1"}}
+	if !reflect.DeepEqual(batches[0][1], want) {
+		t.Fatalf("unexpected document: %v", batches[0][1])
+	}
+	if !reflect.DeepEqual(progress, []int64{2, 3}) {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+}
+
+func TestImportLinesErrors(t *testing.T) {
+	write := func(batch []bson.D) (int64, error) {
+		return 1, errors.New("duplicate key")
+	}
+	n, err := importLines(strings.NewReader("{\"_id\":1}\n{\"_id\":2}"), &ImportOptions{BatchSize: 2}, nil, write)
+	if err == nil || n != 1 {
+		t.Fatalf("expected 1 imported document and an error, got %d, %v", n, err)
+	}
+
+	_, err = importLines(strings.NewReader("{\"_id\":1}\nnot json"), &ImportOptions{BatchSize: 2}, nil, write)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error on line 2, got %v", err)
+	}
+
+	if _, err = Import(context.Background(), newTestCollection(t), strings.NewReader(""), ImportMode(9)); err != ErrImportMode {
+		t.Fatalf("expected ErrImportMode, got %v", err)
+	}
+}
+
+func TestWrittenCount(t *testing.T) {
+	bulkErr := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{}, {}}}
+	if n := writtenCount(5, bulkErr); n != 3 {
+		t.Fatalf("expected 3 written documents, got %d", n)
+	}
+	if n := writtenCount(5, errors.New("network")); n != 0 {
+		t.Fatalf("expected 0 written documents, got %d", n)
+	}
+	if n := writtenCount(5, nil); n != 5 {
+		t.Fatalf("expected 5 written documents, got %d", n)
+	}
+}
@@ -19,6 +19,12 @@ type DateFields struct {
 	UpdatedAt int64 `json:"updatedAt" bson:"updatedAt,omitempty" `
 }
 
+// TenantField struct contain the `tenantId` field that
+// autofill on insert model into tenant-scoped collection.
+type TenantField struct {
+	TenantID string `json:"tenantId" bson:"tenantId,omitempty"`
+}
+
 // PrepareID method prepare id value to using it as id in filtering,...
 // e.g convert hex-string id value to bson.ObjectId
 //func (f *IDField) PrepareID(id interface{}) (interface{}, error) {
@@ -79,3 +85,17 @@ func (f *DateFields) Saving() error {
 	//f.UpdatedAt = time.Now().UTC()
 	return nil
 }
+
+//--------------------------------
+// TenantField methods
+//--------------------------------
+
+// GetTenantID method return model's tenant id
+func (f *TenantField) GetTenantID() string {
+	return f.TenantID
+}
+
+// SetTenantID set tenant id value of model's tenant field.
+func (f *TenantField) SetTenantID(id string) {
+	f.TenantID = id
+}
@@ -0,0 +1,23 @@
+package field
+
+// $text fields
+const (
+	Search             = "$search"
+	Language           = "$language"
+	CaseSensitive      = "$caseSensitive"
+	DiacriticSensitive = "$diacriticSensitive"
+)
+
+// $meta keywords
+const (
+	TextScore   = "textScore"
+	SearchScore = "searchScore"
+)
+
+// $search (Atlas Search) fields
+const (
+	Index = "index"
+	Text  = "text"
+	// Query = "query" // Declared
+	// Path  = "path" // Declared
+)
@@ -0,0 +1,197 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ponlv/go-kit/mongodb/field"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrStopIteration can be returned by iteration callbacks to stop
+// the iteration early without error.
+var ErrStopIteration = errors.New("mongodb: stop iteration")
+
+const defaultIterBatchSize = 100
+
+// BatchOptions struct contain options of `EachBatch`.
+type BatchOptions struct {
+	// BatchSize is the number of documents per batch, it's also
+	// used as cursor batch size, default to 100.
+	BatchSize int
+	// Workers is the number of batches processed in parallel, default to 1.
+	Workers int
+}
+
+// Each decode documents matched the filter one by one and call fn with
+// each of them, without loading whole result into memory.
+func Each[T any](ctx context.Context, coll *Collection, filter interface{}, fn func(T) error, opts ...*options.FindOptions) error {
+	cur, err := findCursor(ctx, coll, filter, opts...)
+	if err != nil {
+		return err
+	}
+	return eachCursor(ctx, cur, fn)
+}
+
+// EachAggregate does aggregation and call fn with each decoded result.
+// stages value can be Operator|bson.M
+func EachAggregate[T any](ctx context.Context, coll *Collection, fn func(T) error, stages ...interface{}) error {
+	c, pipeline, err := coll.scopePipeline(ctx, aggregatePipeline(stages...))
+	if err != nil {
+		return err
+	}
+	cur, err := c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return eachCursor(ctx, cur, fn)
+}
+
+// Stream decode documents matched the filter into the returned channel.
+// Both channels are closed when the cursor is exhausted, errors are sent
+// to the error channel. Cancel ctx to stop the stream early.
+func Stream[T any](ctx context.Context, coll *Collection, filter interface{}, opts ...*options.FindOptions) (<-chan T, <-chan error) {
+	out := make(chan T)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errs)
+
+		err := Each(ctx, coll, filter, func(v T) error {
+			select {
+			case out <- v:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return out, errs
+}
+
+// EachBatch decode documents matched the filter into batches and call fn
+// with each batch, batches are processed by parallel workers. The first
+// error stops the iteration and is returned, fn is not called with the
+// batches left after it.
+func EachBatch[T any](ctx context.Context, coll *Collection, filter interface{}, fn func([]T) error, batchOpts *BatchOptions, opts ...*options.FindOptions) error {
+	batchSize, workers := defaultIterBatchSize, 1
+	if batchOpts != nil {
+		if batchOpts.BatchSize > 0 {
+			batchSize = batchOpts.BatchSize
+		}
+		if batchOpts.Workers > 0 {
+			workers = batchOpts.Workers
+		}
+	}
+
+	opts = append([]*options.FindOptions{options.Find().SetBatchSize(int32(batchSize))}, opts...)
+	cur, err := findCursor(ctx, coll, filter, opts...)
+	if err != nil {
+		return err
+	}
+	return eachBatchCursor(ctx, cur, fn, batchSize, workers)
+}
+
+// eachBatchCursor split documents of the cursor into batches for the workers.
+func eachBatchCursor[T any](ctx context.Context, cur *mongo.Cursor, fn func([]T) error, batchSize, workers int) error {
+	g, gctx := errgroup.WithContext(ctx)
+	batches := make(chan []T, workers)
+
+	g.Go(func() error {
+		defer close(batches)
+
+		batch := make([]T, 0, batchSize)
+		err := eachCursor(gctx, cur, func(v T) error {
+			batch = append(batch, v)
+			if len(batch) < batchSize {
+				return nil
+			}
+			select {
+			case batches <- batch:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+			batch = make([]T, 0, batchSize)
+			return nil
+		})
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		select {
+		case batches <- batch:
+			return nil
+		case <-gctx.Done():
+			return gctx.Err()
+		}
+	})
+
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for {
+				var batch []T
+				var ok bool
+				select {
+				case batch, ok = <-batches:
+					if !ok {
+						return nil
+					}
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+				// select pick randomly when a batch is ready too
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(batch); err != nil {
+					return err
+				}
+			}
+		})
+	}
+
+	err := g.Wait()
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+func findCursor(ctx context.Context, coll *Collection, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	c, filter, _, err := coll.scope(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = field.Empty
+	}
+	return c.Find(ctx, filter, opts...)
+}
+
+// eachCursor call fn with each decoded document and always close the cursor.
+func eachCursor[T any](ctx context.Context, cur *mongo.Cursor, fn func(T) error) error {
+	defer cur.Close(context.Background())
+
+	for cur.Next(ctx) {
+		var v T
+		if err := cur.Decode(&v); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return cur.Err()
+}
@@ -0,0 +1,119 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type iterDoc struct {
+	N int `bson:"n"`
+}
+
+func newTestCursor(t *testing.T, n int) *mongo.Cursor {
+	docs := make([]interface{}, n)
+	for i := range docs {
+		docs[i] = bson.D{{Key: "n", Value: i}}
+	}
+	cur, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cur
+}
+
+func TestEachStop(t *testing.T) {
+	var seen []int
+	err := eachCursor(context.Background(), newTestCursor(t, 10), func(d iterDoc) error {
+		seen = append(seen, d.N)
+		if len(seen) == 3 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || len(seen) != 3 {
+		t.Fatalf("unexpected result %v, %v", seen, err)
+	}
+
+	errBoom := errors.New("boom")
+	err = eachCursor(context.Background(), newTestCursor(t, 10), func(d iterDoc) error {
+		return errBoom
+	})
+	if err != errBoom {
+		t.Fatalf("expected errBoom, got %v", err)
+	}
+}
+
+func TestEachTenantRequired(t *testing.T) {
+	coll := newTestCollection(t).ScopeTenant(&TenantConfig{})
+
+	err := Each(context.Background(), coll, nil, func(d iterDoc) error { return nil })
+	if err != ErrTenantRequired {
+		t.Fatalf("expected ErrTenantRequired, got %v", err)
+	}
+	err = EachBatch(context.Background(), coll, nil, func(d []iterDoc) error { return nil }, nil)
+	if err != ErrTenantRequired {
+		t.Fatalf("expected ErrTenantRequired, got %v", err)
+	}
+}
+
+func TestEachBatch(t *testing.T) {
+	var mu sync.Mutex
+	var sizes, seen []int
+	err := eachBatchCursor(context.Background(), newTestCursor(t, 10), func(batch []iterDoc) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, len(batch))
+		for _, d := range batch {
+			seen = append(seen, d.N)
+		}
+		return nil
+	}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Ints(sizes)
+	sort.Ints(seen)
+	if len(sizes) != 4 || sizes[0] != 1 || sizes[3] != 3 {
+		t.Fatalf("unexpected batch sizes: %v", sizes)
+	}
+	for i, n := range seen {
+		if n != i {
+			t.Fatalf("unexpected documents: %v", seen)
+		}
+	}
+}
+
+func TestEachBatchStop(t *testing.T) {
+	const workers = 4
+	for _, stopErr := range []error{ErrStopIteration, errors.New("boom")} {
+		var calls int32
+		err := eachBatchCursor(context.Background(), newTestCursor(t, 200), func(batch []iterDoc) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return stopErr
+			}
+			// still running when the first batch fails
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, 1, workers)
+
+		if stopErr == ErrStopIteration && err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		if stopErr != ErrStopIteration && err != stopErr {
+			t.Fatalf("expected %v, got %v", stopErr, err)
+		}
+		// only the calls already running when the first one failed
+		if n := atomic.LoadInt32(&calls); n > workers {
+			t.Fatalf("fn called %d times after the iteration stopped", n)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/ponlv/go-kit/mongodb/field"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrLockFailed is returned when the lock is held by another owner
+	// after all tries.
+	ErrLockFailed = errors.New("mongodb: failed to acquire lock")
+	// ErrLockNotHeld is returned when extending or releasing a lock
+	// that is expired or taken by another owner.
+	ErrLockNotHeld = errors.New("mongodb: lock is not held")
+	// ErrLockNotInit is returned when `InitLock` was not called.
+	ErrLockNotInit = errors.New("mongodb: lock collection is not initialized")
+)
+
+const (
+	defaultLockExpiry = 8 * time.Second
+	defaultLockTries  = 32
+	minLockRetryDelay = 50 * time.Millisecond
+	maxLockRetryDelay = 250 * time.Millisecond
+)
+
+// lock document fields
+const (
+	lockOwnerField     = "owner"
+	lockExpiresAtField = "expiresAt"
+)
+
+var lockColl *Collection
+
+// InitLock set the collection that stores locks and create its TTL index,
+// so expired locks are cleaned up by mongodb. Locks use update pipelines
+// and $$NOW, they need MongoDB 4.2 or later.
+func InitLock(ctx context.Context, coll *Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: lockExpiresAtField, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+	lockColl = coll
+	return nil
+}
+
+// LockOption configurates Mutex with defined option.
+type LockOption func(*Mutex)
+
+// WithLockExpiry return LockOption to configure lock expiry.
+func WithLockExpiry(expiry time.Duration) LockOption {
+	return func(m *Mutex) {
+		m.expiry = expiry
+	}
+}
+
+// WithLockTries return LockOption to configure number of tries.
+func WithLockTries(tries int) LockOption {
+	return func(m *Mutex) {
+		m.tries = tries
+	}
+}
+
+// WithLockRetryDelay return LockOption to configure delay between tries.
+func WithLockRetryDelay(delay time.Duration) LockOption {
+	return func(m *Mutex) {
+		m.retryDelay = delay
+	}
+}
+
+// WithLockHeartbeat return LockOption to extend the lock every interval
+// while it is held, until `Unlock`. A failed extension is retried on the
+// next interval, the lock is only reported lost when it's taken by another
+// owner or would expire before the next try.
+func WithLockHeartbeat(interval time.Duration) LockOption {
+	return func(m *Mutex) {
+		m.heartbeat = interval
+	}
+}
+
+// Mutex is a distributed lock stored in the lock collection.
+type Mutex struct {
+	name       string
+	owner      string
+	expiry     time.Duration
+	tries      int
+	retryDelay time.Duration
+	heartbeat  time.Duration
+	coll       *Collection
+
+	mu       sync.Mutex
+	until    time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// Lock, func: we lock the mutex 8 seconds (timeout), 32 tries, each try is random between 50 - 250ms apart
+func Lock(mutexId string) (*Mutex, error) {
+	return LockCustom(mutexId)
+}
+
+// LockTimeout, func: we lock the mutex n (SECONDs), 32 tries, each try is random between 50 - 250ms apart
+func LockTimeout(mutexId string, timeout int) (*Mutex, error) {
+	return LockCustom(mutexId, WithLockExpiry(time.Duration(timeout)*time.Second))
+}
+
+// LockCustom lock the mutex with given options.
+func LockCustom(mutexId string, opts ...LockOption) (*Mutex, error) {
+	return LockWithCtx(context.Background(), mutexId, opts...)
+}
+
+// LockWithCtx lock the mutex with given options, it stops retrying when ctx
+// is done. ctx only bound the acquisition, the heartbeat keeps the lock
+// until `Unlock`.
+func LockWithCtx(ctx context.Context, mutexId string, opts ...LockOption) (*Mutex, error) {
+	if lockColl == nil {
+		return nil, ErrLockNotInit
+	}
+
+	m := &Mutex{
+		name:   mutexId,
+		owner:  lockToken(),
+		expiry: defaultLockExpiry,
+		tries:  defaultLockTries,
+		coll:   lockColl,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(m)
+	}
+
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Unlock release the mutex, return false if the lock was not held anymore.
+func Unlock(mutex *Mutex) (bool, error) {
+	return mutex.Unlock()
+}
+
+// Name return mutex's name.
+func (m *Mutex) Name() string {
+	return m.name
+}
+
+// Owner return the owner token of the mutex.
+func (m *Mutex) Owner() string {
+	return m.owner
+}
+
+// Until return the time that the lock expires, measured by the local clock
+// from before the last acquisition or extension, so it is never later than
+// the expiry kept by the server.
+func (m *Mutex) Until() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.until
+}
+
+// Done return a channel that is closed when the lock is not held
+// anymore, either released or failed to be extended by the heartbeat.
+func (m *Mutex) Done() <-chan struct{} {
+	return m.done
+}
+
+// Extend reset the mutex's expiry.
+func (m *Mutex) Extend(ctx context.Context) error {
+	until := time.Now().Add(m.expiry)
+
+	res, err := m.coll.UpdateOne(ctx, bson.M{
+		field.ID:       m.name,
+		lockOwnerField: m.owner,
+		"$expr":        bson.M{"$gt": bson.A{"$" + lockExpiresAtField, "$$NOW"}},
+	}, bson.A{bson.M{"$set": bson.M{lockExpiresAtField: m.serverExpiry()}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrLockNotHeld
+	}
+
+	m.mu.Lock()
+	m.until = until
+	m.mu.Unlock()
+	return nil
+}
+
+// Unlock release the mutex, return false if the lock was not held anymore.
+func (m *Mutex) Unlock() (bool, error) {
+	m.stopHeartbeat()
+	m.markDone()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.expiry)
+	defer cancel()
+
+	res, err := m.coll.DeleteOne(ctx, bson.M{field.ID: m.name, lockOwnerField: m.owner})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount == 1, nil
+}
+
+func (m *Mutex) lock(ctx context.Context) error {
+	for i := 0; i < m.tries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(m.delay()):
+			}
+		}
+
+		ok, err := m.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.startHeartbeat()
+			return nil
+		}
+	}
+	return ErrLockFailed
+}
+
+// acquire take the lock if it is free, expired or already owned. Expiry is
+// compared and set with the server time, so owners with skewed clocks
+// cannot take a lock that is still held.
+func (m *Mutex) acquire(ctx context.Context) (bool, error) {
+	until := time.Now().Add(m.expiry)
+
+	_, err := m.coll.UpdateOne(ctx, bson.M{
+		field.ID: m.name,
+		"$or": bson.A{
+			bson.M{"$expr": bson.M{"$lte": bson.A{"$" + lockExpiresAtField, "$$NOW"}}},
+			bson.M{lockOwnerField: m.owner},
+		},
+	}, bson.A{bson.M{"$set": bson.M{
+		lockOwnerField:     m.owner,
+		lockExpiresAtField: m.serverExpiry(),
+	}}}, UpsertTrueOption())
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	m.mu.Lock()
+	m.until = until
+	m.mu.Unlock()
+	return true, nil
+}
+
+func (m *Mutex) startHeartbeat() {
+	if m.heartbeat <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), m.heartbeat)
+				err := m.Extend(ctx)
+				cancel()
+				if heartbeatLost(err, time.Now(), m.Until(), m.heartbeat) {
+					m.markDone()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// heartbeatLost report whether a failed extension lose the lock: it's
+// taken by another owner, or it expires before the next try.
+func heartbeatLost(err error, now, until time.Time, interval time.Duration) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrLockNotHeld) {
+		return true
+	}
+	return !now.Add(interval).Before(until)
+}
+
+func (m *Mutex) stopHeartbeat() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+func (m *Mutex) markDone() {
+	m.doneOnce.Do(func() { close(m.done) })
+}
+
+// serverExpiry return the update pipeline expression of the expiry, from
+// the server time.
+func (m *Mutex) serverExpiry() bson.M {
+	return bson.M{"$add": bson.A{"$$NOW", m.expiry.Milliseconds()}}
+}
+
+func (m *Mutex) delay() time.Duration {
+	if m.retryDelay > 0 {
+		return m.retryDelay
+	}
+	return minLockRetryDelay + time.Duration(mrand.Int63n(int64(maxLockRetryDelay-minLockRetryDelay)))
+}
+
+// lockToken return random token that identify the lock owner.
+func lockToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
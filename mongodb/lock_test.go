@@ -0,0 +1,181 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestHeartbeatLost(t *testing.T) {
+	now := time.Now()
+	until := now.Add(8 * time.Second)
+
+	tests := []struct {
+		name  string
+		err   error
+		until time.Time
+		want  bool
+	}{
+		{"extended", nil, until, false},
+		{"taken", ErrLockNotHeld, until, true},
+		{"transient", errors.New("timeout"), until, false},
+		{"transient near expiry", errors.New("timeout"), now.Add(time.Second), true},
+	}
+	for _, tt := range tests {
+		if got := heartbeatLost(tt.err, now, tt.until, 2*time.Second); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestLockOptions(t *testing.T) {
+	if _, err := LockWithCtx(context.Background(), "job"); err != ErrLockNotInit {
+		t.Fatalf("expected ErrLockNotInit, got %v", err)
+	}
+
+	m := &Mutex{}
+	for _, o := range []LockOption{WithLockExpiry(time.Minute), WithLockTries(3), WithLockRetryDelay(time.Second), WithLockHeartbeat(10 * time.Second)} {
+		o(m)
+	}
+	if m.expiry != time.Minute || m.tries != 3 || m.delay() != time.Second || m.heartbeat != 10*time.Second {
+		t.Fatalf("unexpected options: %+v", m)
+	}
+
+	m.retryDelay = 0
+	for i := 0; i < 100; i++ {
+		if d := m.delay(); d < minLockRetryDelay || d >= maxLockRetryDelay {
+			t.Fatalf("delay out of range: %s", d)
+		}
+	}
+
+	if a, b := lockToken(), lockToken(); a == b || len(a) != 32 {
+		t.Fatalf("unexpected tokens %s, %s", a, b)
+	}
+}
+
+// useLockColl set the lock collection of the mock client for the test.
+func useLockColl(mt *mtest.T) {
+	old := lockColl
+	lockColl = NewCollection(mt.DB, "locks")
+	mt.Cleanup(func() { lockColl = old })
+}
+
+func updateResponse(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+func TestLock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("acquire", func(mt *mtest.T) {
+		useLockColl(mt)
+		mt.AddMockResponses(updateResponse(1))
+
+		m, err := LockCustom("job", WithLockExpiry(time.Minute))
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if time.Until(m.Until()) > time.Minute || time.Until(m.Until()) < 50*time.Second {
+			mt.Fatalf("unexpected expiry: %s", m.Until())
+		}
+
+		// the expiry is compared and set with the server time
+		cmd := mt.GetStartedEvent().Command
+		update := cmd.Lookup("updates").Array().Index(0).Value().Document()
+		if !update.Lookup("upsert").Boolean() {
+			mt.Fatalf("expected upsert: %v", update)
+		}
+		expires := update.Lookup("u").Array().Index(0).Value().Document().Lookup("$set", lockExpiresAtField)
+		add := expires.Document().Lookup("$add").Array()
+		if now, _ := add.Index(0).Value().StringValueOK(); now != "$$NOW" || add.Index(1).Value().Int64() != time.Minute.Milliseconds() {
+			mt.Fatalf("unexpected expiry: %v", expires)
+		}
+		if _, err := update.LookupErr("q", "$or"); err != nil {
+			mt.Fatalf("unexpected filter: %v", update)
+		}
+	})
+
+	mt.Run("contention", func(mt *mtest.T) {
+		useLockColl(mt)
+		dup := mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"})
+		mt.AddMockResponses(dup, dup)
+
+		_, err := LockCustom("job", WithLockTries(2), WithLockRetryDelay(time.Millisecond))
+		if err != ErrLockFailed {
+			mt.Fatalf("expected ErrLockFailed, got %v", err)
+		}
+	})
+
+	mt.Run("extend", func(mt *mtest.T) {
+		useLockColl(mt)
+		mt.AddMockResponses(updateResponse(1), updateResponse(1), updateResponse(0))
+
+		m, err := LockCustom("job")
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.ClearEvents()
+		if err := m.Extend(context.Background()); err != nil {
+			mt.Fatal(err)
+		}
+		q := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		if owner, _ := q.Lookup(lockOwnerField).StringValueOK(); owner != m.Owner() {
+			mt.Fatalf("unexpected filter: %v", q)
+		}
+		if _, err := q.LookupErr("$expr", "$gt"); err != nil {
+			mt.Fatalf("unexpected filter: %v", q)
+		}
+
+		if err := m.Extend(context.Background()); err != ErrLockNotHeld {
+			mt.Fatalf("expected ErrLockNotHeld, got %v", err)
+		}
+	})
+
+	mt.Run("lost", func(mt *mtest.T) {
+		useLockColl(mt)
+		mt.AddMockResponses(updateResponse(1), updateResponse(0))
+
+		m, err := LockCustom("job", WithLockHeartbeat(10*time.Millisecond))
+		if err != nil {
+			mt.Fatal(err)
+		}
+		select {
+		case <-m.Done():
+		case <-time.After(time.Second):
+			mt.Fatal("Done should be closed when the lock is taken")
+		}
+	})
+
+	mt.Run("unlock", func(mt *mtest.T) {
+		useLockColl(mt)
+		mt.AddMockResponses(updateResponse(1), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			updateResponse(1), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}))
+
+		m, err := LockCustom("job")
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if ok, err := m.Unlock(); !ok || err != nil {
+			mt.Fatalf("unexpected unlock result %v, %v", ok, err)
+		}
+		select {
+		case <-m.Done():
+		default:
+			mt.Fatal("Done should be closed on unlock")
+		}
+
+		// released after it was taken by another owner
+		m, err = LockCustom("job")
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if ok, err := Unlock(m); ok || err != nil {
+			mt.Fatalf("unexpected unlock result %v, %v", ok, err)
+		}
+	})
+}
@@ -27,6 +27,14 @@ type Model interface {
 	SetID(id interface{})
 }
 
+// TenantModel interface is implemented by models that
+// stored in tenant-scoped collections, If you're using
+// `TenantField` struct in your model, don't need to implement it.
+type TenantModel interface {
+	GetTenantID() string
+	SetTenantID(id string)
+}
+
 // DefaultModel struct contain model's default fields.
 type DefaultModel struct {
 	IDField `bson:",inline"`
@@ -10,6 +10,12 @@ import (
 )
 
 func create(ctx context.Context, c *Collection, model Model, opts ...*options.InsertOneOptions) (interface{}, error) {
+	c, _, tenantID, err := c.scope(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	stampTenant(model, tenantID)
+
 	// Call to saving hook
 	if err := callToBeforeCreateHooks(model); err != nil {
 		return nil, err
@@ -30,9 +36,17 @@ func create(ctx context.Context, c *Collection, model Model, opts ...*options.In
 }
 
 func createMany(ctx context.Context, c *Collection, documents []interface{}, opts ...*options.InsertManyOptions) error {
+	c, _, tenantID, err := c.scope(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		stampTenant(doc, tenantID)
+	}
+
 	//TODO update check hook
 	//documents := util.InterfaceSlice(models)
-	_, err := c.InsertMany(ctx, documents, opts...)
+	_, err = c.InsertMany(ctx, documents, opts...)
 
 	if err != nil {
 		return err
@@ -41,14 +55,31 @@ func createMany(ctx context.Context, c *Collection, documents []interface{}, opt
 }
 
 func first(ctx context.Context, c *Collection, filter interface{}, model Model, opts ...*options.FindOneOptions) error {
+	c, filter, _, err := c.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
 	return c.FindOne(ctx, filter, opts...).Decode(model)
 }
 
 func firstAndUpdate(ctx context.Context, c *Collection, filter interface{}, update interface{}, model Model, opts ...*options.FindOneAndUpdateOptions) error {
+	if c.tenant != nil && c.tenant.Strategy == TenantFieldStrategy {
+		if err := checkTenantUpdate(update, c.tenant.field()); err != nil {
+			return err
+		}
+	}
+	c, filter, _, err := c.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
 	return c.FindOneAndUpdate(ctx, filter, update, opts...).Decode(model)
 }
 
 func findMany(ctx context.Context, c *Collection, filter, results interface{}, opts ...*options.FindOptions) error {
+	c, filter, _, err := c.scope(ctx, filter)
+	if err != nil {
+		return err
+	}
 	cur, err := c.Find(ctx, filter, opts...)
 
 	if err != nil {
@@ -59,11 +90,18 @@ func findMany(ctx context.Context, c *Collection, filter, results interface{}, o
 }
 
 func update(ctx context.Context, c *Collection, model Model, opts ...*options.UpdateOptions) error {
+	c, filter, tenantID, err := c.scope(ctx, bson.M{field.ID: model.GetID()})
+	if err != nil {
+		return err
+	}
+
 	// Call to saving hook
 	if err := callToBeforeUpdateHooks(model); err != nil {
 		return err
 	}
-	res, err := c.UpdateOne(ctx, bson.M{field.ID: model.GetID()}, bson.M{"$set": model}, opts...)
+	// the model may carry another tenant id, keep the one of the ctx
+	stampTenant(model, tenantID)
+	res, err := c.UpdateOne(ctx, filter, bson.M{"$set": model}, opts...)
 
 	if err != nil {
 		return err
@@ -73,10 +111,15 @@ func update(ctx context.Context, c *Collection, model Model, opts ...*options.Up
 }
 
 func del(ctx context.Context, c *Collection, model Model) error {
+	c, filter, _, err := c.scope(ctx, bson.M{field.ID: model.GetID()})
+	if err != nil {
+		return err
+	}
+
 	if err := callToBeforeDeleteHooks(model); err != nil {
 		return err
 	}
-	res, err := c.DeleteOne(ctx, bson.M{field.ID: model.GetID()})
+	res, err := c.DeleteOne(ctx, filter)
 	if err != nil {
 		return err
 	}
@@ -84,6 +127,10 @@ func del(ctx context.Context, c *Collection, model Model) error {
 	return callToAfterDeleteHooks(res, model)
 }
 func count(ctx context.Context, c *Collection, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	c, filter, _, err := c.scope(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
 	count, err := c.CountDocuments(ctx, filter, opts...)
 	return count, err
 }
@@ -0,0 +1,264 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTenantRequired is returned by tenant-scoped collections when
+// the context does not carry a tenant id.
+var ErrTenantRequired = errors.New("mongodb: tenant id is required")
+
+// ErrTenantUpdate is returned by tenant-scoped collections when an update
+// would change the tenant field of the document.
+var ErrTenantUpdate = errors.New("mongodb: update cannot change the tenant field")
+
+// TenantStrategy define how documents of different tenants are isolated.
+type TenantStrategy int
+
+const (
+	// TenantFieldStrategy keeps every tenant in a shared collection and
+	// scope all queries by the tenant field.
+	TenantFieldStrategy TenantStrategy = iota
+	// TenantDatabaseStrategy keeps every tenant in its own database.
+	TenantDatabaseStrategy
+)
+
+// DefaultTenantField is the bson field used to store the tenant id.
+const DefaultTenantField = "tenantId"
+
+// TenantConfig struct contain config of the tenant-aware mode.
+type TenantConfig struct {
+	Strategy TenantStrategy
+	// Field is the tenant field name, default to `DefaultTenantField`.
+	Field string
+	// DatabaseName return the database of a tenant when using
+	// `TenantDatabaseStrategy`, default to "<db>_<tenantID>".
+	DatabaseName func(db, tenantID string) string
+}
+
+type tenantCtxKey struct{}
+
+// WithTenant return new context that carry the tenant id.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromCtx return tenant id of the context, if any.
+func TenantFromCtx(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantCtxKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// ScopeTenant return a copy of collection that scope every operation
+// to the tenant of the given context.
+// Note: methods without context (First, Count, SimpleAggregate...) use a
+// background context that never carries a tenant, so they always fail with
+// `ErrTenantRequired` on scoped collections, use their WithCtx variant.
+// The embedded *mongo.Collection methods are not scoped.
+func (coll *Collection) ScopeTenant(conf *TenantConfig) *Collection {
+	return &Collection{Collection: coll.Collection, tenant: conf}
+}
+
+func (conf *TenantConfig) field() string {
+	if conf.Field == "" {
+		return DefaultTenantField
+	}
+	return conf.Field
+}
+
+func (conf *TenantConfig) databaseName(db, tenantID string) string {
+	if conf.DatabaseName == nil {
+		return db + "_" + tenantID
+	}
+	return conf.DatabaseName(db, tenantID)
+}
+
+// tenantConfigFor return the default tenant config if the model is
+// tenant-aware, otherwise return nil.
+func tenantConfigFor(m Model) *TenantConfig {
+	if config == nil || config.Tenant == nil {
+		return nil
+	}
+	if _, ok := m.(TenantModel); !ok {
+		return nil
+	}
+	return config.Tenant
+}
+
+// scope resolve collection and filter of the tenant carried by ctx.
+// Collections that are not tenant-scoped are returned as is.
+func (coll *Collection) scope(ctx context.Context, filter interface{}) (*Collection, interface{}, string, error) {
+	if coll.tenant == nil {
+		return coll, filter, "", nil
+	}
+
+	tenantID, ok := TenantFromCtx(ctx)
+	if !ok {
+		return nil, nil, "", ErrTenantRequired
+	}
+
+	if coll.tenant.Strategy == TenantDatabaseStrategy {
+		db := coll.Database()
+		name := coll.tenant.databaseName(db.Name(), tenantID)
+		tenantColl := db.Client().Database(name).Collection(coll.Name())
+
+		return &Collection{Collection: tenantColl}, filter, tenantID, nil
+	}
+
+	return coll, tenantFilter(filter, coll.tenant.field(), tenantID), tenantID, nil
+}
+
+// scopePipeline resolve collection of the tenant carried by ctx and prepend
+// a $match on the tenant field to the pipeline. When the first stage is a
+// $match, the tenant condition is added to it instead, so a $text query
+// stays in the first stage. Other stages that must be first, such as
+// $geoNear, are only supported with `TenantDatabaseStrategy`.
+func (coll *Collection) scopePipeline(ctx context.Context, pipeline bson.A) (*Collection, bson.A, error) {
+	c, _, tenantID, err := coll.scope(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if coll.tenant == nil || coll.tenant.Strategy == TenantDatabaseStrategy {
+		return c, pipeline, nil
+	}
+
+	field := coll.tenant.field()
+	if len(pipeline) > 0 {
+		if match, ok := matchStage(pipeline[0]); ok {
+			scoped := append(bson.A{bson.M{"$match": tenantFilter(match, field, tenantID)}}, pipeline[1:]...)
+			return c, scoped, nil
+		}
+	}
+	return c, append(bson.A{bson.M{"$match": tenantFilter(nil, field, tenantID)}}, pipeline...), nil
+}
+
+// matchStage return the filter of the stage if it is a $match stage.
+func matchStage(stage interface{}) (interface{}, bool) {
+	switch s := stage.(type) {
+	case bson.M:
+		if match, ok := s["$match"]; ok && len(s) == 1 {
+			return match, true
+		}
+	case bson.D:
+		if len(s) == 1 && s[0].Key == "$match" {
+			return s[0].Value, true
+		}
+	}
+	return nil, false
+}
+
+// tenantFilter append tenant condition to the filter.
+func tenantFilter(filter interface{}, field string, tenantID string) interface{} {
+	cond := bson.M{field: tenantID}
+
+	switch f := filter.(type) {
+	case nil:
+		return cond
+	case bson.M:
+		if len(f) == 0 {
+			return cond
+		}
+	case bson.D:
+		if len(f) == 0 {
+			return cond
+		}
+	}
+
+	return bson.M{"$and": bson.A{filter, cond}}
+}
+
+// stampTenant set tenant id of the model if it is tenant-aware.
+func stampTenant(model interface{}, tenantID string) {
+	if tenantID == "" {
+		return
+	}
+	if m, ok := model.(TenantModel); ok {
+		m.SetTenantID(tenantID)
+	}
+}
+
+// checkTenantUpdate return `ErrTenantUpdate` if the update document or
+// pipeline writes, renames, removes or replaces the tenant field.
+func checkTenantUpdate(update interface{}, field string) error {
+	var stages []interface{}
+	switch u := update.(type) {
+	case bson.A:
+		stages = u
+	case []interface{}:
+		stages = u
+	case mongo.Pipeline:
+		for _, stage := range u {
+			stages = append(stages, stage)
+		}
+	case []bson.M:
+		for _, stage := range u {
+			stages = append(stages, stage)
+		}
+	default:
+		stages = []interface{}{update}
+	}
+
+	for _, stage := range stages {
+		raw, err := bson.Marshal(stage)
+		if err != nil {
+			return err
+		}
+		ops, err := bson.Raw(raw).Elements()
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if touchesTenant(op.Key(), op.Value(), field) {
+				return ErrTenantUpdate
+			}
+		}
+	}
+	return nil
+}
+
+// touchesTenant report whether the update operator or pipeline stage
+// changes the tenant field.
+func touchesTenant(op string, value bson.RawValue, field string) bool {
+	isField := func(name string) bool {
+		return name == field || strings.HasPrefix(name, field+".")
+	}
+
+	switch op {
+	case "$replaceRoot", "$replaceWith", "$project":
+		return true
+	case "$unset":
+		// the pipeline stage takes a field name or an array of names
+		if name, ok := value.StringValueOK(); ok {
+			return isField(name)
+		}
+		if names, ok := value.ArrayOK(); ok {
+			values, _ := names.Values()
+			for _, v := range values {
+				if name, ok := v.StringValueOK(); ok && isField(name) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	doc, ok := value.DocumentOK()
+	if !ok {
+		return false
+	}
+	elems, _ := doc.Elements()
+	for _, e := range elems {
+		if isField(e.Key()) {
+			return true
+		}
+		if name, ok := e.Value().StringValueOK(); ok && op == "$rename" && isField(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package mongodb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type tenantDoc struct {
+	DefaultModel `bson:",inline"`
+	TenantField  `bson:",inline"`
+	Name         string `bson:"name"`
+}
+
+func newTestCollection(t *testing.T) *Collection {
+	c, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCollection(c.Database("shop"), "orders")
+}
+
+func TestTenantFilter(t *testing.T) {
+	got := tenantFilter(nil, "tenantId", "t1")
+	if !reflect.DeepEqual(got, bson.M{"tenantId": "t1"}) {
+		t.Fatalf("unexpected filter: %v", got)
+	}
+
+	filter := bson.M{"name": "a"}
+	got = tenantFilter(filter, "tenantId", "t1")
+	want := bson.M{"$and": bson.A{filter, bson.M{"tenantId": "t1"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected filter: %v", got)
+	}
+}
+
+func TestScopeFailClosed(t *testing.T) {
+	coll := newTestCollection(t).ScopeTenant(&TenantConfig{})
+
+	if _, _, _, err := coll.scope(context.Background(), nil); err != ErrTenantRequired {
+		t.Fatalf("expected ErrTenantRequired, got %v", err)
+	}
+}
+
+func TestScopePipeline(t *testing.T) {
+	coll := newTestCollection(t).ScopeTenant(&TenantConfig{})
+	stages := bson.A{bson.M{"$sort": bson.M{"name": 1}}}
+
+	if _, _, err := coll.scopePipeline(context.Background(), stages); err != ErrTenantRequired {
+		t.Fatalf("expected ErrTenantRequired, got %v", err)
+	}
+	if _, err := coll.SimpleAggregateCursorWithCtx(context.Background(), stages...); err != ErrTenantRequired {
+		t.Fatalf("expected ErrTenantRequired, got %v", err)
+	}
+
+	_, pipeline, err := coll.scopePipeline(WithTenant(context.Background(), "t1"), stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.A{bson.M{"$match": bson.M{"tenantId": "t1"}}, bson.M{"$sort": bson.M{"name": 1}}}
+	if !reflect.DeepEqual(pipeline, want) {
+		t.Fatalf("unexpected pipeline: %v", pipeline)
+	}
+
+	_, pipeline, err = newTestCollection(t).scopePipeline(context.Background(), stages)
+	if err != nil || !reflect.DeepEqual(pipeline, stages) {
+		t.Fatalf("unexpected pipeline %v, err %v", pipeline, err)
+	}
+}
+
+func TestScopePipelineTextSearch(t *testing.T) {
+	coll := newTestCollection(t).ScopeTenant(&TenantConfig{})
+
+	stages := TextSearchStages("coffee")
+	_, pipeline, err := coll.scopePipeline(WithTenant(context.Background(), "t1"), aggregatePipeline(stages...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pipeline) != len(stages) {
+		t.Fatalf("expected %d stages, got %v", len(stages), pipeline)
+	}
+	want := bson.M{"$match": bson.M{"$and": bson.A{TextFilter("coffee"), bson.M{"tenantId": "t1"}}}}
+	if !reflect.DeepEqual(pipeline[0], want) {
+		t.Fatalf("unexpected first stage: %v", pipeline[0])
+	}
+}
+
+func TestScopeDatabaseStrategy(t *testing.T) {
+	coll := newTestCollection(t).ScopeTenant(&TenantConfig{Strategy: TenantDatabaseStrategy})
+
+	scoped, filter, tenantID, err := coll.scope(WithTenant(context.Background(), "t1"), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoped.Database().Name() != "shop_t1" || scoped.Name() != "orders" {
+		t.Fatalf("unexpected collection: %s.%s", scoped.Database().Name(), scoped.Name())
+	}
+	if !reflect.DeepEqual(filter, bson.M{}) || tenantID != "t1" {
+		t.Fatalf("unexpected filter %v, tenant %s", filter, tenantID)
+	}
+}
+
+func TestStampTenant(t *testing.T) {
+	doc := &tenantDoc{}
+	stampTenant(doc, "t1")
+	if doc.GetTenantID() != "t1" {
+		t.Fatalf("expected tenant t1, got %s", doc.GetTenantID())
+	}
+}
+
+func TestUpdateKeepsTenant(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("update", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+		coll := NewCollection(mt.DB, "orders").ScopeTenant(&TenantConfig{})
+
+		doc := &tenantDoc{Name: "a"}
+		doc.ID = "o1"
+		doc.SetTenantID("t2")
+		if err := coll.UpdateWithCtx(WithTenant(context.Background(), "t1"), doc); err != nil {
+			mt.Fatal(err)
+		}
+		if doc.GetTenantID() != "t1" {
+			mt.Fatalf("expected tenant t1, got %s", doc.GetTenantID())
+		}
+
+		updates := mt.GetStartedEvent().Command.Lookup("updates").Array()
+		set := updates.Index(0).Value().Document().Lookup("u", "$set", "tenantId")
+		if tenantID, _ := set.StringValueOK(); tenantID != "t1" {
+			mt.Fatalf("expected $set of tenant t1, got %v", set)
+		}
+	})
+
+	mt.Run("first and update", func(mt *mtest.T) {
+		coll := NewCollection(mt.DB, "orders").ScopeTenant(&TenantConfig{})
+		ctx := WithTenant(context.Background(), "t1")
+
+		for _, update := range []interface{}{
+			bson.M{"$set": bson.M{"tenantId": "t2"}},
+			bson.D{{Key: "$rename", Value: bson.M{"owner": "tenantId"}}},
+			bson.M{"$unset": bson.M{"tenantId.region": ""}},
+			mongo.Pipeline{{{Key: "$unset", Value: "tenantId"}}},
+			bson.A{bson.M{"$replaceWith": bson.M{"name": "a"}}},
+		} {
+			if err := coll.FirstAndUpdateWithCtx(ctx, bson.M{}, update, &tenantDoc{}); err != ErrTenantUpdate {
+				mt.Fatalf("update %v: expected ErrTenantUpdate, got %v", update, err)
+			}
+		}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.M{"_id": "o1", "tenantId": "t1", "name": "b"}}))
+		doc := &tenantDoc{}
+		if err := coll.FirstAndUpdateWithCtx(ctx, bson.M{}, bson.M{"$set": bson.M{"name": "b"}}, doc); err != nil {
+			mt.Fatal(err)
+		}
+		if doc.Name != "b" {
+			mt.Fatalf("unexpected document: %+v", doc)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ponlv/go-kit/mongodb/builder"
+	"github.com/ponlv/go-kit/mongodb/field"
+
+	o "github.com/kamva/mgm/v3/operator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TextTagName is the struct tag used to mark text-indexed fields, its
+// value is the field weight, e.g `text:"10"`. Empty value means weight 1.
+const TextTagName = "text"
+
+// DefaultTextScoreField is the field that text score is projected to.
+const DefaultTextScoreField = "score"
+
+// TextSearchOptions struct contain options of text search helpers.
+type TextSearchOptions struct {
+	// Filter is combined with the $text query.
+	Filter             interface{}
+	Language           string
+	CaseSensitive      bool
+	DiacriticSensitive bool
+	// ScoreField is the field that text score is projected to,
+	// default to `DefaultTextScoreField`.
+	ScoreField string
+	Skip       int64
+	Limit      int64
+}
+
+// TextIndexModel return text index of the model, fields and weights
+// are taken from `text` struct tags.
+func TextIndexModel(model interface{}, opts ...*options.IndexOptions) (mongo.IndexModel, error) {
+	weights := bson.D{}
+	if err := textFields(reflect.TypeOf(model), "", &weights, map[reflect.Type]bool{}); err != nil {
+		return mongo.IndexModel{}, err
+	}
+	if len(weights) == 0 {
+		return mongo.IndexModel{}, errors.New("mongodb: model has no text field")
+	}
+
+	keys := bson.D{}
+	for _, w := range weights {
+		keys = append(keys, bson.E{Key: w.Key, Value: field.Text})
+	}
+
+	indexOpts := options.MergeIndexOptions(append([]*options.IndexOptions{options.Index().SetWeights(weights)}, opts...)...)
+
+	return mongo.IndexModel{Keys: keys, Options: indexOpts}, nil
+}
+
+// EnsureTextIndex create text index of the model on collection and return index's name.
+func EnsureTextIndex(ctx context.Context, coll *Collection, model interface{}, opts ...*options.IndexOptions) (string, error) {
+	index, err := TextIndexModel(model, opts...)
+	if err != nil {
+		return "", err
+	}
+	return coll.Indexes().CreateOne(ctx, index)
+}
+
+// TextFilter return filter that combine $text query with the options' filter.
+func TextFilter(query string, opts ...*TextSearchOptions) bson.M {
+	opt := mergeTextSearchOptions(opts...)
+
+	var language, caseSensitive, diacriticSensitive interface{}
+	if opt.Language != "" {
+		language = opt.Language
+	}
+	if opt.CaseSensitive {
+		caseSensitive = true
+	}
+	if opt.DiacriticSensitive {
+		diacriticSensitive = true
+	}
+	text := builder.S(builder.Text(query, language, caseSensitive, diacriticSensitive))
+
+	if opt.Filter == nil {
+		return text
+	}
+	return bson.M{o.And: bson.A{opt.Filter, text}}
+}
+
+// TextFindOptions return find options that project text score and sort
+// results by relevance, to using with `SimpleFind`.
+func TextFindOptions(opts ...*TextSearchOptions) *options.FindOptions {
+	opt := mergeTextSearchOptions(opts...)
+	score := builder.Meta(field.TextScore)
+
+	findOpts := options.Find().
+		SetProjection(bson.M{opt.ScoreField: score}).
+		SetSort(bson.D{{Key: opt.ScoreField, Value: score}})
+
+	if opt.Skip > 0 {
+		findOpts.SetSkip(opt.Skip)
+	}
+	if opt.Limit > 0 {
+		findOpts.SetLimit(opt.Limit)
+	}
+
+	return findOpts
+}
+
+// TextSearchStages return aggregation stages that match the text query,
+// project text score and sort by relevance, to using with `SimpleAggregate`.
+// $text stage must be the first stage of the pipeline.
+func TextSearchStages(query string, opts ...*TextSearchOptions) []interface{} {
+	opt := mergeTextSearchOptions(opts...)
+	score := builder.Meta(field.TextScore)
+
+	stages := []interface{}{
+		builder.New(o.Match, TextFilter(query, opt)),
+		builder.New(o.AddFields, bson.M{opt.ScoreField: score}),
+		builder.New(o.Sort, bson.D{{Key: opt.ScoreField, Value: score}}),
+	}
+
+	if opt.Skip > 0 {
+		stages = append(stages, builder.New(o.Skip, opt.Skip))
+	}
+	if opt.Limit > 0 {
+		stages = append(stages, builder.New(o.Limit, opt.Limit))
+	}
+
+	return stages
+}
+
+// TextSearch find documents matched the text query sorted by relevance
+// and decode them to results.
+func TextSearch(ctx context.Context, coll *Collection, query string, results interface{}, opts ...*TextSearchOptions) error {
+	return coll.SimpleFindWithCtx(ctx, results, TextFilter(query, opts...), TextFindOptions(opts...))
+}
+
+func mergeTextSearchOptions(opts ...*TextSearchOptions) *TextSearchOptions {
+	opt := &TextSearchOptions{}
+	for _, item := range opts {
+		if item != nil {
+			*opt = *item
+		}
+	}
+	if opt.ScoreField == "" {
+		opt.ScoreField = DefaultTextScoreField
+	}
+	return opt
+}
+
+// textFields collect weights of `text` tagged fields, nested
+// structs are walked and their fields are prefixed by their path.
+func textFields(t reflect.Type, prefix string, weights *bson.D, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("bson")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		inline := false
+		for _, opt := range parts[1:] {
+			if opt == "inline" {
+				inline = true
+			}
+		}
+
+		if weight, ok := f.Tag.Lookup(TextTagName); ok {
+			w := 1
+			if weight != "" {
+				var err error
+				if w, err = strconv.Atoi(weight); err != nil || w <= 0 {
+					return fmt.Errorf("mongodb: invalid text weight %q of field %s", weight, f.Name)
+				}
+			}
+			*weights = append(*weights, bson.E{Key: prefix + name, Value: w})
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+
+		nested := prefix + name + "."
+		if inline {
+			nested = prefix
+		}
+		if err := textFields(ft, nested, weights, seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package mongodb
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type article struct {
+	DefaultModel `bson:",inline"`
+	Title        string `bson:"title" text:"10"`
+	Body         string `bson:"body" text:""`
+	Author       struct {
+		Name string `bson:"name" text:"2"`
+	} `bson:"author"`
+	Views int `bson:"views"`
+}
+
+func TestTextIndexModel(t *testing.T) {
+	index, err := TextIndexModel(&article{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantKeys := bson.D{{Key: "title", Value: "text"}, {Key: "body", Value: "text"}, {Key: "author.name", Value: "text"}}
+	if !reflect.DeepEqual(index.Keys, wantKeys) {
+		t.Fatalf("unexpected keys: %v", index.Keys)
+	}
+
+	wantWeights := bson.D{{Key: "title", Value: 10}, {Key: "body", Value: 1}, {Key: "author.name", Value: 2}}
+	if !reflect.DeepEqual(index.Options.Weights, wantWeights) {
+		t.Fatalf("unexpected weights: %v", index.Options.Weights)
+	}
+}
+
+func TestTextFilter(t *testing.T) {
+	got := TextFilter("coffee", &TextSearchOptions{Filter: bson.M{"views": 1}, Language: "en"})
+	want := bson.M{"$and": bson.A{bson.M{"views": 1}, bson.M{"$text": bson.M{"$search": "coffee", "$language": "en"}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected filter: %v", got)
+	}
+}
@@ -13,11 +13,15 @@ import (
 )
 
 // Coll return model's collection.
+// If tenant-aware mode is enabled and model implement `TenantModel`,
+// the returned collection is scoped to the tenant of each operation's context.
 func Coll(db string, m Model, opts ...*options.CollectionOptions) *Collection {
 	if collGetter, ok := m.(CollectionGetter); ok {
 		return collGetter.Collection()
 	}
-	return CollectionByName(db, CollName(m), opts...)
+	coll := CollectionByName(db, CollName(m), opts...)
+	coll.tenant = tenantConfigFor(m)
+	return coll
 }
 
 func CollRead(db string, m Model, opts ...*options.CollectionOptions) *Collection {
@@ -41,7 +45,9 @@ func CollWithMode(db string, m Model, mode readpref.Mode) *Collection {
 	if collGetter, ok := m.(CollectionGetter); ok {
 		return collGetter.Collection()
 	}
-	return CollectionByNameWithMode(db, CollName(m), mode)
+	coll := CollectionByNameWithMode(db, CollName(m), mode)
+	coll.tenant = tenantConfigFor(m)
+	return coll
 }
 
 // CollName check if you provided collection name in your
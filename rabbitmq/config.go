@@ -10,6 +10,8 @@ type RabbitMQConfig struct {
 	Vhost    string
 	Exchange string
 	Durable  bool
+
+	client *Client
 }
 
 func defaultDB() *RabbitMQConfig {
@@ -0,0 +1,266 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// publishIDHeader is set on confirmed messages to match returns with their publishing.
+const publishIDHeader = "x-publish-id"
+
+var (
+	// ErrNacked is returned when the broker could not take the message.
+	ErrNacked = errors.New("rabbitmq: message nacked by the broker")
+	// ErrUnroutable matches every *UnroutableError with errors.Is.
+	ErrUnroutable = errors.New("rabbitmq: message is unroutable")
+)
+
+// UnroutableError is returned when a mandatory message was returned because
+// no queue is bound to the exchange with its routing key.
+type UnroutableError struct {
+	Exchange  string
+	Key       string
+	ReplyCode uint16
+	ReplyText string
+}
+
+func (e *UnroutableError) Error() string {
+	return fmt.Sprintf("rabbitmq: message to exchange %q with key %q returned: %d %s", e.Exchange, e.Key, e.ReplyCode, e.ReplyText)
+}
+
+// Is make errors.Is(err, ErrUnroutable) match.
+func (e *UnroutableError) Is(target error) bool {
+	return target == ErrUnroutable
+}
+
+// Confirmation is the outcome of a confirmed publishing, it's done once the
+// broker acked, nacked or returned the message, or the channel was closed.
+type Confirmation struct {
+	Exchange string
+	Key      string
+	Message  amqp.Publishing
+
+	id      string
+	noSpill bool
+	done    chan struct{}
+	err     error
+	spilled bool
+}
+
+func newConfirmation(exchange, key string, msg amqp.Publishing) *Confirmation {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	// copy the headers, the caller may reuse the map
+	headers := make(amqp.Table, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[publishIDHeader] = id
+	msg.Headers = headers
+
+	return &Confirmation{Exchange: exchange, Key: key, Message: msg, id: id, done: make(chan struct{})}
+}
+
+// Done is closed once the confirmation is known.
+func (c *Confirmation) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err return nil when the message was acked or spilled, ErrNacked, an
+// *UnroutableError or the error closing the channel otherwise. It's only
+// valid once Done is closed.
+func (c *Confirmation) Err() error {
+	return c.err
+}
+
+// Spilled report whether the message was kept in the spill buffer to be
+// published again later.
+func (c *Confirmation) Spilled() bool {
+	return c.spilled
+}
+
+// Wait for the confirmation until ctx is done.
+func (c *Confirmation) Wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// confirmChannel is a channel in confirm mode, a listener resolves the
+// pending confirmations as acks, nacks and returns come in.
+type confirmChannel struct {
+	ch      *amqp.Channel
+	resolve func(conf *Confirmation, err error)
+
+	mu      sync.Mutex
+	pending map[uint64]*Confirmation
+	closed  bool
+}
+
+func newConfirmChannel(conn *amqp.Connection, resolve func(conf *Confirmation, err error)) (*confirmChannel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err = ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	cc := &confirmChannel{ch: ch, resolve: resolve, pending: make(map[uint64]*Confirmation)}
+	// returns are unbuffered: the broker sends the return of a message before
+	// its ack, so the return is always seen by the listener first
+	returns := ch.NotifyReturn(make(chan amqp.Return))
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 256))
+	go cc.listen(returns, confirms)
+	return cc, nil
+}
+
+func (cc *confirmChannel) IsClosed() bool {
+	return cc.ch.IsClosed()
+}
+
+func (cc *confirmChannel) Close() error {
+	return cc.ch.Close()
+}
+
+// publish send the message as mandatory and register its confirmation.
+func (cc *confirmChannel) publish(ctx context.Context, conf *Confirmation) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.closed {
+		return amqp.ErrClosed
+	}
+
+	tag := cc.ch.GetNextPublishSeqNo()
+	cc.pending[tag] = conf
+	if err := cc.ch.PublishWithContext(ctx, conf.Exchange, conf.Key, true, false, conf.Message); err != nil {
+		delete(cc.pending, tag)
+		return err
+	}
+	return nil
+}
+
+func (cc *confirmChannel) listen(returns <-chan amqp.Return, confirms <-chan amqp.Confirmation) {
+	returned := make(map[string]amqp.Return)
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			if id, ok := r.Headers[publishIDHeader].(string); ok {
+				returned[id] = r
+			}
+		case c, ok := <-confirms:
+			if !ok {
+				cc.failPending()
+				return
+			}
+
+			cc.mu.Lock()
+			conf := cc.pending[c.DeliveryTag]
+			delete(cc.pending, c.DeliveryTag)
+			cc.mu.Unlock()
+			if conf == nil {
+				continue
+			}
+
+			var err error
+			if r, ok := returned[conf.id]; ok {
+				delete(returned, conf.id)
+				err = &UnroutableError{Exchange: r.Exchange, Key: r.RoutingKey, ReplyCode: r.ReplyCode, ReplyText: r.ReplyText}
+			} else if !c.Ack {
+				err = ErrNacked
+			}
+			cc.resolve(conf, err)
+		}
+	}
+}
+
+// failPending resolve the confirmations left when the channel was closed,
+// the broker may or may not have taken those messages.
+func (cc *confirmChannel) failPending() {
+	cc.mu.Lock()
+	cc.closed = true
+	pending := cc.pending
+	cc.pending = nil
+	cc.mu.Unlock()
+
+	for _, conf := range pending {
+		cc.resolve(conf, amqp.ErrClosed)
+	}
+}
+
+// Batch publish messages asynchronously and wait for all of their
+// confirmations at once, the in-flight window of the client bounds how many
+// are waiting.
+// EX used:
+//
+//	batch := client.NewBatch()
+//	for _, body := range bodies {
+//		if err := batch.Publish(ctx, "orders", "orders.created", amqp.Publishing{Body: body}); err != nil {...}
+//	}
+//	failed, err := batch.Wait(ctx)
+type Batch struct {
+	client *Client
+
+	mu            sync.Mutex
+	confirmations []*Confirmation
+}
+
+// NewBatch return an empty batch.
+func (c *Client) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// Publish send the message without waiting for its confirmation, it blocks
+// while the in-flight window is full.
+func (b *Batch) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	conf, err := b.client.PublishAsync(ctx, exchange, key, msg)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.confirmations = append(b.confirmations, conf)
+	b.mu.Unlock()
+	return nil
+}
+
+// Wait for the confirmations of the messages published so far and return
+// the failed ones, the batch is empty afterwards. When ctx is done first, it
+// returns the failed ones so far with ctx.Err() and keeps the unresolved
+// confirmations in the batch for the next Wait.
+func (b *Batch) Wait(ctx context.Context) ([]*Confirmation, error) {
+	b.mu.Lock()
+	confirmations := b.confirmations
+	b.confirmations = nil
+	b.mu.Unlock()
+
+	var failed []*Confirmation
+	for i, conf := range confirmations {
+		if err := conf.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				b.mu.Lock()
+				b.confirmations = append(append([]*Confirmation(nil), confirmations[i:]...), b.confirmations...)
+				b.mu.Unlock()
+				return failed, ctx.Err()
+			}
+			failed = append(failed, conf)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,164 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestConfirmListen(t *testing.T) {
+	resolved := make(map[string]error)
+	cc := &confirmChannel{
+		resolve: func(conf *Confirmation, err error) {
+			resolved[conf.Key] = err
+		},
+		pending: make(map[uint64]*Confirmation),
+	}
+	acked := newConfirmation("orders", "acked", amqp.Publishing{})
+	returned := newConfirmation("orders", "returned", amqp.Publishing{Headers: amqp.Table{"a": "b"}})
+	nacked := newConfirmation("orders", "nacked", amqp.Publishing{})
+	lost := newConfirmation("orders", "lost", amqp.Publishing{})
+	cc.pending[1], cc.pending[2], cc.pending[3], cc.pending[4] = acked, returned, nacked, lost
+
+	returns := make(chan amqp.Return)
+	confirms := make(chan amqp.Confirmation)
+	done := make(chan struct{})
+	go func() {
+		cc.listen(returns, confirms)
+		close(done)
+	}()
+
+	returns <- amqp.Return{ReplyCode: 312, ReplyText: "NO_ROUTE", Exchange: "orders", RoutingKey: "returned", Headers: returned.Message.Headers}
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: false}
+	close(confirms)
+	<-done
+
+	if err := resolved["acked"]; err != nil {
+		t.Errorf("acked: %v", err)
+	}
+	var unroutable *UnroutableError
+	if err := resolved["returned"]; !errors.Is(err, ErrUnroutable) || !errors.As(err, &unroutable) || unroutable.ReplyCode != 312 {
+		t.Errorf("returned: %v", err)
+	}
+	if err := resolved["nacked"]; err != ErrNacked {
+		t.Errorf("nacked: %v", err)
+	}
+	if err := resolved["lost"]; err != amqp.ErrClosed {
+		t.Errorf("lost: %v", err)
+	}
+	if !cc.closed {
+		t.Error("channel should be closed")
+	}
+}
+
+func TestSpill(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newSpill(SpillConfig{Dir: dir, MaxMessages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := newConfirmation("orders", "first", amqp.Publishing{Body: []byte("1")})
+	if err = s.push(first.Exchange, first.Key, first.Message); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.push("orders", "second", amqp.Publishing{Body: []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.push("orders", "third", amqp.Publishing{}); err != ErrSpillFull {
+		t.Fatalf("push on a full spill: %v", err)
+	}
+
+	// restart
+	s, err = newSpill(SpillConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := s.pending()
+	if len(pending) != 2 || pending[0].Key != "first" || string(pending[1].Message.Body) != "2" {
+		t.Fatalf("unexpected spilled messages: %+v", pending)
+	}
+	if _, ok := pending[0].Message.Headers[publishIDHeader]; ok {
+		t.Error("publish id should not be spilled")
+	}
+
+	s.remove(pending[0])
+	s, _ = newSpill(SpillConfig{Dir: dir})
+	if s.len() != 1 {
+		t.Fatalf("expected 1 spilled message, got %d", s.len())
+	}
+}
+
+func TestBatchWait(t *testing.T) {
+	acked := newConfirmation("orders", "acked", amqp.Publishing{})
+	nacked := newConfirmation("orders", "nacked", amqp.Publishing{})
+	unresolved := newConfirmation("orders", "unresolved", amqp.Publishing{})
+	close(acked.done)
+	nacked.err = ErrNacked
+	close(nacked.done)
+
+	b := &Batch{confirmations: []*Confirmation{acked, nacked, unresolved}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	failed, err := b.Wait(ctx)
+	if err != context.DeadlineExceeded || len(failed) != 1 || failed[0] != nacked {
+		t.Fatalf("unexpected result %v, %v", failed, err)
+	}
+	// kept for the next wait
+	if len(b.confirmations) != 1 || b.confirmations[0] != unresolved {
+		t.Fatalf("unexpected confirmations left: %v", b.confirmations)
+	}
+
+	unresolved.err = amqp.ErrClosed
+	close(unresolved.done)
+	failed, err = b.Wait(context.Background())
+	if err != nil || len(failed) != 1 || failed[0] != unresolved || len(b.confirmations) != 0 {
+		t.Fatalf("unexpected result %v, %v", failed, err)
+	}
+}
+
+func TestResolveSpill(t *testing.T) {
+	s, err := newSpill(SpillConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{spill: s, window: make(chan struct{}, 2), spills: make(chan spillRequest, 2), done: make(chan struct{})}
+
+	// the listener only hands the message over
+	conf := newConfirmation("orders", "nacked", amqp.Publishing{Body: []byte("1")})
+	c.window <- struct{}{}
+	c.resolve(conf, ErrNacked)
+	if s.len() != 0 || len(c.spills) != 1 {
+		t.Fatal("message should be spilled by spillLoop")
+	}
+
+	c.spilling.Add(1)
+	go c.spillLoop()
+	<-conf.Done()
+	if conf.Err() != nil || !conf.Spilled() || s.len() != 1 || len(c.window) != 0 {
+		t.Fatalf("unexpected confirmation %v, spilled %v", conf.Err(), conf.Spilled())
+	}
+
+	// once closed, messages are spilled right away
+	c.closed = true
+	close(c.done)
+	c.spilling.Wait()
+	conf = newConfirmation("orders", "lost", amqp.Publishing{})
+	c.window <- struct{}{}
+	c.resolve(conf, amqp.ErrClosed)
+	if !conf.Spilled() || s.len() != 2 {
+		t.Fatal("message should be spilled on resolve")
+	}
+
+	// not transient, never spilled
+	conf = newConfirmation("orders", "returned", amqp.Publishing{})
+	c.window <- struct{}{}
+	c.resolve(conf, &UnroutableError{})
+	if conf.Spilled() || !errors.Is(conf.Err(), ErrUnroutable) {
+		t.Fatalf("unexpected confirmation %v", conf.Err())
+	}
+}
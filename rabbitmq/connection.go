@@ -0,0 +1,161 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// connection keep one amqp connection open, it redial with backoff when the
+// connection is lost and run onConnect before the connection is used again.
+type connection struct {
+	name      string
+	url       string
+	dial      amqp.Config
+	minDelay  time.Duration
+	maxDelay  time.Duration
+	onConnect func(conn *amqp.Connection) error
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	ready   chan struct{}
+	lastErr error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newConnection(name string, conf *Config, onConnect func(conn *amqp.Connection) error) *connection {
+	dial := conf.Dial
+	if dial.Heartbeat <= 0 {
+		dial.Heartbeat = 10 * time.Second
+	}
+	if dial.Properties == nil {
+		dial.Properties = amqp.NewConnectionProperties()
+	}
+	dial.Properties.SetClientConnectionName(name)
+
+	return &connection{
+		name:      name,
+		url:       conf.URL,
+		dial:      dial,
+		minDelay:  conf.ReconnectDelay,
+		maxDelay:  conf.MaxReconnectDelay,
+		onConnect: onConnect,
+		ready:     make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *connection) start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+func (c *connection) run() {
+	defer c.wg.Done()
+
+	delay := c.minDelay
+	for {
+		conn, err := c.connect()
+		if err != nil {
+			c.mu.Lock()
+			c.lastErr = err
+			c.mu.Unlock()
+			logger.Error().Err(err).Var("name", c.name).Msg("failed to connect to RabbitMQ")
+
+			select {
+			case <-c.done:
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > c.maxDelay {
+				delay = c.maxDelay
+			}
+			continue
+		}
+		delay = c.minDelay
+
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		c.mu.Lock()
+		c.conn = conn
+		c.lastErr = nil
+		close(c.ready)
+		c.mu.Unlock()
+		logger.Info().Var("name", c.name).Msg("connection to RabbitMQ is established")
+
+		select {
+		case <-c.done:
+			return
+		case err := <-closed:
+			c.mu.Lock()
+			c.conn = nil
+			c.ready = make(chan struct{})
+			c.mu.Unlock()
+			logger.Error().Err(err).Var("name", c.name).Msg("connection dropped, reconnecting")
+		}
+	}
+}
+
+func (c *connection) connect() (*amqp.Connection, error) {
+	conn, err := amqp.DialConfig(c.url, c.dial)
+	if err != nil {
+		return nil, err
+	}
+	if c.onConnect != nil {
+		if err = c.onConnect(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// get return the open connection, it waits while reconnecting.
+func (c *connection) get(ctx context.Context) (*amqp.Connection, error) {
+	for {
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+		if conn != nil {
+			return conn, nil
+		}
+
+		select {
+		case <-ready:
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// connected report whether the connection is open now.
+func (c *connection) connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// err return the error of the last failed connection attempt.
+func (c *connection) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// close stop reconnecting and close the connection.
+func (c *connection) close() error {
+	close(c.done)
+	c.wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,169 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errConsumerStopped = errors.New("rabbitmq: consumer channel closed")
+
+// ConsumeOptions struct contain options of Client.Consume.
+type ConsumeOptions struct {
+	// Consumer is the consumer tag, default to a random one.
+	Consumer string
+	// Prefetch is the number of unacknowledged messages, default to Concurrency.
+	Prefetch int
+	// Concurrency is the number of handlers running at the same time, default to 1.
+	Concurrency int
+	// AutoAck let the broker forget messages once delivered, handler errors are only logged.
+	AutoAck   bool
+	Exclusive bool
+	// Requeue messages whose handler failed, otherwise they are rejected
+	// to the dead letter exchange of the queue if any.
+	Requeue bool
+	Args    amqp.Table
+}
+
+// Consume call f with the messages of the queue until ctx is done or the
+// client is closed, messages are acked when f succeeds and rejected
+// otherwise. Consuming resumes after reconnects. It returns ctx.Err() or
+// ErrClosed once the running handlers are done.
+// EX used: err := client.Consume(ctx, "orders.created", &rabbitmq.ConsumeOptions{Concurrency: 4}, func(d []byte) error {...})
+func (c *Client) Consume(ctx context.Context, queue string, opts *ConsumeOptions, f func(d []byte) error) error {
+	o := ConsumeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.Prefetch <= 0 {
+		o.Prefetch = o.Concurrency
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.consuming.Add(1)
+	c.mu.Unlock()
+	defer c.consuming.Done()
+
+	delay := c.conf.ReconnectDelay
+	for {
+		err := c.consume(ctx, queue, &o, f)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return ErrClosed
+		default:
+		}
+
+		if err == errConsumerStopped {
+			// it was consuming, the connection was lost just now
+			delay = c.conf.ReconnectDelay
+		}
+		logger.Error().Err(err).Var("queue", queue).Msg("consumer stopped, restarting")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return ErrClosed
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > c.conf.MaxReconnectDelay {
+			delay = c.conf.MaxReconnectDelay
+		}
+	}
+}
+
+// consume run the handlers until the channel is closed, ctx is done or the
+// client is closed.
+func (c *Client) consume(ctx context.Context, queue string, o *ConsumeOptions, f func(d []byte) error) error {
+	ch, err := c.consumers.get(ctx)
+	if err != nil {
+		return err
+	}
+	if err = ch.Qos(o.Prefetch, 0, false); err != nil {
+		c.consumers.put(ch)
+		return err
+	}
+
+	tag := o.Consumer
+	if tag == "" {
+		tag = consumerTag(queue)
+	}
+	deliveries, err := ch.Consume(queue, tag, o.AutoAck, o.Exclusive, false, false, o.Args)
+	if err != nil {
+		c.consumers.put(ch)
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				handle(queue, o, d, f)
+			}
+		}()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		c.consumers.put(ch)
+		return errConsumerStopped
+	case <-ctx.Done():
+	case <-c.done:
+	}
+
+	// deliveries is closed after the prefetched messages are handled
+	if err = ch.Cancel(tag, false); err != nil {
+		c.consumers.discard(ch)
+		<-stopped
+		return err
+	}
+	<-stopped
+	c.consumers.put(ch)
+	return nil
+}
+
+func handle(queue string, o *ConsumeOptions, d amqp.Delivery, f func(d []byte) error) {
+	err := f(d.Body)
+	if err != nil {
+		logger.Error().Err(err).Var("name", queue).Var("body", d.Body).Msg("error when handle a message")
+	}
+	if o.AutoAck {
+		return
+	}
+
+	if err != nil {
+		if errReject := d.Reject(o.Requeue); errReject != nil {
+			logger.Error().Err(errReject).Var("name", queue).Var("body", d.Body).Msg("error when reject")
+		}
+		return
+	}
+	if errAck := d.Ack(false); errAck != nil {
+		logger.Error().Err(errAck).Var("name", queue).Var("body", d.Body).Msg("error when ack")
+	}
+}
+
+func consumerTag(queue string) string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return queue + "-" + hex.EncodeToString(b)
+}
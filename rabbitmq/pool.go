@@ -0,0 +1,105 @@
+package rabbitmq
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// poolItem is a channel, or a channel with its own state such as confirmChannel.
+type poolItem interface {
+	IsClosed() bool
+	Close() error
+}
+
+// channelPool reuse up to size channels of a connection. Channels closed by
+// a channel exception or a reconnect are dropped and opened again on demand.
+type channelPool[T poolItem] struct {
+	conn  *connection
+	idle  chan T
+	slots chan struct{}
+	open  func(conn *amqp.Connection) (T, error)
+}
+
+func newChannelPool[T poolItem](conn *connection, size int, open func(conn *amqp.Connection) (T, error)) *channelPool[T] {
+	return &channelPool[T]{
+		conn:  conn,
+		idle:  make(chan T, size),
+		slots: make(chan struct{}, size),
+		open:  open,
+	}
+}
+
+// openChannel open a plain channel.
+func openChannel(conn *amqp.Connection) (*amqp.Channel, error) {
+	return conn.Channel()
+}
+
+// get return an idle channel or open a new one, it waits while every
+// channel is in use.
+func (p *channelPool[T]) get(ctx context.Context) (T, error) {
+	var zero T
+	for {
+		// idle channels first, a new channel is opened only when none is left
+		select {
+		case ch := <-p.idle:
+			if ch.IsClosed() {
+				<-p.slots
+				continue
+			}
+			return ch, nil
+		default:
+		}
+
+		select {
+		case ch := <-p.idle:
+			if ch.IsClosed() {
+				<-p.slots
+				continue
+			}
+			return ch, nil
+		case p.slots <- struct{}{}:
+			conn, err := p.conn.get(ctx)
+			if err != nil {
+				<-p.slots
+				return zero, err
+			}
+			ch, err := p.open(conn)
+			if err != nil {
+				<-p.slots
+				return zero, err
+			}
+			return ch, nil
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// put give the channel back, closed channels free their slot.
+func (p *channelPool[T]) put(ch T) {
+	if ch.IsClosed() {
+		<-p.slots
+		return
+	}
+	p.idle <- ch
+}
+
+// discard close a channel that must not be reused and free its slot.
+func (p *channelPool[T]) discard(ch T) {
+	ch.Close()
+	<-p.slots
+}
+
+// close close the idle channels.
+func (p *channelPool[T]) close() {
+	for {
+		select {
+		case ch := <-p.idle:
+			ch.Close()
+			<-p.slots
+		default:
+			return
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package rabbitmq
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ponlv/go-kit/plog"
 
@@ -11,87 +13,99 @@ import (
 
 var logger = plog.NewBizLogger("rabbitmq")
 
+// legacyConnectTimeout bound how long InitRabbitMq wait for the first
+// connection, it used to fail on the first dial error.
+const legacyConnectTimeout = 10 * time.Second
+
+// InitRabbitMq connect a Client and declare the topic exchange of the config.
+//
+// Deprecated: use New, the returned connection and channel do not survive reconnects.
 func (r *RabbitMQConfig) InitRabbitMq(config RabbitMQConfig) (*amqp.Connection, *amqp.Channel, error) {
-	host := config.Host
-	port := config.Port
-	username := config.UserName
-	password := config.Password
-	vHost := config.Vhost
-	rabbitmqConnString := fmt.Sprintf("amqp://%s:%s@%s:%s/%s", username, password, host, port, vHost)
-	conn, ch, err := r.connect(rabbitmqConnString, config.Exchange)
-	return conn, ch, err
-}
+	url := fmt.Sprintf("amqp://%s:%s@%s:%s/%s", config.UserName, config.Password, config.Host, config.Port, config.Vhost)
+	conf := Config{URL: url}
+	if config.Exchange != "" {
+		conf.Topology.Exchanges = []Exchange{{Name: config.Exchange, Kind: amqp.ExchangeTopic, Durable: true}}
+	}
 
-func (r *RabbitMQConfig) connect(url string, exchange string) (*amqp.Connection, *amqp.Channel, error) {
-	conn, err := amqp.Dial(url)
+	ctx, cancel := context.WithTimeout(context.Background(), legacyConnectTimeout)
+	defer cancel()
+
+	client, err := New(ctx, conf)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to connect to RabbitMQ")
 		return nil, nil, err
 	}
+	r.client = client
 
-	ch, err := conn.Channel()
+	conn, err := client.Connection(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := client.Channel(ctx)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to open channel rabbitmq")
 		return nil, nil, err
 	}
-	_ = ch.ExchangeDeclare(
-		exchange,
-		"topic",
-		true,  // durable
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // arguments
-	)
 	return conn, ch, nil
 }
 
+// Client return the client connected by InitRabbitMq, nil before.
+func (r *RabbitMQConfig) Client() *Client {
+	return r.client
+}
+
+// DeclareQueue declare the queue bound to the exchange with the key "exchange.queue".
+//
+// Deprecated: use Client.Declare.
 func (r *RabbitMQConfig) DeclareQueue(ch *amqp.Channel, name string, exchange string, durable bool) amqp.Queue {
-	q, err := ch.QueueDeclare(
-		name,    // name
-		durable, // durable
-		false,   // delete when unused
-		false,   // exclusive
-		false,   // no-wait
-		nil,     // arguments
-	)
-	if err != nil {
-		logger.Error().Err(err).Var("name", name).Msg("failed to declare queue rabbitmq")
+	topology := Topology{Queues: []Queue{{Name: name, Durable: durable}}}
+	if exchange != "" {
+		topology.Bindings = []Binding{{Queue: name, Exchange: exchange, Key: fmt.Sprintf("%s.%s", exchange, name)}}
+	}
+
+	var err error
+	if r.client != nil {
+		err = r.client.Declare(context.Background(), topology)
+	} else {
+		err = topology.declare(ch)
 	}
-	err = ch.QueueBind(
-		q.Name,                                 // queue name
-		fmt.Sprintf("%s.%s", exchange, q.Name), // routing key
-		exchange,                               // exchange
-		false,
-		nil,
-	)
 	if err != nil {
-		logger.Error().Err(err).Var("name", name).Msg("failed to binding queue rabbitmq")
+		logger.Error().Err(err).Var("name", name).Msg("failed to declare queue rabbitmq")
+	} else {
+		logger.Info().Var("name", name).Msg("declare queue success")
 	}
-	logger.Info().Var("name", name).Msg("declare queue success")
-	return q
+	return amqp.Queue{Name: name}
 }
 
+// Publish send data to the exchange of the config with the queue name as routing key.
+//
+// Deprecated: use Client.Publish.
 func (r *RabbitMQConfig) Publish(ch *amqp.Channel, q amqp.Queue, data []byte) error {
-	if ch == nil {
+	msg := amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        data,
+	}
+
+	var err error
+	switch {
+	case r.client != nil:
+		err = r.client.Publish(context.Background(), r.Exchange, q.Name, msg)
+	case ch != nil:
+		err = ch.Publish(r.Exchange, q.Name, false, false, msg)
+	default:
 		return errors.New("channel is nil")
 	}
-	err := ch.Publish(
-		r.Exchange, // exchange
-		q.Name,     // routing key
-		false,      // mandatory
-		false,      // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        data,
-		})
 	if err != nil {
 		logger.Error().Err(err).Var("name", q.Name).Msg("failed to publish a message")
+		return err
 	}
 	logger.Info().Var("name", q.Name).Msg("message published successfully")
-	return err
+	return nil
 }
 
+// Consume start consuming the queue on the channel.
+//
+// Deprecated: use Client.Consume, it resumes consuming after reconnects.
 func (r *RabbitMQConfig) Consume(ch *amqp.Channel, q amqp.Queue, opt Option) <-chan amqp.Delivery {
 	messages, err := ch.Consume(
 		q.Name,        // queue
@@ -108,32 +122,27 @@ func (r *RabbitMQConfig) Consume(ch *amqp.Channel, q amqp.Queue, opt Option) <-c
 	return messages
 }
 
+// HandleMessages call f with the messages and block forever, messages are
+// only acked or rejected in production.
+//
+// Deprecated: use Client.Consume.
 func (r *RabbitMQConfig) HandleMessages(qName string, isProduction bool, messages <-chan amqp.Delivery, f func(d []byte) error) {
+	HandleDeliveries(qName, isProduction, messages, f)
+}
+
+// HandleDeliveries call f with the messages and block forever, it's shared
+// by the deprecated packages.
+//
+// Deprecated: use Client.Consume.
+func HandleDeliveries(qName string, isProduction bool, messages <-chan amqp.Delivery, f func(d []byte) error) {
 	forever := make(chan bool)
 
+	// non production consumers are auto ack
+	opts := &ConsumeOptions{AutoAck: !isProduction, Requeue: isProduction}
 	go func() {
 		for d := range messages {
 			logger.Info().Var("body", d.Body).Msg("receive a message")
-			err := f(d.Body)
-
-			if err != nil {
-				logger.Error().Err(err).Var("name", qName).Var("body", d.Body).Msg("error when handle a message")
-				errReject := d.Reject(isProduction)
-
-				if errReject != nil {
-					logger.Error().Err(errReject).Var("name", qName).Var("body", d.Body).Msg("error when requeue")
-				}
-			} else {
-				//Không phải production thì sẽ autoAck
-				if isProduction {
-					errAck := d.Ack(false)
-
-					if errAck != nil {
-						logger.Error().Err(errAck).Var("name", qName).Var("body", d.Body).Msg("error when ack")
-					}
-				}
-
-			}
+			handle(qName, opts, d, f)
 		}
 	}()
 
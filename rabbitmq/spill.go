@@ -0,0 +1,246 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrSpillFull is returned when a message can't be spilled because the buffer is full.
+var ErrSpillFull = errors.New("rabbitmq: spill buffer is full")
+
+// SpillConfig enable the spill buffer: confirmed messages failing with a
+// connection error or a nack are kept locally and published again in the
+// background, unroutable messages are not spilled.
+type SpillConfig struct {
+	// Dir keep spilled messages as files so they survive restarts, they are
+	// only kept in memory when empty.
+	Dir string
+	// MaxMessages is the max number of spilled messages, default to 10000.
+	MaxMessages int
+	// RetryInterval is the delay between two retries, default to 5 seconds.
+	RetryInterval time.Duration
+}
+
+type spilledMessage struct {
+	Exchange string          `json:"exchange"`
+	Key      string          `json:"key"`
+	Message  amqp.Publishing `json:"message"`
+
+	file string
+}
+
+// spill is the local buffer of messages waiting to be published again, in
+// the order they were spilled.
+type spill struct {
+	conf SpillConfig
+
+	mu       sync.Mutex
+	messages []*spilledMessage
+}
+
+func newSpill(conf SpillConfig) (*spill, error) {
+	if conf.MaxMessages <= 0 {
+		conf.MaxMessages = 10000
+	}
+	if conf.RetryInterval <= 0 {
+		conf.RetryInterval = 5 * time.Second
+	}
+	s := &spill{conf: conf}
+	if conf.Dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(conf.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return s, s.load()
+}
+
+// load the messages spilled before a restart.
+func (s *spill) load() error {
+	names, err := filepath.Glob(filepath.Join(s.conf.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	// names start with the spill time
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		m := &spilledMessage{file: name}
+		if err = json.Unmarshal(data, m); err != nil {
+			logger.Error().Err(err).Var("file", name).Msg("skip invalid spilled message")
+			continue
+		}
+		s.messages = append(s.messages, m)
+	}
+	return nil
+}
+
+func (s *spill) push(exchange, key string, msg amqp.Publishing) error {
+	// a new publish id is set when the message is published again
+	headers := make(amqp.Table, len(msg.Headers))
+	for k, v := range msg.Headers {
+		if k != publishIDHeader {
+			headers[k] = v
+		}
+	}
+	msg.Headers = headers
+	m := &spilledMessage{Exchange: exchange, Key: key, Message: msg}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) >= s.conf.MaxMessages {
+		return ErrSpillFull
+	}
+	if s.conf.Dir != "" {
+		if err := s.write(m); err != nil {
+			return err
+		}
+	}
+	s.messages = append(s.messages, m)
+	return nil
+}
+
+func (s *spill) write(m *spilledMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + hex.EncodeToString(b) + ".json"
+	m.file = filepath.Join(s.conf.Dir, name)
+
+	tmp := m.file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.file)
+}
+
+// pending return the spilled messages in order.
+func (s *spill) pending() []*spilledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*spilledMessage(nil), s.messages...)
+}
+
+func (s *spill) remove(m *spilledMessage) {
+	s.mu.Lock()
+	for i, cur := range s.messages {
+		if cur == m {
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if m.file != "" {
+		if err := os.Remove(m.file); err != nil && !os.IsNotExist(err) {
+			logger.Error().Err(err).Var("file", m.file).Msg("failed to remove spilled message")
+		}
+	}
+}
+
+func (s *spill) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages)
+}
+
+type spillRequest struct {
+	conf *Confirmation
+	err  error
+}
+
+// spillLoop spill the messages handed by resolve until the client is
+// closed, the requests handed before Close are spilled too.
+func (c *Client) spillLoop() {
+	defer c.spilling.Done()
+	for {
+		select {
+		case req := <-c.spills:
+			c.spillConfirmation(req.conf, req.err)
+		case <-c.done:
+			for {
+				select {
+				case req := <-c.spills:
+					c.spillConfirmation(req.conf, req.err)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// spillConfirmation spill the message and complete its confirmation, the
+// publish error is kept when the message can't be spilled.
+func (c *Client) spillConfirmation(conf *Confirmation, err error) {
+	if spillErr := c.spill.push(conf.Exchange, conf.Key, conf.Message); spillErr != nil {
+		logger.Error().Err(spillErr).Var("exchange", conf.Exchange).Var("key", conf.Key).Msg("failed to spill message")
+	} else {
+		conf.spilled = true
+		err = nil
+	}
+	c.complete(conf, err)
+}
+
+// retrySpilled publish the spilled messages again until the client is closed.
+func (c *Client) retrySpilled() {
+	defer c.spilling.Done()
+
+	ticker := time.NewTicker(c.spill.conf.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		if !c.publisherConn.connected() {
+			continue
+		}
+
+		for _, m := range c.spill.pending() {
+			ctx, cancel := context.WithTimeout(context.Background(), c.spill.conf.RetryInterval)
+			conf := newConfirmation(m.Exchange, m.Key, m.Message)
+			conf.noSpill = true
+			err := c.publishConfirmed(ctx, conf)
+			if err == nil {
+				err = conf.Wait(ctx)
+			}
+			cancel()
+
+			if err != nil && !errors.Is(err, ErrUnroutable) {
+				// still failing, try again on the next tick
+				logger.Error().Err(err).Var("pending", c.spill.len()).Msg("failed to publish spilled messages")
+				break
+			}
+			if err != nil {
+				logger.Error().Err(err).Var("exchange", m.Exchange).Var("key", m.Key).Msg("drop unroutable spilled message")
+			}
+			c.spill.remove(m)
+		}
+	}
+}
+
+// isTransient report whether publishing again may succeed.
+func isTransient(err error) bool {
+	return errors.Is(err, ErrNacked) || errors.Is(err, amqp.ErrClosed) || errors.Is(err, errNotConnected)
+}
@@ -0,0 +1,125 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Exchange is an exchange of the topology.
+type Exchange struct {
+	Name string
+	// Kind is default to topic.
+	Kind       string
+	Durable    bool
+	AutoDelete bool
+	Internal   bool
+	Args       amqp.Table
+}
+
+// Queue is a queue of the topology.
+type Queue struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+	Args       amqp.Table
+}
+
+// Binding bind a queue to an exchange with a routing key.
+type Binding struct {
+	Queue    string
+	Exchange string
+	Key      string
+	Args     amqp.Table
+}
+
+// Topology is declared when the client connects and again after every
+// reconnect, so non durable exchanges and queues are restored too.
+// EX used:
+//
+//	rabbitmq.Topology{
+//		Exchanges: []rabbitmq.Exchange{{Name: "orders", Durable: true}},
+//		Queues:    []rabbitmq.Queue{{Name: "orders.created", Durable: true}},
+//		Bindings:  []rabbitmq.Binding{{Queue: "orders.created", Exchange: "orders", Key: "orders.created"}},
+//	}
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []Queue
+	Bindings  []Binding
+}
+
+// declarer is the part of *amqp.Channel used to declare a topology.
+type declarer interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// declare exchanges, then queues, then bindings.
+func (t Topology) declare(ch declarer) error {
+	for _, e := range t.Exchanges {
+		kind := e.Kind
+		if kind == "" {
+			kind = amqp.ExchangeTopic
+		}
+		if err := ch.ExchangeDeclare(e.Name, kind, e.Durable, e.AutoDelete, e.Internal, false, e.Args); err != nil {
+			return err
+		}
+	}
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, q.Args); err != nil {
+			return err
+		}
+	}
+	for _, b := range t.Bindings {
+		if err := ch.QueueBind(b.Queue, b.Key, b.Exchange, false, b.Args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge add the exchanges, queues and bindings of other that are not in t yet.
+func (t *Topology) merge(other Topology) {
+	for _, e := range other.Exchanges {
+		if !t.hasExchange(e.Name) {
+			t.Exchanges = append(t.Exchanges, e)
+		}
+	}
+	for _, q := range other.Queues {
+		if !t.hasQueue(q.Name) {
+			t.Queues = append(t.Queues, q)
+		}
+	}
+	for _, b := range other.Bindings {
+		if !t.hasBinding(b) {
+			t.Bindings = append(t.Bindings, b)
+		}
+	}
+}
+
+func (t *Topology) hasExchange(name string) bool {
+	for _, e := range t.Exchanges {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Topology) hasQueue(name string) bool {
+	for _, q := range t.Queues {
+		if q.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Topology) hasBinding(b Binding) bool {
+	for _, cur := range t.Bindings {
+		if cur.Queue == b.Queue && cur.Exchange == b.Exchange && cur.Key == b.Key {
+			return true
+		}
+	}
+	return false
+}
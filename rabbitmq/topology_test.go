@@ -0,0 +1,49 @@
+package rabbitmq
+
+import (
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	r.calls = append(r.calls, "exchange "+name+" "+kind)
+	return nil
+}
+
+func (r *recorder) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	r.calls = append(r.calls, "queue "+name)
+	return amqp.Queue{Name: name}, nil
+}
+
+func (r *recorder) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	r.calls = append(r.calls, "bind "+name+" "+exchange+" "+key)
+	return nil
+}
+
+func TestTopology(t *testing.T) {
+	topology := Topology{
+		Bindings:  []Binding{{Queue: "orders.created", Exchange: "orders", Key: "orders.created"}},
+		Queues:    []Queue{{Name: "orders.created", Durable: true}},
+		Exchanges: []Exchange{{Name: "orders", Durable: true}},
+	}
+	topology.merge(Topology{
+		Queues:   []Queue{{Name: "orders.created"}, {Name: "orders.paid"}},
+		Bindings: []Binding{{Queue: "orders.created", Exchange: "orders", Key: "orders.created"}},
+	})
+
+	r := &recorder{}
+	if err := topology.declare(r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "exchange orders topic|queue orders.created|queue orders.paid|bind orders.created orders orders.created"
+	if got := strings.Join(r.calls, "|"); got != want {
+		t.Fatalf("unexpected declarations:\n%s\n%s", got, want)
+	}
+}
@@ -1,12 +1,14 @@
+// Package rabbitmq is a deprecated adapter over the root rabbitmq package,
+// new code should use rabbitmq.New and rabbitmq.Client.
 package rabbitmq
 
 import (
-	"fmt"
-	_ "reflect"
+	"context"
 	"sync"
 	"time"
 
 	"github.com/ponlv/go-kit/plog"
+	kitrabbitmq "github.com/ponlv/go-kit/rabbitmq"
 
 	"github.com/pkg/errors"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -14,6 +16,9 @@ import (
 
 var logger = plog.NewBizLogger("rabbitmq")
 
+// connectTimeout bound how long Connect wait for the first connection.
+const connectTimeout = 10 * time.Second
+
 type Config struct {
 	Uri                  string
 	ChannelNotifyTimeout time.Duration
@@ -29,10 +34,14 @@ type Config struct {
 	MainQueue    string
 }
 
+// RabbitMQ wrap a rabbitmq.Client, reconnection is managed by the client.
+//
+// Deprecated: use rabbitmq.Client.
 type RabbitMQ struct {
 	mux                  sync.RWMutex
 	config               Config
 	dialConfig           amqp.Config
+	client               *kitrabbitmq.Client
 	connection           *amqp.Connection
 	ChannelNotifyTimeout time.Duration
 	ExchangeName         string
@@ -42,15 +51,16 @@ type RabbitMQ struct {
 	MainQueue            string
 	UniqueChannel        *amqp.Channel
 	ChannelTrackArray    map[string]*amqp.Channel
-	CurrRetryTime        int64
-	OldRetryTime         int64
-	Durable              bool
+	// Deprecated: reconnect delays are managed by rabbitmq.Client.
+	CurrRetryTime int64
+	// Deprecated: reconnect delays are managed by rabbitmq.Client.
+	OldRetryTime int64
+	Durable      bool
 }
 
 var RabbitConnector *RabbitMQ
 
-// var ChannelTrackArray map[string]*amqp.Channel
-
+// Deprecated: use rabbitmq.New.
 func New(config Config) *RabbitMQ {
 	return &RabbitMQ{
 		config:               config,
@@ -63,116 +73,107 @@ func New(config Config) *RabbitMQ {
 	}
 }
 
-// Connect creates a new connection. Use once at application
-// startup.
+// Connect creates a new client. Use once at application startup.
 func (r *RabbitMQ) Connect() error {
-	con, err := amqp.DialConfig(r.config.Uri, r.dialConfig)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	client, err := kitrabbitmq.New(ctx, kitrabbitmq.Config{URL: r.config.Uri, Dial: r.dialConfig})
+	if err != nil {
+		return err
+	}
+	conn, err := client.Connection(ctx)
 	if err != nil {
+		client.Close()
 		return err
 	}
-	r.connection = con
-	go r.reconnect()
+
+	r.mux.Lock()
+	r.client = client
+	r.connection = conn
+	r.mux.Unlock()
 	return nil
 }
 
+// Client return the underlying client, nil before Connect.
+func (r *RabbitMQ) Client() *kitrabbitmq.Client {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.client
+}
+
+// getClient return the client, connecting once if needed.
+func (r *RabbitMQ) getClient() (*kitrabbitmq.Client, error) {
+	if client := r.Client(); client != nil {
+		return client, nil
+	}
+	if err := r.Connect(); err != nil {
+		return nil, errors.New("connection is not open")
+	}
+	return r.Client(), nil
+}
+
 // Channel returns a new `*amqp.Channel` instance. You must
 // call `defer channel.Close()` as soon as you obtain one.
-// Sometimes the connection might be closed unintentionally so
-// as a graceful handling, try to connect only once.
 func (r *RabbitMQ) Channel() (*amqp.Channel, error) {
-	if r.connection == nil {
-		if err := r.Connect(); err != nil {
-			return nil, errors.New("connection is not open")
-		}
-	}
-	// if r.UniqueChannel != nil {
-	// 	log.Info("get exet channel to publish: ", *r.UniqueChannel)
-	// 	return r.UniqueChannel, nil
-	// }
-	channel, err := r.connection.Channel()
+	client, err := r.getClient()
 	if err != nil {
 		return nil, err
 	}
-	// r.UniqueChannel = channel
-	return channel, nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	return client.Channel(ctx)
 }
 
-// Channel returns a new `*amqp.Channel` instance. You must
-// call `defer channel.Close()` as soon as you obtain one.
-// Sometimes the connection might be closed unintentionally so
-// as a graceful handling, try to connect only once.
+// ChannelByName returns the channel tracked with the name, a new
+// channel is opened when it does not exist or was closed.
 func (r *RabbitMQ) ChannelByName(name string) (*amqp.Channel, error) {
-	if r.connection == nil {
-		if err := r.Connect(); err != nil {
-			return nil, errors.New("connection is not open")
-		}
+	r.mux.RLock()
+	storeChannel, ok := r.ChannelTrackArray[name]
+	r.mux.RUnlock()
+	if ok && !storeChannel.IsClosed() {
+		return storeChannel, nil
 	}
-	storeChannel, errChannel := r.ChannelTrackArray[name]
-	if !errChannel {
-		channel, err := r.connection.Channel()
-		if err != nil {
-			return nil, err
-		}
-		r.ChannelTrackArray[name] = channel
-		return channel, nil
+
+	channel, err := r.Channel()
+	if err != nil {
+		return nil, err
 	}
-	return storeChannel, nil
+	r.mux.Lock()
+	r.ChannelTrackArray[name] = channel
+	r.mux.Unlock()
+	return channel, nil
 }
 
-// Connection exposes the essentials of the current connection.
+// Connection exposes the current connection.
 // You should not normally use this but it is there for special
 // use cases.
 func (r *RabbitMQ) Connection() *amqp.Connection {
-	return r.connection
+	client := r.Client()
+	if client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	conn, err := client.Connection(ctx)
+	if err != nil {
+		return r.connection
+	}
+	return conn
 }
 
 // Shutdown triggers a normal shutdown. Use this when you wish
 // to shutdown your current connection or if you are shutting
 // down the application.
 func (r *RabbitMQ) Shutdown() error {
-	if r.connection != nil {
-		return r.connection.Close()
-	}
 	r.cleanChannelTrack()
 	r.UniqueChannel = nil
-	return nil
-}
-
-// reconnect reconnects to server if the connection or a channel
-// is closed unexpectedly. Normal shutdown is ignored. It tries
-// maximum of 7200 times and sleeps half a second in between
-// each try which equals to 1 hour.
-func (r *RabbitMQ) reconnect() {
-WATCH:
-
-	conErr := <-r.connection.NotifyClose(make(chan *amqp.Error))
-	if conErr != nil {
-		logger.Error().Err(conErr).Msg("Connection dropped, reconnecting")
-		r.cleanChannelTrack()
-		r.UniqueChannel = nil
-		var err error
-
-		// for i := 1; i <= r.config.Reconnect.MaxAttempt; i++ {
-		for {
-			r.mux.RLock()
-			r.connection, err = amqp.DialConfig(r.config.Uri, r.dialConfig)
-			r.mux.RUnlock()
-
-			if err == nil {
-				goto WATCH
-			}
-			logger.Error().Err(err).Msg("Failed to reconnect")
-			// time.Sleep(r.config.Reconnect.Interval)
-			currRetryTime := r.CurrRetryTime
-			newNextRetryTime := r.OldRetryTime + r.CurrRetryTime // next time retry in the future with fibo
-			r.OldRetryTime = currRetryTime                       // in the future, current of now  become old
-			r.CurrRetryTime = newNextRetryTime                   // for the future
-			time.Sleep(time.Duration(currRetryTime) * time.Second)
-		}
-	} else {
-		r.cleanChannelTrack()
-		r.UniqueChannel = nil
+	if client := r.Client(); client != nil {
+		return client.Close()
 	}
+	return nil
 }
 
 func (r *RabbitMQ) Ping() error {
@@ -187,41 +188,34 @@ func (r *RabbitMQ) Ping() error {
 }
 
 func (r *RabbitMQ) Setup(name, exchange string, durable bool) *amqp.Queue {
-	channel, err := r.Channel()
-	if err != nil {
+	if _, err := r.getClient(); err != nil {
 		return nil
 	}
-	defer channel.Close()
-	q := r.DeclareQueue(channel, name, exchange, durable)
+	q := r.DeclareQueue(nil, name, exchange, durable)
 	return &q
 }
 
+// DeclareQueue declare the queue bound to the exchange with the key "exchange.queue",
+// the queue is declared again after reconnects.
+//
+// Deprecated: use rabbitmq.Client.Declare.
 func (r *RabbitMQ) DeclareQueue(ch *amqp.Channel, name string, exchange string, durable bool) amqp.Queue {
-	ch, _ = r.Channel()
-	defer ch.Close()
-	q, err := ch.QueueDeclare(
-		name,    // name
-		durable, // durable
-		false,   // delete when unused
-		false,   // exclusive
-		false,   // no-wait
-		nil,     // arguments
-	)
+	client, err := r.getClient()
 	if err != nil {
 		logger.Error().Err(err).Var("name", name).Msg("failed to declare queue rabbitmq")
+		return amqp.Queue{Name: name}
 	}
-	err = ch.QueueBind(
-		q.Name,                                 // queue name
-		fmt.Sprintf("%s.%s", exchange, q.Name), // routing key
-		exchange,                               // exchange
-		false,
-		nil,
-	)
-	if err != nil {
-		logger.Error().Err(err).Var("name", name).Msg("failed to binding queue rabbitmq")
+
+	topology := kitrabbitmq.Topology{Queues: []kitrabbitmq.Queue{{Name: name, Durable: durable}}}
+	if exchange != "" {
+		topology.Bindings = []kitrabbitmq.Binding{{Queue: name, Exchange: exchange, Key: exchange + "." + name}}
+	}
+	if err = client.Declare(context.Background(), topology); err != nil {
+		logger.Error().Err(err).Var("name", name).Msg("failed to declare queue rabbitmq")
+	} else {
+		logger.Info().Var("name", name).Msg("declare queue success")
 	}
-	logger.Info().Var("name", name).Msg("declare queue success")
-	return q
+	return amqp.Queue{Name: name}
 }
 
 func (r *RabbitMQ) CreateTempQueue(callerId string, duration, autodelete, exclusive, nowait bool) error {
@@ -242,8 +236,16 @@ func (r *RabbitMQ) CreateTempQueue(callerId string, duration, autodelete, exclus
 	}
 	return nil
 }
+
+// Consume start consuming the queue on a new channel.
+//
+// Deprecated: use rabbitmq.Client.Consume, it resumes consuming after reconnects.
 func (r *RabbitMQ) Consume(ch *amqp.Channel, q amqp.Queue, opt Option) <-chan amqp.Delivery {
-	ch, _ = r.Channel()
+	ch, err := r.Channel()
+	if err != nil {
+		logger.Error().Err(err).Var("name", q.Name).Msg("failed to register consumer")
+		return nil
+	}
 	messages, err := ch.Consume(
 		q.Name,        // queue
 		q.Name,        // consumer
@@ -280,126 +282,63 @@ func (r *RabbitMQ) LeftTopic(topicName, consumer string, noWait bool) error {
 	}
 	err := channel.Cancel(consumer, noWait)
 	defer channel.Close()
+	r.mux.Lock()
 	delete(r.ChannelTrackArray, topicName)
+	r.mux.Unlock()
 	if err != nil {
 		return errors.Wrap(err, "failed to declare queue")
 	}
 	return nil
 }
-func (r *RabbitMQ) Publish(ch *amqp.Channel, q amqp.Queue, data []byte) error {
-	channel, err := r.Channel()
-	if err != nil {
-		return errors.Wrap(err, "failed to open channel")
-	}
-	defer channel.Close()
-	if err := channel.Confirm(false); err != nil {
-		r.UniqueChannel = nil
-		return errors.Wrap(err, "failed to put channel in confirmation mode")
-	}
-	if err := channel.Publish(
-		"",
-		q.Name,
-		true,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        data,
-		},
-	); err != nil {
-		return errors.Wrap(err, "failed to publish message")
-	}
-	select {
-	case ntf := <-channel.NotifyPublish(make(chan amqp.Confirmation, 1)):
-		if !ntf.Ack {
-			return errors.New("failed to deliver message to exchange/queue")
-		}
-	case <-channel.NotifyReturn(make(chan amqp.Return)):
-		return errors.New("failed to deliver message to exchange/queue")
-	case <-time.After(r.ChannelNotifyTimeout):
-		logger.Error().Err(err).Msg("message delivery confirmation to exchange/queue timed out")
-	}
 
-	return nil
+// Publish send data to the queue through the default exchange and wait for
+// the broker to confirm it.
+//
+// Deprecated: use rabbitmq.Client.PublishAndConfirm.
+func (r *RabbitMQ) Publish(ch *amqp.Channel, q amqp.Queue, data []byte) error {
+	return r.PublishContext(q.Name, data)
 }
 
+// PublishContext send data to the queue through the default exchange and
+// wait for the broker to confirm it, an unknown queue is returned as
+// rabbitmq.ErrUnroutable.
+//
+// Deprecated: use rabbitmq.Client.PublishAndConfirm.
 func (r *RabbitMQ) PublishContext(queueName string, data []byte) error {
-	channel, err := r.Channel()
+	client, err := r.getClient()
 	if err != nil {
 		return errors.Wrap(err, "failed to open channel")
 	}
-	defer channel.Close()
-	if err := channel.Confirm(false); err != nil {
-		r.UniqueChannel = nil
-		return errors.Wrap(err, "failed to put channel in confirmation mode")
+
+	ctx := context.Background()
+	if r.ChannelNotifyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ChannelNotifyTimeout)
+		defer cancel()
 	}
-	err = channel.Publish("", queueName, true, false, amqp.Publishing{
+	err = client.PublishAndConfirm(ctx, "", queueName, amqp.Publishing{
 		ContentType: "text/plain",
 		Body:        data,
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to publish message")
 	}
-	select {
-	case ntf := <-channel.NotifyPublish(make(chan amqp.Confirmation, 1)):
-		if !ntf.Ack {
-			return errors.New("failed to deliver message to exchange/queue")
-		}
-	case <-channel.NotifyReturn(make(chan amqp.Return)):
-		return errors.New("failed to deliver message to exchange/queue")
-	case <-time.After(r.ChannelNotifyTimeout):
-		logger.Error().Err(err).Msg("message delivery confirmation to exchange/queue timed out")
-	}
 	return nil
 }
 
 func (r *RabbitMQ) cleanChannelTrack() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
 	for k := range r.ChannelTrackArray {
 		delete(r.ChannelTrackArray, k)
 	}
 }
+
+// Deprecated: use rabbitmq.Client.Consume.
 func (r *RabbitMQ) HandleMessages(qName string, isProduction bool, messages <-chan amqp.Delivery, f func(d []byte) error) {
-	forever := make(chan bool)
-	go func() {
-		for d := range messages {
-			logger.Info().Var("body", d.Body).Msg("receive a message")
-			err := f(d.Body)
-
-			if err != nil {
-				logger.Error().Err(err).Var("name", qName).Var("body", d.Body).Msg("error when handle a message")
-				errReject := d.Reject(isProduction)
-
-				if errReject != nil {
-					logger.Error().Err(errReject).Var("name", qName).Var("body", d.Body).Msg("error when requeue")
-				}
-			} else {
-				//Không phải production thì sẽ autoAck
-				if isProduction {
-					errAck := d.Ack(false)
-
-					if errAck != nil {
-						logger.Error().Err(errAck).Var("name", qName).Var("body", d.Body).Msg("error when ack")
-					}
-				}
-
-			}
-		}
-	}()
-
-	<-forever
+	kitrabbitmq.HandleDeliveries(qName, isProduction, messages, f)
 }
 
-type Option struct {
-	AutoACK   bool
-	Exclusive bool
-	NoLocal   bool
-	NoWait    bool
-	Args      amqp.Table
-}
+type Option = kitrabbitmq.Option
 
-var DefaultOption = Option{
-	AutoACK:   false,
-	Exclusive: false,
-	NoLocal:   false,
-	NoWait:    false,
-	Args:      nil,
-}
+var DefaultOption = kitrabbitmq.DefaultOption
@@ -1,126 +1,117 @@
+// Package rabbitmq is a deprecated adapter over the root rabbitmq package,
+// new code should use rabbitmq.New and rabbitmq.Client.
 package rabbitmq
 
 import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"sync"
 
 	"github.com/ponlv/go-kit/plog"
+	kitrabbitmq "github.com/ponlv/go-kit/rabbitmq"
 
-	"github.com/makasim/amqpextra"
-	"github.com/makasim/amqpextra/consumer"
-	"github.com/makasim/amqpextra/publisher"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-var dialer *amqpextra.Dialer
 var logger = plog.NewBizLogger("rabbitmq")
 
+// errNoClient is returned when Init was not called.
+var errNoClient = errors.New("rabbitmq: no dialer available")
+
+// errNack reject a message without requeue in ConsumerParallel.
+var errNack = errors.New("rabbitmq: message nacked by handler")
+
+var (
+	mu     sync.RWMutex
+	client *kitrabbitmq.Client
+)
+
 type PublishRequest struct {
 	Exchange string
 	Key      string
 	Message  interface{}
 }
 
+// Init create the shared client, it connects in background so an
+// unreachable broker does not fail Init, calls wait for the connection.
+//
+// Deprecated: use rabbitmq.New.
 func Init(uri string) error {
-	var err error
-
-	dialer, err = amqpextra.NewDialer(amqpextra.WithURL(uri))
+	c, err := kitrabbitmq.New(context.Background(), kitrabbitmq.Config{URL: uri, Lazy: true})
 	if err != nil {
 		return err
 	}
 
-	logger.Info().Msg("connection to RabbitMQ is established")
+	mu.Lock()
+	prev := client
+	client = c
+	mu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+
+	logger.Info().Msg("connection to RabbitMQ is initialized")
 	return nil
 }
 
-func Publish(ctx context.Context, data PublishRequest) error {
+// Client return the client connected by Init, nil before.
+func Client() *kitrabbitmq.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return client
+}
 
-	if dialer == nil {
-		logger.Error().Err(errors.New("rabbitmq: no dialer available")).Send()
-		return errors.New("rabbitmq: no dialer available")
+func getClient() (*kitrabbitmq.Client, error) {
+	c := Client()
+	if c == nil {
+		logger.Error().Err(errNoClient).Send()
+		return nil, errNoClient
 	}
+	return c, nil
+}
 
-	p, err := amqpextra.NewPublisher(
-		dialer.ConnectionCh(),
-		publisher.WithRestartSleep(0),
-	)
+// Publish send the message encoded as JSON and wait for the broker to
+// confirm it, a message routed to no queue is returned as rabbitmq.ErrUnroutable.
+//
+// Deprecated: use rabbitmq.Client.PublishAndConfirm.
+func Publish(ctx context.Context, data PublishRequest) error {
+	c, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-	defer p.Close()
 
 	bytes, err := json.Marshal(data.Message)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
-	err = p.Publish(publisher.Message{
-		Context:   ctx,
-		Exchange:  data.Exchange,
-		Key:       data.Key,
-		Mandatory: true,
-		Immediate: false,
-		Publishing: amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        bytes,
-		},
+	err = c.PublishAndConfirm(ctx, data.Exchange, data.Key, amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        bytes,
 	})
 	if err != nil {
 		logger.Error().Err(err).Var("key", data.Key).Msg("publish failed")
 		return err
 	}
-
 	return nil
 }
 
+// DeclareQueue declare the topic exchange and the durable queue bound with
+// the key "exchange.queue", they are declared again after reconnects.
+//
+// Deprecated: use rabbitmq.Client.Declare.
 func DeclareQueue(ctx context.Context, exchange, queue string) error {
-
-	if dialer == nil {
-		logger.Error().Err(errors.New("rabbitmq: no dialer available")).Send()
-		return errors.New("rabbitmq: no dialer available")
-	}
-
-	con, err := dialer.Connection(ctx)
-	if err != nil {
-		logger.Error().Err(err).Send()
-		return err
-	}
-
-	ch, err := con.Channel()
-	if err != nil {
-		logger.Error().Err(err).Send()
-		return err
-	}
-	defer ch.Close()
-
-	// create exchange
-	err = ch.ExchangeDeclare(exchange,
-		amqp.ExchangeTopic,
-		true,         // durable
-		false,        // auto-deleted
-		false,        // internal
-		false,        // no-wait
-		amqp.Table{}) // arguments)
-	if err != nil {
-		logger.Error().Err(err).Send()
-		return err
-	}
-
-	q, err := ch.QueueDeclare(queue, true, false, false, false, nil)
+	c, err := getClient()
 	if err != nil {
-		logger.Error().Err(err).Send()
 		return err
 	}
 
-	err = ch.QueueBind(
-		q.Name,
-		fmt.Sprintf("%s.%s", exchange, q.Name),
-		exchange,
-		false,
-		nil,
-	)
+	err = c.Declare(ctx, kitrabbitmq.Topology{
+		Exchanges: []kitrabbitmq.Exchange{{Name: exchange, Kind: amqp.ExchangeTopic, Durable: true}},
+		Queues:    []kitrabbitmq.Queue{{Name: queue, Durable: true}},
+		Bindings:  []kitrabbitmq.Binding{{Queue: queue, Exchange: exchange, Key: exchange + "." + queue}},
+	})
 	if err != nil {
 		logger.Error().Err(err).Send()
 		return err
@@ -130,64 +121,42 @@ func DeclareQueue(ctx context.Context, exchange, queue string) error {
 	return nil
 }
 
+// Consumer call handle with the messages of the queue until ctx is done,
+// messages are acked even when handle fails.
+//
+// Deprecated: use rabbitmq.Client.Consume.
 func Consumer(ctx context.Context, exchange, queue string, handle func([]byte) error) {
-
-	if dialer == nil {
-		logger.Error().Err(errors.New("rabbitmq: no dialer available")).Send()
+	c, err := getClient()
+	if err != nil {
 		return
 	}
-	h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
-		bb := msg.Body
-		err := handle(bb)
-		if err != nil {
-			fmt.Println(err)
+
+	_ = c.Consume(ctx, queue, nil, func(d []byte) error {
+		if err := handle(d); err != nil {
+			logger.Error().Err(err).Var("queue", queue).Msg("error when handle a message")
 		}
-		return msg.Ack(false)
+		return nil
 	})
-
-	_, err := dialer.Consumer(
-		consumer.WithContext(ctx),
-		consumer.WithQueue(queue),
-		consumer.WithHandler(h),
-	)
-	if err != nil {
-		return
-	}
-	<-ctx.Done()
-	return
 }
 
+// ConsumerParallel call handle with numWorkers workers until ctx is done,
+// messages are nacked without requeue when handle return true.
+//
+// Deprecated: use rabbitmq.Client.Consume.
 func ConsumerParallel(ctx context.Context, exchange, queue string, numWorkers int, handle func([]byte) (bool, error)) {
-
-	if dialer == nil {
-		logger.Error().Err(errors.New("rabbitmq: no dialer available")).Send()
+	c, err := getClient()
+	if err != nil {
 		return
 	}
 
-	h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
-		bb := msg.Body
-		isNack, err := handle(bb)
+	_ = c.Consume(ctx, queue, &kitrabbitmq.ConsumeOptions{Concurrency: numWorkers}, func(d []byte) error {
+		isNack, err := handle(d)
 		if err != nil {
-			fmt.Println(err)
+			logger.Error().Err(err).Var("queue", queue).Msg("error when handle a message")
 		}
-
 		if isNack {
-			return msg.Nack(false, false)
+			return errNack
 		}
-		return msg.Ack(false)
+		return nil
 	})
-
-	c, err := dialer.Consumer(
-		consumer.WithContext(ctx),
-		//consumer.WithExchange(exchange, fmt.Sprintf("%s.%s", exchange, queue)),
-		consumer.WithQueue(queue),
-		consumer.WithHandler(h),
-		consumer.WithWorker(consumer.NewParallelWorker(numWorkers)),
-	)
-	if err != nil {
-		return
-	}
-	defer c.Close()
-	<-ctx.Done()
-	return
 }
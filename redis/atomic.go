@@ -0,0 +1,101 @@
+package searedis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// compareAndSetScript set the value if the current value is the expected one.
+// KEYS[1]: key, ARGV: expect missing (1/0), expected, value, ttl (ms)
+var compareAndSetScript = RegisterScript("compare_and_set", `
+local current = redis.call("GET", KEYS[1])
+if ARGV[1] == "1" then
+  if current then
+    return 0
+  end
+elseif current ~= ARGV[2] then
+  return 0
+end
+if tonumber(ARGV[4]) > 0 then
+  redis.call("SET", KEYS[1], ARGV[3], "PX", ARGV[4])
+else
+  redis.call("SET", KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// compareAndDeleteScript delete the key if the current value is the expected one.
+// KEYS[1]: key, ARGV: expected
+var compareAndDeleteScript = RegisterScript("compare_and_delete", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// incrCappedScript increase the counter unless it goes over the max, the
+// ttl is set when the counter is created.
+// KEYS[1]: key, ARGV: increment, max, ttl (ms)
+var incrCappedScript = RegisterScript("incr_capped", `
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local incr = tonumber(ARGV[1])
+if current + incr > tonumber(ARGV[2]) then
+  return {current, 0}
+end
+local value = redis.call("INCRBY", KEYS[1], incr)
+if value == incr and tonumber(ARGV[3]) > 0 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return {value, 1}
+`)
+
+// CompareAndSet set the value if the current value equals expected, nil
+// expected means the key must not exist. Values are encoded as JSON, same
+// as SetObject.
+// EX used: ok, err := util.CompareAndSet(ctx, "key", oldModel, newModel, 86400)
+func CompareAndSet(ctx context.Context, key string, expected, value interface{}, expirationSecond int) (bool, error) {
+	missing, expectedStr := "1", ""
+	if expected != nil {
+		data, err := json.Marshal(expected)
+		if err != nil {
+			return false, err
+		}
+		missing, expectedStr = "0", string(data)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	ttl := (time.Duration(expirationSecond) * time.Second).Milliseconds()
+	n, err := compareAndSetScript.Run(ctx, client, []string{key}, missing, expectedStr, data, ttl).Int()
+	return n == 1, err
+}
+
+// CompareAndDelete delete the key if the current value equals expected,
+// it's encoded as JSON, same as SetObject.
+func CompareAndDelete(ctx context.Context, key string, expected interface{}) (bool, error) {
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := compareAndDeleteScript.Run(ctx, client, []string{key}, data).Int()
+	return n == 1, err
+}
+
+// IncrCapped increase the counter by incr unless it would go over max, and
+// return the counter value and whether it was increased. The expiration
+// is set when the counter is created, 0 means no expiration.
+func IncrCapped(ctx context.Context, key string, incr, max int64, expirationSecond int) (int64, bool, error) {
+	ttl := (time.Duration(expirationSecond) * time.Second).Milliseconds()
+	values, err := incrCappedScript.Run(ctx, client, []string{key}, incr, max, ttl).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(values) != 2 {
+		return 0, false, errInvalidScriptResult
+	}
+	return values[0], values[1] == 1, nil
+}
@@ -0,0 +1,78 @@
+package searedis
+
+import (
+	"context"
+
+	"github.com/ponlv/go-kit/breaker"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+type breakerDoneKey struct{}
+
+// BreakerHook call commands and pipelines through the breaker, network
+// errors count as failures but error replies such as WRONGTYPE and
+// redis.Nil do not. Commands fail with breaker.ErrOpen while the circuit is open.
+// EX used: GetClient().AddHook(BreakerHook(breaker.New(breaker.Settings{Name: "redis"})))
+func BreakerHook(b *breaker.Breaker) goredislib.Hook {
+	return &breakerHook{breaker: b}
+}
+
+type breakerHook struct {
+	breaker *breaker.Breaker
+}
+
+func (h *breakerHook) BeforeProcess(ctx context.Context, cmd goredislib.Cmder) (context.Context, error) {
+	return h.allow(ctx)
+}
+
+func (h *breakerHook) AfterProcess(ctx context.Context, cmd goredislib.Cmder) error {
+	h.done(ctx, cmd.Err())
+	return nil
+}
+
+func (h *breakerHook) BeforeProcessPipeline(ctx context.Context, cmds []goredislib.Cmder) (context.Context, error) {
+	return h.allow(ctx)
+}
+
+func (h *breakerHook) AfterProcessPipeline(ctx context.Context, cmds []goredislib.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if isBreakerFailure(cmd.Err()) {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.done(ctx, err)
+	return nil
+}
+
+func (h *breakerHook) allow(ctx context.Context) (context.Context, error) {
+	done, err := h.breaker.Allow()
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, breakerDoneKey{}, done), nil
+}
+
+// done record the result, AfterProcess is also called for commands
+// rejected by the breaker and those have no done func in ctx.
+func (h *breakerHook) done(ctx context.Context, err error) {
+	done, ok := ctx.Value(breakerDoneKey{}).(func(err error))
+	if !ok {
+		return
+	}
+	if isBreakerFailure(err) {
+		done(err)
+	} else {
+		done(nil)
+	}
+}
+
+func isBreakerFailure(err error) bool {
+	if err == nil || err == goredislib.Nil {
+		return false
+	}
+	_, reply := err.(goredislib.Error)
+	return !reply
+}
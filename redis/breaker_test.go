@@ -0,0 +1,72 @@
+package searedis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ponlv/go-kit/breaker"
+	"github.com/ponlv/go-kit/internal/redistest"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+func TestBreakerHook(t *testing.T) {
+	_, c := redistest.NewServer(t, func(args []string) interface{} {
+		switch strings.ToLower(args[1]) {
+		case "value":
+			return []byte("1")
+		case "list":
+			return errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		return nil
+	})
+	ctx := context.Background()
+
+	// replies of a healthy server are not failures
+	wrongType := c.Get(ctx, "list").Err()
+	tests := []struct {
+		err     error
+		failure bool
+	}{
+		{nil, false},
+		{c.Get(ctx, "value").Err(), false},
+		{c.Get(ctx, "missing").Err(), false},
+		{wrongType, false},
+		{io.EOF, true},
+		{errors.New("dial tcp: connection refused"), true},
+	}
+	if wrongType == nil {
+		t.Fatal("expected a WRONGTYPE error")
+	}
+	for _, tt := range tests {
+		if got := isBreakerFailure(tt.err); got != tt.failure {
+			t.Errorf("isBreakerFailure(%v) = %v, want %v", tt.err, got, tt.failure)
+		}
+	}
+
+	b := breaker.New(breaker.Settings{Name: "redis", ConsecutiveFailures: 1})
+	c.AddHook(BreakerHook(b))
+	c.Get(ctx, "missing")
+	c.Get(ctx, "list")
+	pipe := c.Pipeline()
+	pipe.Get(ctx, "value")
+	pipe.Get(ctx, "missing")
+	pipe.Exec(ctx)
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("expected closed, got %s", b.State())
+	}
+
+	// a network error trips the breaker, the next command is rejected
+	down := goredislib.NewClient(&goredislib.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer down.Close()
+	down.AddHook(BreakerHook(b))
+	if err := down.Get(ctx, "value").Err(); err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if err := c.Get(ctx, "value").Err(); err != breaker.ErrOpen {
+		t.Fatalf("expected ErrOpen, got %v", err)
+	}
+}
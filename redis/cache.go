@@ -0,0 +1,325 @@
+package searedis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"time"
+
+	goredislib "github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+)
+
+// envelope flags
+const (
+	flagGzip byte = 1 << iota
+)
+
+var errInvalidEnvelope = errors.New("redis: invalid cache value")
+
+// CacheOptions struct contain options of Cache.
+type CacheOptions struct {
+	// Namespace is prefixed to every key as "<namespace>:<key>".
+	Namespace string
+	// Codec is default to JSONCodec.
+	Codec Codec
+	// CompressThreshold enable gzip for encoded values bigger than
+	// this size in bytes, 0 means no compression.
+	CompressThreshold int
+	// EarlyRefreshBeta enable probabilistic early refresh in GetOrLoad,
+	// values are reloaded before they expire with a probability that grows
+	// as the expiry gets closer. 1 is a good default, 0 means disabled.
+	EarlyRefreshBeta float64
+	// LoadTimeout bound the shared loader call of GetOrLoad, it does not
+	// depend on the context of the caller that started it. Default to 30 seconds.
+	LoadTimeout time.Duration
+	// Client is default to the client of ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Cache is a typed cache over redis.
+type Cache[T any] struct {
+	opts  CacheOptions
+	group singleflight.Group
+}
+
+// NewCache return new typed cache.
+// EX used: users := NewCache[User](&CacheOptions{Namespace: "user"})
+func NewCache[T any](opts *CacheOptions) *Cache[T] {
+	c := &Cache[T]{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Codec == nil {
+		c.opts.Codec = JSONCodec
+	}
+	if c.opts.LoadTimeout <= 0 {
+		c.opts.LoadTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// Get return the cached value, the bool result is false if key not exists.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+
+	data, err := c.client().Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		if err == goredislib.Nil {
+			return zero, false, nil
+		}
+		return zero, false, err
+	}
+
+	v, _, err := c.decode(data)
+	if err != nil {
+		return zero, false, err
+	}
+	return v, true, nil
+}
+
+// Set cache the value, ttl 0 means no expiration.
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.encode(value, 0)
+	if err != nil {
+		return err
+	}
+	return c.client().Set(ctx, c.key(key), data, ttl).Err()
+}
+
+// MGet return cached values of the keys, missing keys are not in the result.
+func (c *Cache[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	result := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	// pipelined GET instead of MGET, so keys can be in different cluster slots
+	pipe := c.client().Pipeline()
+	cmds := make([]*goredislib.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, c.key(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != goredislib.Nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err == goredislib.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, _, err := c.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		result[keys[i]] = v
+	}
+	return result, nil
+}
+
+// MSet cache the values with the same ttl.
+func (c *Cache[T]) MSet(ctx context.Context, items map[string]T, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	pipe := c.client().Pipeline()
+	for key, value := range items {
+		data, err := c.encode(value, 0)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, c.key(key), data, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Delete remove the keys from cache.
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := c.client().Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, c.key(key))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetOrLoad return the cached value, or call loader and cache its result.
+// Concurrent loads of the same key in this process share one loader call,
+// it gets the values of ctx but is only canceled by LoadTimeout, so a caller
+// giving up does not fail the others.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	fullKey := c.key(key)
+
+	pipe := c.client().Pipeline()
+	getCmd := pipe.Get(ctx, fullKey)
+	ttlCmd := pipe.PTTL(ctx, fullKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != goredislib.Nil {
+		return c.load(ctx, fullKey, ttl, loader)
+	}
+
+	data, err := getCmd.Bytes()
+	if err != nil {
+		return c.load(ctx, fullKey, ttl, loader)
+	}
+
+	v, delta, err := c.decode(data)
+	if err != nil {
+		return c.load(ctx, fullKey, ttl, loader)
+	}
+
+	if c.shouldRefresh(delta, ttlCmd.Val()) {
+		if fresh, err := c.load(ctx, fullKey, ttl, loader); err == nil {
+			return fresh, nil
+		}
+	}
+	return v, nil
+}
+
+func (c *Cache[T]) load(ctx context.Context, fullKey string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	ch := c.group.DoChan(fullKey, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(detach(ctx), c.opts.LoadTimeout)
+		defer cancel()
+
+		start := time.Now()
+		v, err := loader(loadCtx)
+		if err != nil {
+			return v, err
+		}
+
+		data, err := c.encode(v, time.Since(start))
+		if err != nil {
+			return v, err
+		}
+		// the value is loaded, failing to cache it only costs the next load
+		if err := c.client().Set(loadCtx, fullKey, data, ttl).Err(); err != nil {
+			logger.Error().Err(err).Var("key", fullKey).Msg("error when cache a loaded value")
+		}
+		return v, nil
+	})
+
+	select {
+	case res := <-ch:
+		v, _ := res.Val.(T)
+		return v, res.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// detachedCtx keep the values of its parent without its deadline and cancellation.
+type detachedCtx struct {
+	context.Context
+}
+
+func (detachedCtx) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedCtx) Done() <-chan struct{}       { return nil }
+func (detachedCtx) Err() error                  { return nil }
+
+func detach(ctx context.Context) context.Context {
+	return detachedCtx{ctx}
+}
+
+// shouldRefresh implement probabilistic early expiration (XFetch),
+// delta is the time the loader took to compute the value.
+func (c *Cache[T]) shouldRefresh(delta, remaining time.Duration) bool {
+	if c.opts.EarlyRefreshBeta <= 0 || delta <= 0 || remaining <= 0 {
+		return false
+	}
+	gap := float64(delta) * c.opts.EarlyRefreshBeta * -math.Log(rand.Float64())
+	return gap >= float64(remaining)
+}
+
+func (c *Cache[T]) client() goredislib.UniversalClient {
+	if c.opts.Client != nil {
+		return c.opts.Client
+	}
+	return client
+}
+
+func (c *Cache[T]) key(key string) string {
+	if c.opts.Namespace == "" {
+		return key
+	}
+	return c.opts.Namespace + ":" + key
+}
+
+// encode value into envelope: flags byte, uvarint loader delta in
+// milliseconds, then the encoded payload.
+func (c *Cache[T]) encode(value T, delta time.Duration) ([]byte, error) {
+	payload, err := c.opts.Codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if c.opts.CompressThreshold > 0 && len(payload) > c.opts.CompressThreshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(payload); err != nil {
+			return nil, err
+		}
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+		flags |= flagGzip
+	}
+
+	out := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(payload))
+	out[0] = flags
+	n := binary.PutUvarint(out[1:], uint64(delta.Milliseconds()))
+	out = append(out[:1+n], payload...)
+	return out, nil
+}
+
+func (c *Cache[T]) decode(data []byte) (T, time.Duration, error) {
+	var v T
+	if len(data) < 2 {
+		return v, 0, errInvalidEnvelope
+	}
+
+	flags := data[0]
+	delta, n := binary.Uvarint(data[1:])
+	if n <= 0 {
+		return v, 0, errInvalidEnvelope
+	}
+	payload := data[1+n:]
+
+	if flags&flagGzip != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return v, 0, err
+		}
+		if payload, err = io.ReadAll(zr); err != nil {
+			return v, 0, err
+		}
+	}
+
+	// allocate pointer types, so codecs like ProtoCodec receive the message itself
+	var target interface{} = &v
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Ptr {
+		v = reflect.New(t.Elem()).Interface().(T)
+		target = v
+	}
+	if err := c.opts.Codec.Unmarshal(payload, target); err != nil {
+		return v, 0, err
+	}
+	return v, time.Duration(delta) * time.Millisecond, nil
+}
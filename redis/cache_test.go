@@ -0,0 +1,136 @@
+package searedis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+)
+
+type cachedUser struct {
+	Name string
+	Bio  string
+}
+
+func TestCacheEnvelope(t *testing.T) {
+	user := cachedUser{Name: "a", Bio: strings.Repeat("b", 100)}
+
+	for name, codec := range map[string]Codec{"json": JSONCodec, "msgpack": MsgpackCodec, "gob": GobCodec} {
+		for _, threshold := range []int{0, 10} {
+			c := NewCache[cachedUser](&CacheOptions{Codec: codec, CompressThreshold: threshold})
+			data, err := c.encode(user, 1500*time.Millisecond)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if gzipped := data[0]&flagGzip != 0; gzipped != (threshold > 0) {
+				t.Fatalf("%s: unexpected gzip flag with threshold %d", name, threshold)
+			}
+
+			got, delta, err := c.decode(data)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if got != user || delta != 1500*time.Millisecond {
+				t.Fatalf("%s: unexpected value %v, delta %s", name, got, delta)
+			}
+		}
+	}
+
+	// values under the threshold are not compressed
+	c := NewCache[string](&CacheOptions{CompressThreshold: 1000})
+	if data, _ := c.encode("small", 0); data[0]&flagGzip != 0 {
+		t.Fatal("small value should not be compressed")
+	}
+	if _, _, err := c.decode([]byte{0}); err != errInvalidEnvelope {
+		t.Fatalf("expected errInvalidEnvelope, got %v", err)
+	}
+
+	p := NewCache[*cachedUser](nil)
+	data, _ := p.encode(&user, 0)
+	if got, _, err := p.decode(data); err != nil || got == nil || *got != user {
+		t.Fatalf("unexpected pointer value %v, %v", got, err)
+	}
+}
+
+func TestShouldRefresh(t *testing.T) {
+	c := NewCache[string](&CacheOptions{EarlyRefreshBeta: 1})
+
+	if c.shouldRefresh(0, time.Second) || c.shouldRefresh(time.Second, 0) {
+		t.Fatal("should not refresh without delta or ttl")
+	}
+	if c.shouldRefresh(time.Millisecond, 24*time.Hour) {
+		t.Fatal("should not refresh far from expiry")
+	}
+	if !c.shouldRefresh(time.Hour, time.Nanosecond) {
+		t.Fatal("should refresh close to expiry")
+	}
+	if NewCache[string](nil).shouldRefresh(time.Hour, time.Nanosecond) {
+		t.Fatal("should not refresh when disabled")
+	}
+}
+
+func TestCacheLoadDetached(t *testing.T) {
+	_, client := redistest.NewServer(t, redistest.MemoryHandler())
+	c := NewCache[string](&CacheOptions{Namespace: "user", Client: client})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	loaderErr := make(chan error, 2)
+	var once sync.Once
+	loader := func(ctx context.Context) (string, error) {
+		once.Do(func() { close(started) })
+		<-release
+		loaderErr <- ctx.Err()
+		return "loaded", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "1", time.Minute, loader)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		v, _ := c.GetOrLoad(context.Background(), "1", time.Minute, loader)
+		second <- v
+	}()
+
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("expected the first caller to be canceled, got %v", err)
+	}
+	close(release)
+
+	if err := <-loaderErr; err != nil {
+		t.Fatalf("loader should not be canceled, got %v", err)
+	}
+	if v := <-second; v != "loaded" {
+		t.Fatalf("unexpected value %q", v)
+	}
+	if v, ok, err := c.Get(context.Background(), "1"); err != nil || !ok || v != "loaded" {
+		t.Fatalf("unexpected cached value %q, %v, %v", v, ok, err)
+	}
+}
+
+func TestCacheLoadSetFailure(t *testing.T) {
+	_, client := redistest.NewServer(t, func(args []string) interface{} {
+		if strings.ToUpper(args[0]) == "SET" {
+			return errors.New("READONLY You can't write against a read only replica.")
+		}
+		return nil
+	})
+	c := NewCache[string](&CacheOptions{Namespace: "user", Client: client})
+
+	v, err := c.GetOrLoad(context.Background(), "1", time.Minute, func(ctx context.Context) (string, error) {
+		return "loaded", nil
+	})
+	if err != nil || v != "loaded" {
+		t.Fatalf("unexpected result %q, %v", v, err)
+	}
+}
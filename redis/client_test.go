@@ -0,0 +1,56 @@
+package searedis
+
+import (
+	"testing"
+	"time"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+func TestNewClientMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RedisConnectionConfig
+		check  func(c goredislib.UniversalClient) bool
+	}{
+		{"default", RedisConnectionConfig{Addr: "localhost:6379"}, func(c goredislib.UniversalClient) bool {
+			cl, ok := c.(*goredislib.Client)
+			return ok && cl.Options().Addr == "localhost:6379"
+		}},
+		{"sentinel", RedisConnectionConfig{Mode: ModeSentinel, Addrs: []string{"s1:26379"}, MasterName: "mymaster"}, func(c goredislib.UniversalClient) bool {
+			cl, ok := c.(*goredislib.Client)
+			return ok && cl.Options().Addr == "FailoverClient"
+		}},
+		{"cluster", RedisConnectionConfig{Mode: ModeCluster, Addrs: []string{"n1:6379", "n2:6379"}}, func(c goredislib.UniversalClient) bool {
+			cl, ok := c.(*goredislib.ClusterClient)
+			return ok && len(cl.Options().Addrs) == 2
+		}},
+	}
+	for _, tt := range tests {
+		c, err := NewClient(&tt.config)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !tt.check(c) {
+			t.Errorf("%s: unexpected client %T", tt.name, c)
+		}
+		c.Close()
+	}
+
+	if _, err := NewClient(&RedisConnectionConfig{Mode: ModeSentinel}); err == nil {
+		t.Error("expected an error without master name")
+	}
+	if _, err := NewClient(&RedisConnectionConfig{Mode: "replica"}); err == nil {
+		t.Error("expected an error on unknown mode")
+	}
+}
+
+func TestUniversalOptions(t *testing.T) {
+	opts := universalOptions(&RedisConnectionConfig{Addr: "localhost:6379", ReadTimeout: time.Second})
+	if len(opts.Addrs) != 1 || opts.Addrs[0] != "localhost:6379" {
+		t.Fatalf("unexpected addrs: %v", opts.Addrs)
+	}
+	if opts.ReadTimeout != time.Second || opts.MaxRetries != 2 || opts.MinIdleConns != 10 || opts.IdleTimeout != 5*time.Minute {
+		t.Fatalf("unexpected defaults: %+v", opts)
+	}
+}
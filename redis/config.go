@@ -1,5 +1,22 @@
 package searedis
 
+import (
+	"crypto/tls"
+	"time"
+)
+
+// RedisMode define how the client connects to redis.
+type RedisMode string
+
+const (
+	// ModeStandalone connects to a single redis node.
+	ModeStandalone RedisMode = "standalone"
+	// ModeSentinel connects to the master discovered by redis sentinels.
+	ModeSentinel RedisMode = "sentinel"
+	// ModeCluster connects to a redis cluster.
+	ModeCluster RedisMode = "cluster"
+)
+
 type RedisConnectionConfig struct {
 	Addr             string
 	UserName         string
@@ -7,4 +24,22 @@ type RedisConnectionConfig struct {
 	Database         int
 	PoolSize         int
 	SentinelPassword string
+
+	// Mode is default to ModeStandalone.
+	Mode RedisMode
+	// Addrs is the sentinel addresses in sentinel mode or the seed nodes
+	// in cluster mode, default to Addr.
+	Addrs            []string
+	MasterName       string
+	SentinelUserName string
+	TLSConfig        *tls.Config
+
+	// Zero values below use the defaults of ConnectRedisV1.
+	MaxRetries   int
+	MinIdleConns int
+	IdleTimeout  time.Duration
+	PoolTimeout  time.Duration
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
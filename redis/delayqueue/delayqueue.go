@@ -0,0 +1,379 @@
+package seadelayqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var logger = plog.NewBizLogger("delayqueue")
+
+var (
+	// ErrDuplicate is returned by Schedule when a job with the same id exists.
+	ErrDuplicate = errors.New("delayqueue: job already exists")
+	// ErrClosed is returned by HandleMessages when the queue was closed.
+	ErrClosed = errors.New("delayqueue: queue is closed")
+	// ErrRunning is returned by HandleMessages when it's already running.
+	ErrRunning = errors.New("delayqueue: queue is already running")
+	// ErrNotReserved is returned by Ack, Retry and Bury when the visibility
+	// timeout expired and the job was reserved again or canceled.
+	ErrNotReserved = errors.New("delayqueue: job is not reserved anymore")
+)
+
+const (
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultMaxAttempts       = 5
+	defaultPollInterval      = time.Second
+	defaultBatchSize         = 100
+	maxBackoff               = time.Hour
+	ackTimeout               = 5 * time.Second
+)
+
+// Options struct contain options of Queue.
+type Options struct {
+	// VisibilityTimeout is how long a reserved job is hidden from other
+	// workers, it's delivered again if not finished in time. Default to 30 seconds.
+	VisibilityTimeout time.Duration
+	// MaxAttempts is how many times a job is tried before it's moved to
+	// the dead jobs, default to 5. Reservations that timed out count as
+	// attempts.
+	MaxAttempts int
+	// Backoff return the delay before the next attempt, default to
+	// exponential from 1 second, capped at 1 hour.
+	Backoff func(attempt int) time.Duration
+	// PollInterval is how often due jobs are promoted and idle workers
+	// check for jobs, default to 1 second.
+	PollInterval time.Duration
+	// Concurrency is the number of jobs handled at the same time, default to 1.
+	Concurrency int
+	// BatchSize is the max number of jobs promoted at once, default to 100.
+	BatchSize int
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Job is a reserved job.
+type Job struct {
+	ID      string
+	Payload []byte
+	// Attempt start from 1.
+	Attempt int
+	// token identify the reservation, a job reserved again after its
+	// visibility timeout cannot be finished with the old one.
+	token string
+}
+
+// Queue schedule payloads to be handled at a future time.
+type Queue struct {
+	name string
+	keys []string
+	opts Options
+
+	mu       sync.Mutex
+	running  bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New return new queue, queues with the same name share their jobs.
+func New(name string, opts *Options) *Queue {
+	q := &Queue{
+		name: name,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.VisibilityTimeout <= 0 {
+		q.opts.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if q.opts.MaxAttempts <= 0 {
+		q.opts.MaxAttempts = defaultMaxAttempts
+	}
+	if q.opts.Backoff == nil {
+		q.opts.Backoff = ExponentialBackoff
+	}
+	if q.opts.PollInterval <= 0 {
+		q.opts.PollInterval = defaultPollInterval
+	}
+	if q.opts.Concurrency <= 0 {
+		q.opts.Concurrency = 1
+	}
+	if q.opts.BatchSize <= 0 {
+		q.opts.BatchSize = defaultBatchSize
+	}
+
+	prefix := "delayqueue:{" + name + "}:"
+	q.keys = []string{
+		prefix + "scheduled",
+		prefix + "ready",
+		prefix + "inflight",
+		prefix + "jobs",
+		prefix + "attempts",
+		prefix + "dead",
+		prefix + "tokens",
+	}
+	return q
+}
+
+// ExponentialBackoff return 1s, 2s, 4s... capped at 1 hour.
+func ExponentialBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 12 {
+		return maxBackoff
+	}
+	d := time.Second << uint(attempt-1)
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// Schedule add the payload to be handled at the time and return the job id.
+// Empty id generate a random one, otherwise the id is unique and
+// ErrDuplicate is returned while a job with the same id is not finished.
+func (q *Queue) Schedule(ctx context.Context, id string, payload []byte, at time.Time) (string, error) {
+	if id == "" {
+		id = randomID()
+	}
+
+	ok, err := scheduleScript.Run(ctx, q.client(), q.keys, id, payload, at.UnixMilli()).Int()
+	if err != nil {
+		return "", err
+	}
+	if ok == 0 {
+		return id, ErrDuplicate
+	}
+	return id, nil
+}
+
+// Delay add the payload to be handled after the delay, see Schedule.
+func (q *Queue) Delay(ctx context.Context, id string, payload []byte, delay time.Duration) (string, error) {
+	return q.Schedule(ctx, id, payload, time.Now().Add(delay))
+}
+
+// Cancel remove the job, it report false if the job is not found.
+// A job being handled is not interrupted, but it's not retried.
+func (q *Queue) Cancel(ctx context.Context, id string) (bool, error) {
+	n, err := cancelScript.Run(ctx, q.client(), q.keys, id).Int()
+	return n == 1, err
+}
+
+// Promote move due jobs to the ready list, HandleMessages call it every PollInterval.
+func (q *Queue) Promote(ctx context.Context) (int, error) {
+	return promoteScript.Run(ctx, q.client(), q.keys, q.opts.BatchSize).Int()
+}
+
+// Reserve return the next ready job, or nil when there is none. The job
+// must be finished with Ack, Retry or Bury before the visibility timeout.
+// Jobs reserved more than MaxAttempts times are moved to the dead jobs.
+func (q *Queue) Reserve(ctx context.Context) (*Job, error) {
+	token := randomID()
+	values, err := reserveScript.Run(ctx, q.client(), q.keys,
+		q.opts.VisibilityTimeout.Milliseconds(), q.opts.MaxAttempts, token).Slice()
+	if err == goredislib.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, errors.New("delayqueue: unexpected reserve result")
+	}
+
+	id, _ := values[0].(string)
+	payload, _ := values[1].(string)
+	attempt, _ := values[2].(int64)
+	return &Job{ID: id, Payload: []byte(payload), Attempt: int(attempt), token: token}, nil
+}
+
+// Ack remove the finished job.
+func (q *Queue) Ack(ctx context.Context, job *Job) error {
+	return finished(ackScript.Run(ctx, q.client(), q.keys, job.ID, job.token).Int())
+}
+
+// Retry schedule the job again after the delay.
+func (q *Queue) Retry(ctx context.Context, job *Job, delay time.Duration) error {
+	return finished(retryScript.Run(ctx, q.client(), q.keys, job.ID, job.token, delay.Milliseconds()).Int())
+}
+
+// Bury move the job to the dead jobs, they are kept until DeleteDead.
+func (q *Queue) Bury(ctx context.Context, job *Job) error {
+	return finished(buryScript.Run(ctx, q.client(), q.keys, job.ID, job.token).Int())
+}
+
+// finished return ErrNotReserved when the script did not find the reservation.
+func finished(n int, err error) error {
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
+
+// Dead return payloads of dead jobs by id.
+func (q *Queue) Dead(ctx context.Context) (map[string]string, error) {
+	return q.client().HGetAll(ctx, q.keys[5]).Result()
+}
+
+// DeleteDead remove the dead jobs.
+func (q *Queue) DeleteDead(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return q.client().HDel(ctx, q.keys[5], ids...).Err()
+}
+
+// HandleMessages promote due jobs and call f for each payload, it blocks
+// until ctx is done or Close is called. Failed jobs are retried with
+// backoff and moved to the dead jobs after MaxAttempts.
+func (q *Queue) HandleMessages(ctx context.Context, f func(d []byte) error) error {
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return ErrRunning
+	}
+	select {
+	case <-q.stop:
+		q.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	q.running = true
+	q.mu.Unlock()
+	defer close(q.done)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-q.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(q.opts.Concurrency + 1)
+	go func() {
+		defer wg.Done()
+		q.poll(ctx)
+	}()
+	for i := 0; i < q.opts.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			q.work(ctx, f)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Close stop handling jobs and wait for in-flight jobs.
+func (q *Queue) Close() {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	q.mu.Lock()
+	running := q.running
+	q.mu.Unlock()
+	if running {
+		<-q.done
+	}
+}
+
+func (q *Queue) poll(ctx context.Context) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// keep promoting while full batches are moved
+		for {
+			n, err := q.Promote(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error().Err(err).Var("queue", q.name).Msg("error when promote jobs")
+				}
+				break
+			}
+			if n < q.opts.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) work(ctx context.Context, f func(d []byte) error) {
+	for ctx.Err() == nil {
+		job, err := q.Reserve(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Var("queue", q.name).Msg("error when reserve a job")
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.opts.PollInterval):
+			}
+			continue
+		}
+		q.handle(job, f)
+	}
+}
+
+func (q *Queue) handle(job *Job, f func(d []byte) error) {
+	err := f(job.Payload)
+
+	// finish the job even when shutting down, so it's not handled twice
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+
+	if err == nil {
+		if err = q.Ack(ctx, job); err != nil {
+			logger.Error().Err(err).Var("queue", q.name).Var("id", job.ID).Msg("error when ack")
+		}
+		return
+	}
+
+	logger.Error().Err(err).Var("queue", q.name).Var("id", job.ID).Var("attempt", job.Attempt).Msg("error when handle a job")
+	if job.Attempt >= q.opts.MaxAttempts {
+		err = q.Bury(ctx, job)
+	} else {
+		err = q.Retry(ctx, job, q.opts.Backoff(job.Attempt))
+	}
+	if err != nil {
+		logger.Error().Err(err).Var("queue", q.name).Var("id", job.ID).Msg("error when retry a job")
+	}
+}
+
+// randomID return random job id or reservation token.
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (q *Queue) client() goredislib.UniversalClient {
+	if q.opts.Client != nil {
+		return q.opts.Client
+	}
+	return searedis.GetClient()
+}
@@ -0,0 +1,233 @@
+package seadelayqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestQueue return a queue on miniredis, its clock is set to now.
+func newTestQueue(t *testing.T, opts Options) (*Queue, *miniredis.Miniredis, time.Time) {
+	m, client := redistest.NewMiniredis(t)
+	now := time.Now()
+	m.SetTime(now)
+	opts.Client = client
+	return New("emails", &opts), m, now
+}
+
+// reserve promote due jobs and reserve the next one.
+func reserve(t *testing.T, q *Queue) *Job {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := q.Promote(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Reserve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  time.Second,
+		1:  time.Second,
+		3:  4 * time.Second,
+		12: 2048 * time.Second,
+		13: time.Hour,
+		40: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := ExponentialBackoff(attempt); got != want {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestKeysShareHashTag(t *testing.T) {
+	q := New("emails", nil)
+	for _, key := range q.keys {
+		if key[:len("delayqueue:{emails}:")] != "delayqueue:{emails}:" {
+			t.Fatalf("unexpected key: %s", key)
+		}
+	}
+	if q.opts.MaxAttempts != defaultMaxAttempts || q.opts.Concurrency != 1 {
+		t.Fatalf("unexpected options: %+v", q.opts)
+	}
+}
+
+func TestScheduleAndAck(t *testing.T) {
+	q, m, now := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	if _, err := q.Schedule(ctx, "welcome", []byte("hi"), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Schedule(ctx, "welcome", []byte("hi"), now); err != ErrDuplicate {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+	if job := reserve(t, q); job != nil {
+		t.Fatalf("expected no due job, got %+v", job)
+	}
+
+	m.SetTime(now.Add(time.Minute))
+	job := reserve(t, q)
+	if job == nil || job.ID != "welcome" || string(job.Payload) != "hi" || job.Attempt != 1 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if err := q.Ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack(ctx, job); err != ErrNotReserved {
+		t.Fatalf("expected ErrNotReserved, got %v", err)
+	}
+	if _, err := q.Schedule(ctx, "welcome", []byte("hi"), now); err != nil {
+		t.Fatalf("expected the id free after ack, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	q, m, now := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	q.Schedule(ctx, "welcome", []byte("hi"), now)
+	job := reserve(t, q)
+	if err := q.Retry(ctx, job, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if next := reserve(t, q); next != nil {
+		t.Fatalf("expected the job delayed, got %+v", next)
+	}
+
+	m.SetTime(now.Add(time.Second))
+	if job = reserve(t, q); job == nil || job.Attempt != 2 {
+		t.Fatalf("expected the second attempt, got %+v", job)
+	}
+	if err := q.Bury(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := q.Dead(ctx)
+	if err != nil || dead["welcome"] != "hi" {
+		t.Fatalf("unexpected dead jobs %v, %v", dead, err)
+	}
+}
+
+func TestReservationToken(t *testing.T) {
+	q, m, now := newTestQueue(t, Options{VisibilityTimeout: time.Second})
+	ctx := context.Background()
+
+	q.Schedule(ctx, "welcome", []byte("hi"), now)
+	stale := reserve(t, q)
+
+	// the visibility timeout expired, the job is delivered again
+	m.SetTime(now.Add(time.Second))
+	job := reserve(t, q)
+	if job == nil || job.ID != stale.ID || job.Attempt != 2 {
+		t.Fatalf("expected the job reserved again, got %+v", job)
+	}
+
+	for name, finish := range map[string]func(*Job) error{
+		"ack":   func(j *Job) error { return q.Ack(ctx, j) },
+		"retry": func(j *Job) error { return q.Retry(ctx, j, 0) },
+		"bury":  func(j *Job) error { return q.Bury(ctx, j) },
+	} {
+		if err := finish(stale); err != ErrNotReserved {
+			t.Fatalf("%s: expected ErrNotReserved with the old token, got %v", name, err)
+		}
+	}
+	if err := q.Ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxAttemptsOnReserve(t *testing.T) {
+	q, m, now := newTestQueue(t, Options{VisibilityTimeout: time.Second, MaxAttempts: 2})
+	ctx := context.Background()
+
+	q.Schedule(ctx, "welcome", []byte("hi"), now)
+	// the worker dies at each attempt
+	for attempt := 1; attempt <= 2; attempt++ {
+		m.SetTime(now.Add(time.Duration(attempt-1) * time.Second))
+		if job := reserve(t, q); job == nil || job.Attempt != attempt {
+			t.Fatalf("expected attempt %d, got %+v", attempt, job)
+		}
+	}
+
+	m.SetTime(now.Add(2 * time.Second))
+	if job := reserve(t, q); job != nil {
+		t.Fatalf("expected the job dead, got %+v", job)
+	}
+	dead, err := q.Dead(ctx)
+	if err != nil || dead["welcome"] != "hi" {
+		t.Fatalf("unexpected dead jobs %v, %v", dead, err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	q, _, now := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	q.Schedule(ctx, "welcome", []byte("hi"), now)
+	q.Promote(ctx)
+	if ok, err := q.Cancel(ctx, "welcome"); !ok || err != nil {
+		t.Fatalf("unexpected cancel result %v, %v", ok, err)
+	}
+	if ok, _ := q.Cancel(ctx, "welcome"); ok {
+		t.Fatal("expected the job not found")
+	}
+	if job, err := q.Reserve(ctx); job != nil || err != nil {
+		t.Fatalf("expected no job, got %+v, %v", job, err)
+	}
+}
+
+func TestHandleMessages(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	q := New("emails", &Options{
+		Client:       client,
+		MaxAttempts:  2,
+		PollInterval: 10 * time.Millisecond,
+		Backoff:      func(attempt int) time.Duration { return 0 },
+	})
+	ctx := context.Background()
+	q.Schedule(ctx, "flaky", []byte("flaky"), time.Now())
+	q.Schedule(ctx, "broken", []byte("broken"), time.Now())
+
+	calls := map[string]int{}
+	handled := make(chan struct{})
+	go q.HandleMessages(ctx, func(d []byte) error {
+		calls[string(d)]++
+		if string(d) == "broken" || calls[string(d)] == 1 {
+			return errors.New("failed")
+		}
+		close(handled)
+		return nil
+	})
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the flaky job handled")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		dead, _ := q.Dead(ctx)
+		if dead["broken"] == "broken" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the broken job dead, got %v", dead)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	q.Close()
+
+	if calls["flaky"] != 2 || calls["broken"] != 2 {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+}
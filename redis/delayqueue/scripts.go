@@ -0,0 +1,136 @@
+package seadelayqueue
+
+import searedis "github.com/ponlv/go-kit/redis"
+
+// All keys of a queue share the "{name}" hash tag so scripts work on cluster.
+// KEYS: scheduled zset, ready list, inflight zset, jobs hash, attempts hash,
+// dead hash, reservation tokens hash
+// Scripts reading TIME replicate their effects, not the script, so replicas
+// do not compute another time.
+
+// scheduleScript add the job if its id is not used.
+// ARGV: id, payload, due (ms)
+var scheduleScript = searedis.RegisterScript("delayqueue:schedule", `
+if redis.call("HSETNX", KEYS[4], ARGV[1], ARGV[2]) == 0 then
+  return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+return 1
+`)
+
+// promoteScript move due jobs and jobs whose visibility timeout expired
+// to the ready list, it returns the number of moved jobs.
+// ARGV: limit
+var promoteScript = searedis.RegisterScript("delayqueue:promote", `
+redis.replicate_commands()
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local moved = 0
+
+for _, key in ipairs({KEYS[1], KEYS[3]}) do
+  local ids = redis.call("ZRANGEBYSCORE", key, "-inf", now, "LIMIT", 0, ARGV[1])
+  for _, id in ipairs(ids) do
+    redis.call("ZREM", key, id)
+    redis.call("RPUSH", KEYS[2], id)
+  end
+  moved = moved + #ids
+end
+
+return moved
+`)
+
+// reserveScript pop a ready job, hide it for the visibility timeout and
+// store the reservation token, it returns {id, payload, attempt} or nil when
+// no job is ready. Attempts are counted here, so jobs whose workers died
+// are moved to the dead hash too once they are over the max attempts.
+// ARGV: visibility timeout (ms), max attempts, token
+var reserveScript = searedis.RegisterScript("delayqueue:reserve", `
+redis.replicate_commands()
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+while true do
+  local id = redis.call("LPOP", KEYS[2])
+  if not id then
+    return nil
+  end
+  local payload = redis.call("HGET", KEYS[4], id)
+  -- canceled jobs have no payload
+  if payload then
+    local attempt = redis.call("HINCRBY", KEYS[5], id, 1)
+    if attempt > tonumber(ARGV[2]) then
+      redis.call("HSET", KEYS[6], id, payload)
+      redis.call("HDEL", KEYS[4], id)
+      redis.call("HDEL", KEYS[5], id)
+      redis.call("HDEL", KEYS[7], id)
+    else
+      redis.call("ZADD", KEYS[3], now + tonumber(ARGV[1]), id)
+      redis.call("HSET", KEYS[7], id, ARGV[3])
+      return {id, payload, attempt}
+    end
+  end
+end
+`)
+
+// ackScript remove a finished job if it's still reserved with the token.
+// ARGV: id, token
+var ackScript = searedis.RegisterScript("delayqueue:ack", `
+if redis.call("HGET", KEYS[7], ARGV[1]) ~= ARGV[2] then
+  return 0
+end
+redis.call("ZREM", KEYS[3], ARGV[1])
+redis.call("HDEL", KEYS[4], ARGV[1])
+redis.call("HDEL", KEYS[5], ARGV[1])
+redis.call("HDEL", KEYS[7], ARGV[1])
+return 1
+`)
+
+// retryScript schedule a failed job again if it's still reserved with the token.
+// ARGV: id, token, delay (ms)
+var retryScript = searedis.RegisterScript("delayqueue:retry", `
+redis.replicate_commands()
+
+if redis.call("HGET", KEYS[7], ARGV[1]) ~= ARGV[2] or redis.call("ZREM", KEYS[3], ARGV[1]) == 0 then
+  return 0
+end
+redis.call("HDEL", KEYS[7], ARGV[1])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+return 1
+`)
+
+// buryScript move a job out of retries to the dead hash if it's still
+// reserved with the token.
+// ARGV: id, token
+var buryScript = searedis.RegisterScript("delayqueue:bury", `
+if redis.call("HGET", KEYS[7], ARGV[1]) ~= ARGV[2] then
+  return 0
+end
+redis.call("ZREM", KEYS[3], ARGV[1])
+local payload = redis.call("HGET", KEYS[4], ARGV[1])
+if not payload then
+  return 0
+end
+redis.call("HSET", KEYS[6], ARGV[1], payload)
+redis.call("HDEL", KEYS[4], ARGV[1])
+redis.call("HDEL", KEYS[5], ARGV[1])
+redis.call("HDEL", KEYS[7], ARGV[1])
+return 1
+`)
+
+// cancelScript remove a job wherever it is, it returns 0 if not found.
+// ARGV: id
+var cancelScript = searedis.RegisterScript("delayqueue:cancel", `
+if redis.call("HDEL", KEYS[4], ARGV[1]) == 0 then
+  return 0
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("ZREM", KEYS[3], ARGV[1])
+redis.call("LREM", KEYS[2], 0, ARGV[1])
+redis.call("HDEL", KEYS[5], ARGV[1])
+redis.call("HDEL", KEYS[7], ARGV[1])
+return 1
+`)
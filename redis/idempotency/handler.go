@@ -0,0 +1,53 @@
+package seaidempotency
+
+import (
+	"context"
+
+	"github.com/ponlv/go-kit/plog"
+)
+
+var logger = plog.NewBizLogger("idempotency")
+
+// WrapHandler make a message handler, such as the rabbitmq HandleMessages
+// callback, process each message once. Redelivered messages with the same
+// key are skipped, messages still in progress elsewhere return ErrInProgress
+// so they are requeued. If keyFunc is nil, the key is the body fingerprint.
+func WrapHandler(store *Store, keyFunc func(d []byte) string, f func(d []byte) error) func(d []byte) error {
+	return func(d []byte) error {
+		fingerprint := Fingerprint(d)
+		key := fingerprint
+		if keyFunc != nil {
+			key = keyFunc(d)
+		}
+		if key == "" {
+			return f(d)
+		}
+
+		ctx := context.Background()
+		claim, _, err := store.Begin(ctx, key, fingerprint)
+		if err == ErrKeyReused {
+			// requeue would loop forever, drop it
+			logger.Error().Err(err).Var("key", key).Msg("drop message")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if claim == nil {
+			logger.Info().Var("key", key).Msg("skip duplicate message")
+			return nil
+		}
+
+		if err = f(d); err != nil {
+			_ = claim.Abort(ctx)
+			return err
+		}
+		err = claim.Complete(ctx, nil)
+		if err == ErrClaimExpired {
+			// the message is handled, requeue would handle it again
+			logger.Error().Err(err).Var("key", key).Msg("message handled after its claim expired")
+			return nil
+		}
+		return err
+	}
+}
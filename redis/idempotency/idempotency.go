@@ -0,0 +1,236 @@
+package seaidempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrInProgress is returned when a request with the same key is still being processed.
+	ErrInProgress = errors.New("idempotency: request is in progress")
+	// ErrKeyReused is returned when the key was used by a request with a different payload.
+	ErrKeyReused = errors.New("idempotency: key reused with a different payload")
+	// ErrClaimExpired is returned by Complete when the claim expired before
+	// the request finished and may be processed again.
+	ErrClaimExpired = errors.New("idempotency: claim expired")
+)
+
+const (
+	defaultPrefix       = "idempotency"
+	defaultTTL          = 24 * time.Hour
+	defaultLockTTL      = time.Minute
+	defaultPollInterval = 100 * time.Millisecond
+)
+
+// completeScript replace the pending record by the response if it's still owned.
+// KEYS[1]: key, ARGV: pending record, done record, ttl (ms)
+var completeScript = searedis.RegisterScript("idempotency:complete", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+  return 1
+end
+return 0
+`)
+
+// abortScript delete the pending record if it's still owned.
+// KEYS[1]: key, ARGV: pending record
+var abortScript = searedis.RegisterScript("idempotency:abort", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Options struct contain options of Store.
+type Options struct {
+	// Prefix of the keys, default to "idempotency".
+	Prefix string
+	// TTL is how long responses are kept for replays, default to 24 hours.
+	TTL time.Duration
+	// LockTTL is how long a request can be in progress before another
+	// request with the same key may process it again, default to 1 minute.
+	LockTTL time.Duration
+	// Wait is how long a duplicate waits for the in-progress request before
+	// ErrInProgress is returned, 0 means return immediately.
+	Wait time.Duration
+	// PollInterval is how often a waiting duplicate checks the result, default to 100ms.
+	PollInterval time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Store keep the fingerprint and the response of requests by idempotency key.
+type Store struct {
+	opts Options
+}
+
+// record is stored as JSON under the key.
+type record struct {
+	Fingerprint string `json:"fp"`
+	Done        bool   `json:"done,omitempty"`
+	Response    []byte `json:"resp,omitempty"`
+	// Token identify the owner of a pending record.
+	Token string `json:"token,omitempty"`
+}
+
+// Claim is a request owned by the caller, it must be completed or aborted.
+type Claim struct {
+	store   *Store
+	key     string
+	record  record
+	pending string
+}
+
+// NewStore return new idempotency store.
+func NewStore(opts *Options) *Store {
+	s := &Store{}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Prefix == "" {
+		s.opts.Prefix = defaultPrefix
+	}
+	if s.opts.TTL <= 0 {
+		s.opts.TTL = defaultTTL
+	}
+	if s.opts.LockTTL <= 0 {
+		s.opts.LockTTL = defaultLockTTL
+	}
+	if s.opts.PollInterval <= 0 {
+		s.opts.PollInterval = defaultPollInterval
+	}
+	return s
+}
+
+// Fingerprint return the sha256 of the request payload parts, each part is
+// prefixed with its length so different parts never hash the same.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	size := make([]byte, 8)
+	for _, part := range parts {
+		binary.BigEndian.PutUint64(size, uint64(len(part)))
+		_, _ = h.Write(size)
+		_, _ = h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claim the key for a request with the fingerprint. If the request
+// was already processed, the claim is nil and the stored response is
+// returned instead.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Claim, []byte, error) {
+	fullKey := s.opts.Prefix + ":" + key
+
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	claim := &Claim{
+		store:  s,
+		key:    fullKey,
+		record: record{Fingerprint: fingerprint, Token: hex.EncodeToString(token)},
+	}
+	pending, err := json.Marshal(claim.record)
+	if err != nil {
+		return nil, nil, err
+	}
+	claim.pending = string(pending)
+
+	deadline := time.Now().Add(s.opts.Wait)
+	for {
+		ok, err := s.client().SetNX(ctx, fullKey, claim.pending, s.opts.LockTTL).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return claim, nil, nil
+		}
+
+		data, err := s.client().Get(ctx, fullKey).Bytes()
+		if err == goredislib.Nil {
+			// expired between SETNX and GET
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var stored record
+		if err = json.Unmarshal(data, &stored); err != nil {
+			return nil, nil, err
+		}
+		if stored.Fingerprint != fingerprint {
+			return nil, nil, ErrKeyReused
+		}
+		if stored.Done {
+			return nil, stored.Response, nil
+		}
+
+		if time.Now().Add(s.opts.PollInterval).After(deadline) {
+			return nil, nil, ErrInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(s.opts.PollInterval):
+		}
+	}
+}
+
+// Do call fn once per key, duplicates with the same payload get the stored
+// response. The response is not stored when fn fails, so the request can be retried.
+func (s *Store) Do(ctx context.Context, key string, payload []byte, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	claim, response, err := s.Begin(ctx, key, Fingerprint(payload))
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return response, nil
+	}
+
+	response, err = fn(ctx)
+	if err != nil {
+		_ = claim.Abort(context.Background())
+		return nil, err
+	}
+	return response, claim.Complete(ctx, response)
+}
+
+// Complete store the response for replays.
+func (c *Claim) Complete(ctx context.Context, response []byte) error {
+	done := record{Fingerprint: c.record.Fingerprint, Done: true, Response: response}
+	data, err := json.Marshal(done)
+	if err != nil {
+		return err
+	}
+
+	ok, err := completeScript.Run(ctx, c.store.client(), []string{c.key},
+		c.pending, data, c.store.opts.TTL.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrClaimExpired
+	}
+	return nil
+}
+
+// Abort release the key so the request can be retried.
+func (c *Claim) Abort(ctx context.Context) error {
+	return abortScript.Run(ctx, c.store.client(), []string{c.key}, c.pending).Err()
+}
+
+func (s *Store) client() goredislib.UniversalClient {
+	if s.opts.Client != nil {
+		return s.opts.Client
+	}
+	return searedis.GetClient()
+}
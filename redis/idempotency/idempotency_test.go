@@ -0,0 +1,157 @@
+package seaidempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+)
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint([]byte("a"), []byte("bc")) == Fingerprint([]byte("ab"), []byte("c")) {
+		t.Fatal("fingerprint should separate parts")
+	}
+	if Fingerprint([]byte("a\x00"), []byte("b")) == Fingerprint([]byte("a"), []byte("\x00b")) {
+		t.Fatal("fingerprint should separate parts containing zero bytes")
+	}
+	if Fingerprint([]byte("order")) != Fingerprint([]byte("order")) {
+		t.Fatal("fingerprint should be stable")
+	}
+}
+
+func TestNewStoreDefaults(t *testing.T) {
+	s := NewStore(nil)
+	if s.opts.Prefix != defaultPrefix || s.opts.TTL != defaultTTL || s.opts.LockTTL != defaultLockTTL {
+		t.Fatalf("unexpected options: %+v", s.opts)
+	}
+}
+
+func TestBegin(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{Client: client})
+	ctx := context.Background()
+
+	claim, _, err := s.Begin(ctx, "order-1", "fp")
+	if err != nil || claim == nil {
+		t.Fatalf("expected a claim, got %v, %v", claim, err)
+	}
+	if _, _, err = s.Begin(ctx, "order-1", "fp"); err != ErrInProgress {
+		t.Fatalf("expected ErrInProgress, got %v", err)
+	}
+	if _, _, err = s.Begin(ctx, "order-1", "other"); err != ErrKeyReused {
+		t.Fatalf("expected ErrKeyReused, got %v", err)
+	}
+
+	if err = claim.Complete(ctx, []byte("created")); err != nil {
+		t.Fatal(err)
+	}
+	replay, response, err := s.Begin(ctx, "order-1", "fp")
+	if err != nil || replay != nil || string(response) != "created" {
+		t.Fatalf("expected the stored response, got %v, %q, %v", replay, response, err)
+	}
+	if _, _, err = s.Begin(ctx, "order-1", "other"); err != ErrKeyReused {
+		t.Fatalf("expected ErrKeyReused after completion, got %v", err)
+	}
+}
+
+func TestBeginWait(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{Client: client, Wait: time.Second, PollInterval: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	claim, _, err := s.Begin(ctx, "order-1", "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		claim.Complete(ctx, []byte("created"))
+	}()
+
+	// the duplicate waits for the in-progress request
+	replay, response, err := s.Begin(ctx, "order-1", "fp")
+	if err != nil || replay != nil || string(response) != "created" {
+		t.Fatalf("expected the stored response, got %v, %q, %v", replay, response, err)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{Client: client})
+	ctx := context.Background()
+
+	claim, _, _ := s.Begin(ctx, "order-1", "fp")
+	if err := claim.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+	retry, _, err := s.Begin(ctx, "order-1", "fp")
+	if err != nil || retry == nil {
+		t.Fatalf("expected a new claim after abort, got %v, %v", retry, err)
+	}
+
+	// an old claim does not release the new one
+	if err = claim.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = s.Begin(ctx, "order-1", "fp"); err != ErrInProgress {
+		t.Fatalf("expected ErrInProgress, got %v", err)
+	}
+}
+
+func TestCompleteExpired(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{Client: client, LockTTL: time.Second})
+	ctx := context.Background()
+
+	claim, _, _ := s.Begin(ctx, "order-1", "fp")
+	m.FastForward(time.Second)
+	other, _, err := s.Begin(ctx, "order-1", "fp")
+	if err != nil || other == nil {
+		t.Fatalf("expected the key claimed again, got %v, %v", other, err)
+	}
+
+	if err = claim.Complete(ctx, []byte("late")); err != ErrClaimExpired {
+		t.Fatalf("expected ErrClaimExpired, got %v", err)
+	}
+	if err = other.Complete(ctx, []byte("created")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWrapHandler(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{Client: client, LockTTL: time.Second})
+	calls := 0
+	handle := WrapHandler(s, nil, func(d []byte) error {
+		calls++
+		if string(d) == "fail" {
+			return errors.New("failed")
+		}
+		if string(d) == "slow" {
+			m.FastForward(time.Second)
+		}
+		return nil
+	})
+
+	if err := handle([]byte("order")); err != nil {
+		t.Fatal(err)
+	}
+	if err := handle([]byte("order")); err != nil || calls != 1 {
+		t.Fatalf("expected the duplicate skipped, got %v after %d calls", err, calls)
+	}
+
+	// failed messages are handled again
+	if err := handle([]byte("fail")); err == nil {
+		t.Fatal("expected the handler error")
+	}
+	if err := handle([]byte("fail")); err == nil || calls != 3 {
+		t.Fatalf("expected the message handled again, got %v after %d calls", err, calls)
+	}
+
+	// a message handled after its claim expired is not requeued
+	if err := handle([]byte("slow")); err != nil {
+		t.Fatalf("expected nil after the claim expired, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package sealayered
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+	searedis "github.com/ponlv/go-kit/redis"
+	"github.com/ponlv/go-kit/ristretto"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var logger = plog.NewBizLogger("layered-cache")
+
+// ErrNoClient is returned by New when redis is not connected.
+var ErrNoClient = errors.New("layered: redis client is not initialized")
+
+const (
+	defaultChannel = "layered-cache:invalidate"
+	defaultL1TTL   = time.Minute
+)
+
+// Options struct contain options of Cache.
+type Options struct {
+	// Namespace is prefixed to every key as "<namespace>:<key>" in both levels.
+	Namespace string
+	// Channel is the pub/sub channel of invalidations, default to "layered-cache:invalidate".
+	Channel string
+	// L1TTL is the max time a value stays in memory, default to 1 minute.
+	// Values never stay in memory longer than in redis.
+	L1TTL time.Duration
+	// L1 is default to ristretto.GetInc().
+	L1 *ristretto.Ristretto
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Metrics is the number of hits and misses per level.
+type Metrics struct {
+	L1Hits   uint64
+	L1Misses uint64
+	L2Hits   uint64
+	L2Misses uint64
+}
+
+// Cache read from ristretto (L1) first and fall back to redis (L2).
+// Writes and deletes go through both levels, and evict the key from L1
+// of every other instance through redis pub/sub.
+type Cache struct {
+	opts   Options
+	id     string
+	pubsub *goredislib.PubSub
+	wg     sync.WaitGroup
+
+	l1Hits, l1Misses, l2Hits, l2Misses uint64
+}
+
+// invalidation is the pub/sub message of evicted keys.
+type invalidation struct {
+	Source string   `json:"src"`
+	Keys   []string `json:"keys"`
+}
+
+// New return new layered cache and start listening for invalidations,
+// call Close to stop.
+func New(opts *Options) (*Cache, error) {
+	c := &Cache{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Channel == "" {
+		c.opts.Channel = defaultChannel
+	}
+	if c.opts.L1TTL <= 0 {
+		c.opts.L1TTL = defaultL1TTL
+	}
+	if c.opts.L1 == nil {
+		c.opts.L1 = ristretto.GetInc()
+	}
+	if c.opts.Client == nil {
+		c.opts.Client = searedis.GetClient()
+	}
+	if c.opts.Client == nil {
+		return nil, ErrNoClient
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	c.id = hex.EncodeToString(id)
+
+	c.pubsub = c.opts.Client.Subscribe(context.Background(), c.opts.Channel)
+	c.wg.Add(1)
+	go c.listen()
+	return c, nil
+}
+
+// Get read the value from L1, then L2, the bool result is false if key not exists.
+// EX used: existsFlag, err := cache.Get(ctx, "key", &userModel)
+func (c *Cache) Get(ctx context.Context, key string, refObj interface{}) (bool, error) {
+	fullKey := c.key(key)
+
+	if data, ok := c.opts.L1.Cache().Get(fullKey); ok {
+		atomic.AddUint64(&c.l1Hits, 1)
+		return true, json.Unmarshal(data, refObj)
+	}
+	atomic.AddUint64(&c.l1Misses, 1)
+
+	pipe := c.opts.Client.Pipeline()
+	getCmd := pipe.Get(ctx, fullKey)
+	ttlCmd := pipe.PTTL(ctx, fullKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != goredislib.Nil {
+		return false, err
+	}
+
+	data, err := getCmd.Bytes()
+	if err != nil {
+		if err == goredislib.Nil {
+			atomic.AddUint64(&c.l2Misses, 1)
+			return false, nil
+		}
+		return false, err
+	}
+	atomic.AddUint64(&c.l2Hits, 1)
+
+	if err = json.Unmarshal(data, refObj); err != nil {
+		return false, err
+	}
+	c.setL1(fullKey, data, ttlCmd.Val())
+	return true, nil
+}
+
+// Set write the value to both levels, ttl 0 means no expiration in L2.
+// EX used: err = cache.Set(ctx, "key", userModel, time.Hour)
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	fullKey := c.key(key)
+	if err = c.opts.Client.Set(ctx, fullKey, data, ttl).Err(); err != nil {
+		return err
+	}
+	c.setL1(fullKey, data, ttl)
+	return c.publish(ctx, fullKey)
+}
+
+// Delete remove the keys from both levels.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = c.key(key)
+		c.opts.L1.Cache().Delete(fullKeys[i])
+	}
+
+	pipe := c.opts.Client.Pipeline()
+	for _, key := range fullKeys {
+		pipe.Del(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	return c.publish(ctx, fullKeys...)
+}
+
+// Metrics return the hits and misses per level since New.
+func (c *Cache) Metrics() Metrics {
+	return Metrics{
+		L1Hits:   atomic.LoadUint64(&c.l1Hits),
+		L1Misses: atomic.LoadUint64(&c.l1Misses),
+		L2Hits:   atomic.LoadUint64(&c.l2Hits),
+		L2Misses: atomic.LoadUint64(&c.l2Misses),
+	}
+}
+
+// Close stop listening for invalidations.
+func (c *Cache) Close() error {
+	err := c.pubsub.Close()
+	c.wg.Wait()
+	return err
+}
+
+func (c *Cache) setL1(fullKey string, data []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > c.opts.L1TTL {
+		ttl = c.opts.L1TTL
+	}
+	c.opts.L1.Cache().SetWithTTL(fullKey, data, ttl)
+}
+
+func (c *Cache) publish(ctx context.Context, fullKeys ...string) error {
+	msg, err := json.Marshal(invalidation{Source: c.id, Keys: fullKeys})
+	if err != nil {
+		return err
+	}
+	return c.opts.Client.Publish(ctx, c.opts.Channel, msg).Err()
+}
+
+func (c *Cache) listen() {
+	defer c.wg.Done()
+
+	// the channel is closed by Close, go-redis resubscribe after reconnects
+	for msg := range c.pubsub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			logger.Error().Err(err).Var("payload", msg.Payload).Msg("error when decode invalidation")
+			continue
+		}
+		c.evict(inv)
+	}
+}
+
+// evict remove keys invalidated by other instances from L1.
+func (c *Cache) evict(inv invalidation) {
+	if inv.Source == c.id {
+		return
+	}
+	for _, key := range inv.Keys {
+		c.opts.L1.Cache().Delete(key)
+	}
+}
+
+func (c *Cache) key(key string) string {
+	if c.opts.Namespace == "" {
+		return key
+	}
+	return c.opts.Namespace + ":" + key
+}
@@ -0,0 +1,141 @@
+package sealayered
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+	"github.com/ponlv/go-kit/ristretto"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+// newTestCache return a cache of the client closed with the test.
+func newTestCache(t *testing.T, opts Options) *Cache {
+	c, err := New(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestEvict(t *testing.T) {
+	l1 := ristretto.GetInc()
+	c := &Cache{id: "self", opts: Options{Namespace: "user", L1: l1, L1TTL: time.Minute}}
+
+	key := c.key("42")
+	if key != "user:42" {
+		t.Fatalf("unexpected key: %s", key)
+	}
+
+	c.setL1(key, []byte(`{"name":"a"}`), 0)
+	l1.Cache().Wait()
+
+	c.evict(invalidation{Source: "self", Keys: []string{key}})
+	if _, ok := l1.Cache().Get(key); !ok {
+		t.Fatal("own invalidation should not evict L1")
+	}
+
+	c.evict(invalidation{Source: "other", Keys: []string{key}})
+	if _, ok := l1.Cache().Get(key); ok {
+		t.Fatal("expected key evicted from L1")
+	}
+}
+
+func TestGetLevels(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	l1 := ristretto.GetInc()
+	l1.Cache().Delete("levels:1")
+	c := newTestCache(t, Options{Namespace: "levels", L1: l1, Client: client})
+	ctx := context.Background()
+
+	var u user
+	if ok, err := c.Get(ctx, "1", &u); ok || err != nil {
+		t.Fatalf("expected a miss, got %v, %v", ok, err)
+	}
+
+	// L2 only, Get backfills L1
+	client.Set(ctx, "levels:1", `{"name":"a"}`, time.Hour)
+	if ok, err := c.Get(ctx, "1", &u); !ok || err != nil || u.Name != "a" {
+		t.Fatalf("expected the L2 value, got %v, %v, %+v", ok, err, u)
+	}
+	l1.Cache().Wait()
+	if _, ok := l1.Cache().Get("levels:1"); !ok {
+		t.Fatal("expected the value backfilled in L1")
+	}
+
+	// L1 hit, redis is not read
+	client.Set(ctx, "levels:1", `{"name":"b"}`, time.Hour)
+	if ok, err := c.Get(ctx, "1", &u); !ok || err != nil || u.Name != "a" {
+		t.Fatalf("expected the L1 value, got %v, %v, %+v", ok, err, u)
+	}
+
+	want := Metrics{L1Hits: 1, L1Misses: 2, L2Hits: 1, L2Misses: 1}
+	if m := c.Metrics(); m != want {
+		t.Fatalf("unexpected metrics: %+v", m)
+	}
+}
+
+func TestInvalidationAcrossInstances(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	l1 := ristretto.GetInc()
+	opts := Options{Namespace: "shared", L1: l1, Client: client}
+	a, b := newTestCache(t, opts), newTestCache(t, opts)
+	ctx := context.Background()
+
+	sub := client.Subscribe(ctx, defaultChannel)
+	defer sub.Close()
+
+	// the instances share L1 here, so the eviction by b shows that the
+	// invalidation of a reached it
+	if err := a.Set(ctx, "1", user{Name: "a"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-sub.Channel()
+	var inv invalidation
+	if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Source != a.id || !reflect.DeepEqual(inv.Keys, []string{"shared:1"}) {
+		t.Fatalf("unexpected invalidation: %+v", inv)
+	}
+	waitEvicted(t, l1, "shared:1")
+
+	var u user
+	if ok, err := b.Get(ctx, "1", &u); !ok || err != nil || u.Name != "a" {
+		t.Fatalf("expected the value of a, got %v, %v, %+v", ok, err, u)
+	}
+
+	if err := b.Delete(ctx, "1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-sub.Channel()
+	if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Source != b.id || !reflect.DeepEqual(inv.Keys, []string{"shared:1", "shared:2"}) {
+		t.Fatalf("unexpected invalidation: %+v", inv)
+	}
+	if ok, err := a.Get(ctx, "1", &u); ok || err != nil {
+		t.Fatalf("expected the key deleted from both levels, got %v, %v", ok, err)
+	}
+}
+
+func waitEvicted(t *testing.T, l1 *ristretto.Ristretto, key string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		l1.Cache().Wait()
+		if _, ok := l1.Cache().Get(key); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %s evicted from L1", key)
+}
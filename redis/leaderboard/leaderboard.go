@@ -0,0 +1,286 @@
+package sealeaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var errInvalidResult = errors.New("leaderboard: unexpected script result")
+
+// Policy define how a submitted score is applied to the current score.
+type Policy int
+
+const (
+	// PolicyMax keep the highest score.
+	PolicyMax Policy = iota
+	// PolicyMin keep the lowest score.
+	PolicyMin
+	// PolicyIncrement add the submitted score to the current score.
+	PolicyIncrement
+)
+
+// Period is the time bucket of a board.
+type Period int
+
+const (
+	AllTime Period = iota
+	Daily
+	Weekly
+	Monthly
+)
+
+const defaultRetention = 7 * 24 * time.Hour
+
+// tieEpoch and tieSpan define the submission time range used for tie-breaking.
+var (
+	tieEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	tieSpan  = float64(int64(1) << 31)
+)
+
+// Options struct contain options of Board.
+type Options struct {
+	// Policy is default to PolicyMax.
+	Policy Policy
+	// Ascending rank lower scores first, e.g. with PolicyMin for race times.
+	Ascending bool
+	// Periods are the boards updated by Submit, default to AllTime only.
+	Periods []Period
+	// Retention is how long a finished daily, weekly or monthly board is
+	// kept, default to 7 days.
+	Retention time.Duration
+	// Location of the period boundaries, default to UTC.
+	Location *time.Location
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Entry is a member of a board.
+type Entry struct {
+	Member   string
+	Score    int64
+	Rank     int64 // start from 1
+	Metadata string
+}
+
+// Board is a leaderboard with time-bucketed boards. Scores are integers,
+// ties are broken by submission time to the second for scores below 2^21,
+// and more coarsely above.
+type Board struct {
+	name string
+	opts Options
+	now  func() time.Time
+}
+
+// New return new leaderboard.
+func New(name string, opts *Options) *Board {
+	b := &Board{name: name, now: time.Now}
+	if opts != nil {
+		b.opts = *opts
+	}
+	if len(b.opts.Periods) == 0 {
+		b.opts.Periods = []Period{AllTime}
+	}
+	if b.opts.Retention <= 0 {
+		b.opts.Retention = defaultRetention
+	}
+	if b.opts.Location == nil {
+		b.opts.Location = time.UTC
+	}
+	return b
+}
+
+// At return the board at the time, to read previous daily or weekly boards.
+func (b *Board) At(t time.Time) *Board {
+	c := *b
+	c.now = func() time.Time { return t }
+	return &c
+}
+
+// Submit apply the score of the member to every period board and return
+// the resulting scores in the order of Options.Periods.
+func (b *Board) Submit(ctx context.Context, member string, score int64) ([]int64, error) {
+	now := b.now()
+
+	keys := make([]string, len(b.opts.Periods))
+	args := []interface{}{b.policy(), score, b.fraction(now), member}
+	for i, period := range b.opts.Periods {
+		keys[i] = b.key(period, now)
+		var expireAt int64
+		if period != AllTime {
+			expireAt = periodEnd(period, now.In(b.opts.Location)).Add(b.opts.Retention).UnixMilli()
+		}
+		args = append(args, expireAt)
+	}
+
+	return submitScript.Run(ctx, b.client(), keys, args...).Int64Slice()
+}
+
+// SetMetadata set the metadata of the member, such as display name or avatar.
+func (b *Board) SetMetadata(ctx context.Context, member, metadata string) error {
+	return b.client().HSet(ctx, b.metaKey(), member, metadata).Err()
+}
+
+// Remove remove the member from the current boards and its metadata.
+func (b *Board) Remove(ctx context.Context, member string) error {
+	now := b.now()
+	pipe := b.client().TxPipeline()
+	for _, period := range b.opts.Periods {
+		pipe.ZRem(ctx, b.key(period, now), member)
+	}
+	pipe.HDel(ctx, b.metaKey(), member)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Rank return the entry of the member, nil if the member is not on the board.
+func (b *Board) Rank(ctx context.Context, period Period, member string) (*Entry, error) {
+	entries, err := b.run(ctx, period, 0, 1, member)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// Top return the first n entries.
+func (b *Board) Top(ctx context.Context, period Period, n int) ([]Entry, error) {
+	return b.Page(ctx, period, 0, n)
+}
+
+// Page return limit entries from the offset.
+func (b *Board) Page(ctx context.Context, period Period, offset, limit int) ([]Entry, error) {
+	if limit <= 0 {
+		return []Entry{}, nil
+	}
+	return b.run(ctx, period, offset, limit, "")
+}
+
+// Around return the member entry with up to n entries before and after it,
+// nil if the member is not on the board.
+func (b *Board) Around(ctx context.Context, period Period, member string, n int) ([]Entry, error) {
+	return b.run(ctx, period, n, 2*n+1, member)
+}
+
+// Count return the number of members on the board.
+func (b *Board) Count(ctx context.Context, period Period) (int64, error) {
+	return b.client().ZCard(ctx, b.key(period, b.now())).Result()
+}
+
+func (b *Board) run(ctx context.Context, period Period, start, count int, member string) ([]Entry, error) {
+	asc := "0"
+	if b.opts.Ascending {
+		asc = "1"
+	}
+
+	res, err := rangeScript.Run(ctx, b.client(), []string{b.key(period, b.now()), b.metaKey()},
+		asc, start, count, member).Slice()
+	if err == goredislib.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseEntries(res)
+}
+
+func (b *Board) policy() string {
+	switch b.opts.Policy {
+	case PolicyMin:
+		return "min"
+	case PolicyIncrement:
+		return "incr"
+	}
+	return "max"
+}
+
+// fraction return the tie-breaking fraction of a submission at the time.
+func (b *Board) fraction(t time.Time) string {
+	offset := float64(t.Unix() - tieEpoch)
+	offset = math.Max(0, math.Min(offset, tieSpan))
+	if !b.opts.Ascending {
+		// earlier submissions get a bigger fraction to rank first
+		offset = tieSpan - offset
+	}
+	return strconv.FormatFloat(offset/(tieSpan+1), 'g', 17, 64)
+}
+
+// key return the board key of the period at the time, every key of the
+// leaderboard shares the "{name}" hash tag so scripts work on cluster.
+func (b *Board) key(period Period, t time.Time) string {
+	t = t.In(b.opts.Location)
+	prefix := "leaderboard:{" + b.name + "}:"
+	switch period {
+	case Daily:
+		return prefix + "d:" + t.Format("20060102")
+	case Weekly:
+		year, week := t.ISOWeek()
+		return prefix + fmt.Sprintf("w:%d-W%02d", year, week)
+	case Monthly:
+		return prefix + "m:" + t.Format("200601")
+	}
+	return prefix + "all"
+}
+
+func (b *Board) metaKey() string {
+	return "leaderboard:{" + b.name + "}:meta"
+}
+
+func (b *Board) client() goredislib.UniversalClient {
+	if b.opts.Client != nil {
+		return b.opts.Client
+	}
+	return searedis.GetClient()
+}
+
+// periodEnd return the end of the period that contains t.
+func periodEnd(period Period, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch period {
+	case Daily:
+		return day.AddDate(0, 0, 1)
+	case Weekly:
+		// ISO weeks start on monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, 7-offset)
+	case Monthly:
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// parseEntries parse {start rank, member, score, metadata, ...} script result.
+func parseEntries(res []interface{}) ([]Entry, error) {
+	if len(res) == 0 || (len(res)-1)%3 != 0 {
+		return nil, errInvalidResult
+	}
+	start, ok := res[0].(int64)
+	if !ok {
+		return nil, errInvalidResult
+	}
+
+	entries := make([]Entry, 0, (len(res)-1)/3)
+	for i := 1; i < len(res); i += 3 {
+		member, _ := res[i].(string)
+		scoreStr, _ := res[i+1].(string)
+		metadata, _ := res[i+2].(string)
+
+		score, err := strconv.ParseFloat(scoreStr, 64)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{
+			Member:   member,
+			Score:    int64(math.Floor(score)),
+			Rank:     start + int64(len(entries)) + 1,
+			Metadata: metadata,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,76 @@
+package sealeaderboard
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFractionBreakTies(t *testing.T) {
+	early := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	late := early.Add(time.Second)
+
+	desc := New("game", nil)
+	if parse(t, desc.fraction(early)) <= parse(t, desc.fraction(late)) {
+		t.Fatal("descending board should rank earlier submissions first")
+	}
+
+	asc := New("race", &Options{Ascending: true})
+	if parse(t, asc.fraction(early)) >= parse(t, asc.fraction(late)) {
+		t.Fatal("ascending board should rank earlier submissions first")
+	}
+
+	for _, f := range []string{desc.fraction(early), asc.fraction(early)} {
+		if v := parse(t, f); v < 0 || v >= 1 {
+			t.Fatalf("fraction out of range: %v", v)
+		}
+	}
+}
+
+func TestKeyAndPeriodEnd(t *testing.T) {
+	b := New("game", nil)
+	now := time.Date(2026, 10, 21, 15, 4, 5, 0, time.UTC) // wednesday
+
+	cases := []struct {
+		period Period
+		key    string
+		end    time.Time
+	}{
+		{Daily, "leaderboard:{game}:d:20261021", time.Date(2026, 10, 22, 0, 0, 0, 0, time.UTC)},
+		{Weekly, "leaderboard:{game}:w:2026-W43", time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+		{Monthly, "leaderboard:{game}:m:202610", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{AllTime, "leaderboard:{game}:all", time.Time{}},
+	}
+	for _, c := range cases {
+		if got := b.key(c.period, now); got != c.key {
+			t.Fatalf("expected key %s, got %s", c.key, got)
+		}
+		if got := periodEnd(c.period, now); !got.Equal(c.end) {
+			t.Fatalf("expected end %s, got %s", c.end, got)
+		}
+	}
+}
+
+func TestParseEntries(t *testing.T) {
+	entries, err := parseEntries([]interface{}{int64(4), "alice", "120.75", "{}", "bob", "-3.5", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].Member != "alice" || entries[0].Score != 120 || entries[0].Rank != 5 || entries[0].Metadata != "{}" {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+	if entries[1].Score != -4 || entries[1].Rank != 6 {
+		t.Fatalf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func parse(t *testing.T, s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package sealeaderboard
+
+import searedis "github.com/ponlv/go-kit/redis"
+
+// Scores are stored as score + fraction, the fraction in [0, 1) is computed
+// from the submission time so earlier submissions rank first on ties.
+
+// submitScript apply the score to every board of the member and return the
+// resulting scores.
+// KEYS: boards, ARGV: policy, score, fraction, member, expire at (ms) of each board
+var submitScript = searedis.RegisterScript("leaderboard:submit", `
+local policy = ARGV[1]
+local score = tonumber(ARGV[2])
+local frac = tonumber(ARGV[3])
+local member = ARGV[4]
+local result = {}
+
+for i, key in ipairs(KEYS) do
+  local current = redis.call("ZSCORE", key, member)
+  local value = score
+  if current then
+    current = math.floor(tonumber(current))
+    if policy == "incr" then
+      value = current + score
+    elseif policy == "max" and score <= current then
+      value = nil
+    elseif policy == "min" and score >= current then
+      value = nil
+    end
+  end
+
+  if value then
+    redis.call("ZADD", key, string.format("%.17g", value + frac), member)
+    result[i] = value
+  else
+    result[i] = current
+  end
+
+  local expireAt = tonumber(ARGV[4 + i])
+  if expireAt > 0 then
+    redis.call("PEXPIREAT", key, expireAt)
+  end
+end
+
+return result
+`)
+
+// rangeScript return {start rank, member, score, metadata, ...} of a range
+// of the board. With a member, the range is centered on it and nil is
+// returned if the member is not on the board.
+// KEYS[1]: board, KEYS[2]: metadata hash, ARGV: ascending (1/0), start, count, member
+var rangeScript = searedis.RegisterScript("leaderboard:range", `
+local asc = ARGV[1] == "1"
+local start = tonumber(ARGV[2])
+local count = tonumber(ARGV[3])
+
+if ARGV[4] ~= "" then
+  local rank
+  if asc then
+    rank = redis.call("ZRANK", KEYS[1], ARGV[4])
+  else
+    rank = redis.call("ZREVRANK", KEYS[1], ARGV[4])
+  end
+  if not rank then
+    return nil
+  end
+  start = math.max(rank - start, 0)
+end
+
+local entries
+if asc then
+  entries = redis.call("ZRANGE", KEYS[1], start, start + count - 1, "WITHSCORES")
+else
+  entries = redis.call("ZREVRANGE", KEYS[1], start, start + count - 1, "WITHSCORES")
+end
+
+local result = {start}
+if #entries == 0 then
+  return result
+end
+
+local members = {}
+for i = 1, #entries, 2 do
+  members[#members + 1] = entries[i]
+end
+local metadata = redis.call("HMGET", KEYS[2], unpack(members))
+
+for i = 1, #members do
+  result[#result + 1] = members[i]
+  result[#result + 1] = entries[i * 2]
+  result[#result + 1] = metadata[i] or ""
+end
+return result
+`)
@@ -12,6 +12,7 @@ var rs *redsync.Redsync
 
 func InitPool(pool redis.Pool) {
 	rs = redsync.New(pool)
+	defaultLocker = &Locker{rs: rs, pool: pool}
 }
 
 // Func: how to use thetanlock
@@ -0,0 +1,263 @@
+package sealock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/go-redsync/redsync/v4/redis"
+)
+
+var (
+	// ErrLockLost is returned by WithLock when the lock expired or was
+	// taken by another owner while fn was running.
+	ErrLockLost = errors.New("lock: lock lost")
+	// ErrNotInit is returned when neither InitPool nor NewLocker was called.
+	ErrNotInit = errors.New("lock: pool is not initialized")
+)
+
+const defaultExpiry = 8 * time.Second
+
+// fenceScript increase the fencing token of a lock.
+var fenceScript = redis.NewScript(1, `return redis.call("INCR", KEYS[1])`)
+
+// Options struct contain options of LockCtx.
+type Options struct {
+	// Expiry is default to 8 seconds.
+	Expiry time.Duration
+	// Tries is default to 32.
+	Tries int
+	// RetryDelay is default to random between 50 - 250ms.
+	RetryDelay time.Duration
+	// ExtendInterval is how often the lock is extended while held,
+	// default to Expiry/3, negative value disables the extension and the
+	// lock is held until Expiry.
+	ExtendInterval time.Duration
+}
+
+// Locker create context-aware locks over a redis pool.
+type Locker struct {
+	rs   *redsync.Redsync
+	pool redis.Pool
+}
+
+var defaultLocker *Locker
+
+// NewLocker return new locker over the pool.
+func NewLocker(pool redis.Pool) *Locker {
+	return &Locker{rs: redsync.New(pool), pool: pool}
+}
+
+// Lease is a held lock, it's extended in background until Unlock.
+type Lease struct {
+	mutex *redsync.Mutex
+	token int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	doneOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// LockCtx lock the mutex with the default locker, see Locker.LockCtx.
+func LockCtx(ctx context.Context, mutexId string, opts *Options) (*Lease, error) {
+	if defaultLocker == nil {
+		return nil, ErrNotInit
+	}
+	return defaultLocker.LockCtx(ctx, mutexId, opts)
+}
+
+// WithLock run fn while holding the lock of the default locker, see Locker.WithLock.
+func WithLock(ctx context.Context, mutexId string, fn func(ctx context.Context) error) error {
+	if defaultLocker == nil {
+		return ErrNotInit
+	}
+	return defaultLocker.WithLock(ctx, mutexId, nil, fn)
+}
+
+// LockCtx lock the mutex, it stops retrying when ctx is done.
+//
+// ctx only bound the acquisition, it's usually a short timeout: the lock is
+// extended in background until Unlock is called, whatever happens to ctx.
+// Always call Unlock, or use WithLock. A failed extension is retried until
+// the lock is about to expire, then Done is closed.
+func (l *Locker) LockCtx(ctx context.Context, mutexId string, opts *Options) (*Lease, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	expiry := opts.Expiry
+	if expiry <= 0 {
+		expiry = defaultExpiry
+	}
+
+	mutexOpts := []redsync.Option{redsync.WithExpiry(expiry)}
+	if opts.Tries > 0 {
+		mutexOpts = append(mutexOpts, redsync.WithTries(opts.Tries))
+	}
+	if opts.RetryDelay > 0 {
+		mutexOpts = append(mutexOpts, redsync.WithRetryDelay(opts.RetryDelay))
+	}
+
+	mutex := l.rs.NewMutex(mutexId, mutexOpts...)
+	if err := mutex.LockContext(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := l.fence(ctx, mutexId)
+	if err != nil {
+		_, _ = mutex.Unlock()
+		return nil, err
+	}
+
+	lock := &Lease{
+		mutex: mutex,
+		token: token,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	interval := opts.ExtendInterval
+	if interval == 0 {
+		interval = expiry / 3
+	}
+	lock.wg.Add(1)
+	if interval > 0 {
+		go lock.extend(interval)
+	} else {
+		go lock.expire()
+	}
+
+	return lock, nil
+}
+
+// WithLock run fn while holding the lock and always release it after.
+// ctx of fn is canceled when the lock is lost, in which case
+// ErrLockLost is returned if fn did not return an error.
+func (l *Locker) WithLock(ctx context.Context, mutexId string, opts *Options, fn func(ctx context.Context) error) error {
+	lock, err := l.LockCtx(ctx, mutexId, opts)
+	if err != nil {
+		return err
+	}
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lock.Done():
+			cancel()
+		case <-fnCtx.Done():
+		}
+	}()
+
+	err = fn(fnCtx)
+
+	lost := lock.isLost()
+	if _, unlockErr := lock.Unlock(); err == nil && !lost {
+		err = unlockErr
+	}
+	if err == nil && lost {
+		err = ErrLockLost
+	}
+	return err
+}
+
+// fence return the next fencing token of the mutex.
+func (l *Locker) fence(ctx context.Context, mutexId string) (int64, error) {
+	conn, err := l.pool.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	res, err := conn.Eval(fenceScript, mutexId+":fence")
+	if err != nil {
+		return 0, err
+	}
+	token, ok := res.(int64)
+	if !ok {
+		return 0, errors.New("lock: unexpected fencing token")
+	}
+	return token, nil
+}
+
+// Token return the fencing token of the lock, it's greater than the token
+// of every previous holder of the same mutex. Pass it to downstream writes
+// so they can reject writes of stale holders.
+func (lock *Lease) Token() int64 {
+	return lock.token
+}
+
+// Mutex return the underlying mutex.
+func (lock *Lease) Mutex() *redsync.Mutex {
+	return lock.mutex
+}
+
+// Done return a channel that is closed when the lock is not held anymore,
+// either released or failed to be extended.
+func (lock *Lease) Done() <-chan struct{} {
+	return lock.done
+}
+
+// Unlock stop extending the lock and release it.
+func (lock *Lease) Unlock() (bool, error) {
+	lock.stopOnce.Do(func() { close(lock.stop) })
+	lock.wg.Wait()
+	lock.markDone()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultExpiry)
+	defer cancel()
+	return lock.mutex.UnlockContext(ctx)
+}
+
+func (lock *Lease) extend(interval time.Duration) {
+	defer lock.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			ok, err := lock.mutex.ExtendContext(ctx)
+			cancel()
+			if (err != nil || !ok) && !time.Now().Add(interval).Before(lock.mutex.Until()) {
+				// the next try would be too late
+				lock.markDone()
+				return
+			}
+		}
+	}
+}
+
+// expire close Done when a lock without extension expires.
+func (lock *Lease) expire() {
+	defer lock.wg.Done()
+
+	timer := time.NewTimer(time.Until(lock.mutex.Until()))
+	defer timer.Stop()
+
+	select {
+	case <-lock.stop:
+	case <-timer.C:
+		lock.markDone()
+	}
+}
+
+func (lock *Lease) markDone() {
+	lock.doneOnce.Do(func() { close(lock.done) })
+}
+
+func (lock *Lease) isLost() bool {
+	select {
+	case <-lock.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package sealock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4/redis"
+)
+
+// fakePool keeps the locks in memory, Eval understands the redsync scripts
+// and the fencing script.
+type fakePool struct {
+	mu         sync.Mutex
+	values     map[string]string
+	expires    map[string]time.Time
+	counters   map[string]int64
+	failExtend bool
+}
+
+func newFakePool() *fakePool {
+	return &fakePool{values: map[string]string{}, expires: map[string]time.Time{}, counters: map[string]int64{}}
+}
+
+func (p *fakePool) Get(ctx context.Context) (redis.Conn, error) {
+	return &fakeConn{p}, nil
+}
+
+func (p *fakePool) held(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.values[name]
+	return ok && time.Now().Before(p.expires[name])
+}
+
+type fakeConn struct {
+	p *fakePool
+}
+
+func (c *fakeConn) Get(name string) (string, error) {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	return c.p.values[name], nil
+}
+
+func (c *fakeConn) Set(name string, value string) (bool, error) {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	c.p.values[name] = value
+	return true, nil
+}
+
+func (c *fakeConn) SetNX(name string, value string, expiry time.Duration) (bool, error) {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	if _, ok := c.p.values[name]; ok && time.Now().Before(c.p.expires[name]) {
+		return false, nil
+	}
+	c.p.values[name] = value
+	c.p.expires[name] = time.Now().Add(expiry)
+	return true, nil
+}
+
+func (c *fakeConn) Eval(script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+
+	key := keysAndArgs[0].(string)
+	switch {
+	case strings.Contains(script.Src, "INCR"):
+		c.p.counters[key]++
+		return c.p.counters[key], nil
+	case strings.Contains(script.Src, "PEXPIRE"):
+		if c.p.failExtend {
+			return nil, errors.New("connection reset")
+		}
+		if c.p.values[key] != keysAndArgs[1].(string) {
+			return int64(0), nil
+		}
+		c.p.expires[key] = time.Now().Add(time.Duration(keysAndArgs[2].(int)) * time.Millisecond)
+		return int64(1), nil
+	case strings.Contains(script.Src, "DEL"):
+		if c.p.values[key] != keysAndArgs[1].(string) {
+			return int64(0), nil
+		}
+		delete(c.p.values, key)
+		return int64(1), nil
+	}
+	return nil, errors.New("unknown script")
+}
+
+func (c *fakeConn) PTTL(name string) (time.Duration, error) {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	return time.Until(c.p.expires[name]), nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func TestLockCtxOutlivesAcquireCtx(t *testing.T) {
+	pool := newFakePool()
+	l := NewLocker(pool)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	lease, err := l.LockCtx(ctx, "job", &Options{Expiry: 150 * time.Millisecond})
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Token() != 1 {
+		t.Fatalf("expected token 1, got %d", lease.Token())
+	}
+
+	// held past the acquisition ctx and the first expiry
+	time.Sleep(300 * time.Millisecond)
+	if !pool.held("job") || lease.isLost() {
+		t.Fatal("lock should still be held")
+	}
+
+	if ok, err := lease.Unlock(); !ok || err != nil {
+		t.Fatalf("unexpected unlock result %v, %v", ok, err)
+	}
+	if pool.held("job") || !lease.isLost() {
+		t.Fatal("lock should be released")
+	}
+
+	lease, err = l.LockCtx(context.Background(), "job", nil)
+	if err != nil || lease.Token() != 2 {
+		t.Fatalf("expected token 2, got %v, %v", lease, err)
+	}
+	lease.Unlock()
+}
+
+func TestLockCtxDone(t *testing.T) {
+	pool := newFakePool()
+	l := NewLocker(pool)
+
+	// without extension, Done is closed at expiry
+	lease, err := l.LockCtx(context.Background(), "report", &Options{Expiry: 100 * time.Millisecond, ExtendInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lease.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed at expiry")
+	}
+
+	// a transient extension error is retried until the lock is about to expire
+	lease, err = l.LockCtx(context.Background(), "sync", &Options{Expiry: 300 * time.Millisecond, ExtendInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.mu.Lock()
+	pool.failExtend = true
+	pool.mu.Unlock()
+	time.Sleep(80 * time.Millisecond)
+	if lease.isLost() {
+		t.Fatal("one failed extension should not lose the lock")
+	}
+	select {
+	case <-lease.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed before the lock expires")
+	}
+	lease.Unlock()
+}
+
+func TestWithLockLost(t *testing.T) {
+	pool := newFakePool()
+	l := NewLocker(pool)
+
+	err := l.WithLock(context.Background(), "import", &Options{Expiry: 100 * time.Millisecond, ExtendInterval: -1}, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	if err != ErrLockLost {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package seapubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var logger = plog.NewBizLogger("redis-pubsub")
+
+var (
+	// ErrClosed is returned when subscribing to a closed subscriber.
+	ErrClosed = errors.New("pubsub: subscriber is closed")
+	// ErrNoClient is returned when redis is not connected and no client is given.
+	ErrNoClient = errors.New("pubsub: redis client is not initialized")
+)
+
+const (
+	defaultConcurrency = 10
+	healthCheck        = 30 * time.Second
+	retryDelay         = time.Second
+)
+
+// Options struct contain options of Subscriber.
+type Options struct {
+	// Concurrency is the max number of handlers running at the same time,
+	// default to 10. Receiving blocks while all handlers are busy.
+	Concurrency int
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Handler handle a message of the channel decoded as T.
+type Handler[T any] func(ctx context.Context, channel string, msg T) error
+
+type handler func(ctx context.Context, channel, payload string) error
+
+// Subscriber dispatch messages of its channels and patterns to typed
+// handlers. The connection is re-established and every subscription
+// restored after a connection loss.
+type Subscriber struct {
+	opts Options
+	ps   *goredislib.PubSub
+	sem  chan struct{}
+
+	mu       sync.RWMutex
+	channels map[string]handler
+	patterns map[string]handler
+	started  bool
+	closed   bool
+
+	loop     sync.WaitGroup
+	handlers sync.WaitGroup
+}
+
+// NewSubscriber return new subscriber, it connects on the first subscription.
+func NewSubscriber(opts *Options) (*Subscriber, error) {
+	s := &Subscriber{
+		channels: make(map[string]handler),
+		patterns: make(map[string]handler),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Concurrency <= 0 {
+		s.opts.Concurrency = defaultConcurrency
+	}
+	if s.opts.Client == nil {
+		s.opts.Client = searedis.GetClient()
+	}
+	if s.opts.Client == nil {
+		return nil, ErrNoClient
+	}
+	s.sem = make(chan struct{}, s.opts.Concurrency)
+	s.ps = s.opts.Client.Subscribe(context.Background())
+	return s, nil
+}
+
+// Publish send the value encoded as JSON, same as SetObject, to the channel
+// with the client of searedis.ConnectRedisV1.
+func Publish(ctx context.Context, channel string, value interface{}) error {
+	return PublishWithClient(ctx, searedis.GetClient(), channel, value)
+}
+
+// PublishWithClient send the value encoded as JSON to the channel with the client.
+func PublishWithClient(ctx context.Context, c goredislib.UniversalClient, channel string, value interface{}) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Publish(ctx, channel, data).Err()
+}
+
+// Subscribe call h with messages of the channel decoded as T.
+// EX used: err = seapubsub.Subscribe(ctx, sub, "config", func(ctx context.Context, channel string, conf Config) error {...})
+func Subscribe[T any](ctx context.Context, s *Subscriber, channel string, h Handler[T]) error {
+	return s.subscribe(ctx, channel, false, typed(h))
+}
+
+// PSubscribe call h with messages of the channels matching the pattern decoded as T.
+func PSubscribe[T any](ctx context.Context, s *Subscriber, pattern string, h Handler[T]) error {
+	return s.subscribe(ctx, pattern, true, typed(h))
+}
+
+// Unsubscribe remove the channels and their handlers.
+func (s *Subscriber) Unsubscribe(ctx context.Context, channels ...string) error {
+	s.mu.Lock()
+	for _, channel := range channels {
+		delete(s.channels, channel)
+	}
+	s.mu.Unlock()
+	return s.ps.Unsubscribe(ctx, channels...)
+}
+
+// PUnsubscribe remove the patterns and their handlers.
+func (s *Subscriber) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	s.mu.Lock()
+	for _, pattern := range patterns {
+		delete(s.patterns, pattern)
+	}
+	s.mu.Unlock()
+	return s.ps.PUnsubscribe(ctx, patterns...)
+}
+
+// Close stop receiving messages and wait for running handlers.
+func (s *Subscriber) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.ps.Close()
+	s.loop.Wait()
+	s.handlers.Wait()
+	return err
+}
+
+func (s *Subscriber) subscribe(ctx context.Context, name string, pattern bool, h handler) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	if pattern {
+		s.patterns[name] = h
+	} else {
+		s.channels[name] = h
+	}
+	start := !s.started
+	s.started = true
+	s.mu.Unlock()
+
+	var err error
+	if pattern {
+		err = s.ps.PSubscribe(ctx, name)
+	} else {
+		err = s.ps.Subscribe(ctx, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if start {
+		s.loop.Add(1)
+		go s.receive()
+	}
+	return nil
+}
+
+func (s *Subscriber) receive() {
+	defer s.loop.Done()
+	ctx := context.Background()
+
+	for {
+		msg, err := s.ps.ReceiveTimeout(ctx, healthCheck)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// nothing received, check the connection is still alive
+				_ = s.ps.Ping(ctx)
+				continue
+			}
+			// the next receive reconnects and restores the subscriptions
+			logger.Error().Err(err).Msg("error when receive a message")
+			time.Sleep(retryDelay)
+			continue
+		}
+
+		if m, ok := msg.(*goredislib.Message); ok {
+			s.dispatch(ctx, m)
+		}
+	}
+}
+
+func (s *Subscriber) dispatch(ctx context.Context, m *goredislib.Message) {
+	s.mu.RLock()
+	h, ok := s.channels[m.Channel]
+	if m.Pattern != "" {
+		h, ok = s.patterns[m.Pattern]
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	s.sem <- struct{}{}
+	s.handlers.Add(1)
+	go func() {
+		defer func() {
+			<-s.sem
+			s.handlers.Done()
+		}()
+		if err := h(ctx, m.Channel, m.Payload); err != nil {
+			logger.Error().Err(err).Var("channel", m.Channel).Msg("error when handle a message")
+		}
+	}()
+}
+
+func (s *Subscriber) isClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closed
+}
+
+func typed[T any](h Handler[T]) handler {
+	return func(ctx context.Context, channel, payload string) error {
+		var msg T
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			return err
+		}
+		return h(ctx, channel, msg)
+	}
+}
@@ -0,0 +1,62 @@
+package seapubsub
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+type event struct {
+	Key string `json:"key"`
+}
+
+func TestDispatchBoundedConcurrency(t *testing.T) {
+	s := &Subscriber{
+		channels: make(map[string]handler),
+		patterns: make(map[string]handler),
+		sem:      make(chan struct{}, 2),
+	}
+
+	var running, peak, handled int32
+	s.patterns["cache:*"] = typed(func(ctx context.Context, channel string, e event) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if e.Key == "user:42" && channel == "cache:user" {
+			atomic.AddInt32(&handled, 1)
+		}
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+
+	for i := 0; i < 6; i++ {
+		s.dispatch(context.Background(), &goredislib.Message{Channel: "cache:user", Pattern: "cache:*", Payload: `{"key":"user:42"}`})
+	}
+	// no handler, ignored
+	s.dispatch(context.Background(), &goredislib.Message{Channel: "other", Payload: `{}`})
+	s.handlers.Wait()
+
+	if handled != 6 {
+		t.Fatalf("expected 6 handled messages, got %d", handled)
+	}
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent handlers, got %d", peak)
+	}
+}
+
+func TestNoClient(t *testing.T) {
+	if _, err := NewSubscriber(nil); err != ErrNoClient {
+		t.Fatalf("expected ErrNoClient, got %v", err)
+	}
+	if err := Publish(context.Background(), "config", event{}); err != ErrNoClient {
+		t.Fatalf("expected ErrNoClient, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package searatelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// ErrLimited is returned by Wait when the limit can not be acquired before ctx is done.
+var ErrLimited = errors.New("ratelimit: rate limit exceeded")
+
+var errUnexpectedResult = errors.New("ratelimit: unexpected script result")
+
+// Algorithm is the rate limit algorithm.
+type Algorithm int
+
+const (
+	// GCRA is the generic cell rate algorithm (token bucket), it allows
+	// bursts up to Burst and then smooths requests over the period.
+	GCRA Algorithm = iota
+	// SlidingWindow keeps a log of requests in the last period, it's the
+	// most accurate but costs memory per request.
+	SlidingWindow
+	// FixedWindow counts requests in fixed periods, it's the cheapest but
+	// allows up to twice the rate around window boundaries.
+	FixedWindow
+)
+
+// Limit define how many requests are allowed per period.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	// Burst is used by GCRA only, default to Rate.
+	Burst     int
+	Algorithm Algorithm
+}
+
+// PerSecond return GCRA limit of rate requests per second.
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second, Burst: rate}
+}
+
+// PerMinute return GCRA limit of rate requests per minute.
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute, Burst: rate}
+}
+
+// PerHour return GCRA limit of rate requests per hour.
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour, Burst: rate}
+}
+
+// Result is the result of Allow.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of requests that can be made right now.
+	Remaining int
+	// RetryAfter is the time to wait before the next allowed request,
+	// 0 if the request is allowed, -1 if it will never be allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter is a distributed rate limiter over redis.
+type Limiter struct {
+	client goredislib.UniversalClient
+	prefix string
+}
+
+// NewLimiter return new limiter, nil client means the client of searedis.ConnectRedisV1.
+func NewLimiter(client goredislib.UniversalClient) *Limiter {
+	return &Limiter{client: client, prefix: "ratelimit"}
+}
+
+// WithPrefix set the prefix of limiter keys, default to "ratelimit".
+func (l *Limiter) WithPrefix(prefix string) *Limiter {
+	l.prefix = prefix
+	return l
+}
+
+// Allow report whether one request of key is allowed.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	return l.AllowN(ctx, key, limit, 1)
+}
+
+// AllowN report whether n requests of key are allowed at once.
+func (l *Limiter) AllowN(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return nil, errors.New("ratelimit: invalid limit")
+	}
+
+	switch limit.Algorithm {
+	case GCRA:
+		return l.gcra(ctx, key, limit, n)
+	case SlidingWindow:
+		return l.slidingWindow(ctx, key, limit, n)
+	case FixedWindow:
+		return l.fixedWindow(ctx, key, limit, n)
+	}
+	return nil, errors.New("ratelimit: unknown algorithm")
+}
+
+// Wait block until one request of key is allowed or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, key string, limit Limit) error {
+	for {
+		res, err := l.Allow(ctx, key, limit)
+		if err != nil {
+			return err
+		}
+		if res.Allowed {
+			return nil
+		}
+		if res.RetryAfter < 0 {
+			return ErrLimited
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrLimited
+		case <-time.After(res.RetryAfter):
+		}
+	}
+}
+
+// Reset clear the state of key.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	pipe := l.redis().Pipeline()
+	for _, algo := range []Algorithm{GCRA, SlidingWindow, FixedWindow} {
+		pipe.Del(ctx, l.key(key, algo))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (l *Limiter) gcra(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Rate
+	}
+
+	values, err := gcraScript.Run(ctx, l.redis(), []string{l.key(key, GCRA)},
+		burst, limit.Rate, limit.Period.Seconds(), n).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values)
+}
+
+func (l *Limiter) slidingWindow(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	values, err := slidingWindowScript.Run(ctx, l.redis(), []string{l.key(key, SlidingWindow)},
+		limit.Rate, limit.Period.Milliseconds(), n, token()).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values)
+}
+
+func (l *Limiter) fixedWindow(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	values, err := fixedWindowScript.Run(ctx, l.redis(), []string{l.key(key, FixedWindow)},
+		limit.Rate, limit.Period.Milliseconds(), n).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseResult(values)
+}
+
+func (l *Limiter) redis() goredislib.UniversalClient {
+	if l.client != nil {
+		return l.client
+	}
+	return searedis.GetClient()
+}
+
+func (l *Limiter) key(key string, algo Algorithm) string {
+	return l.prefix + ":" + strconv.Itoa(int(algo)) + ":" + key
+}
+
+// parseResult parse {allowed, remaining, retry_after_ms} script result.
+func parseResult(values []interface{}) (*Result, error) {
+	if len(values) != 3 {
+		return nil, errUnexpectedResult
+	}
+
+	allowed, ok1 := values[0].(int64)
+	remaining, ok2 := values[1].(int64)
+	retryAfterStr, ok3 := values[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errUnexpectedResult
+	}
+	retryAfter, err := strconv.ParseFloat(retryAfterStr, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Allowed:   allowed == 1,
+		Remaining: int(remaining),
+	}
+	if retryAfter < 0 {
+		res.RetryAfter = -1
+	} else {
+		res.RetryAfter = time.Duration(retryAfter * float64(time.Millisecond))
+	}
+	return res, nil
+}
+
+// token return random member prefix for the sliding window log.
+func token() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,157 @@
+package searatelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+)
+
+func TestParseResult(t *testing.T) {
+	res, err := parseResult([]interface{}{int64(0), int64(2), "1500.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Remaining != 2 || res.RetryAfter != 1500500*time.Microsecond {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	res, err = parseResult([]interface{}{int64(0), int64(0), "-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RetryAfter != -1 {
+		t.Fatalf("expected never allowed, got %+v", res)
+	}
+
+	if _, err = parseResult([]interface{}{int64(1), int64(2), int64(0)}); err != errUnexpectedResult {
+		t.Fatalf("expected errUnexpectedResult, got %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	l := NewLimiter(nil).WithPrefix("rl")
+	if got := l.key("login:42", SlidingWindow); got != "rl:1:login:42" {
+		t.Fatalf("unexpected key: %s", got)
+	}
+}
+
+// allowN run AllowN and fail the test on error.
+func allowN(t *testing.T, l *Limiter, limit Limit, n int) *Result {
+	t.Helper()
+	res, err := l.AllowN(context.Background(), "user:1", limit, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestFixedWindowScript(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	l := NewLimiter(client)
+	limit := Limit{Rate: 2, Period: time.Minute, Algorithm: FixedWindow}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		if res := allowN(t, l, limit, 1); !res.Allowed || res.Remaining != remaining {
+			t.Fatalf("expected allowed with %d remaining, got %+v", remaining, res)
+		}
+	}
+	res := allowN(t, l, limit, 1)
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Fatalf("expected limited until the window ends, got %+v", res)
+	}
+	// rejected requests are not counted
+	if n, _ := client.Get(context.Background(), l.key("user:1", FixedWindow)).Int(); n != 2 {
+		t.Fatalf("expected 2 counted requests, got %d", n)
+	}
+	if res := allowN(t, l, limit, 3); res.Allowed || res.RetryAfter != -1 {
+		t.Fatalf("expected never allowed, got %+v", res)
+	}
+
+	m.FastForward(time.Minute)
+	if res := allowN(t, l, limit, 2); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected allowed in the next window, got %+v", res)
+	}
+}
+
+func TestSlidingWindowScript(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	now := time.Now()
+	m.SetTime(now)
+	l := NewLimiter(client)
+	limit := Limit{Rate: 2, Period: time.Second, Algorithm: SlidingWindow}
+
+	allowN(t, l, limit, 1)
+	m.SetTime(now.Add(400 * time.Millisecond))
+	if res := allowN(t, l, limit, 1); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected allowed, got %+v", res)
+	}
+	res := allowN(t, l, limit, 1)
+	if res.Allowed || res.RetryAfter != 600*time.Millisecond {
+		t.Fatalf("expected limited until the oldest request leaves, got %+v", res)
+	}
+	// two requests wait for both to leave the window
+	if res := allowN(t, l, limit, 2); res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("expected limited until both requests leave, got %+v", res)
+	}
+
+	m.SetTime(now.Add(1001 * time.Millisecond))
+	if res := allowN(t, l, limit, 1); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected allowed after the oldest request left, got %+v", res)
+	}
+	if res := allowN(t, l, limit, 3); res.Allowed || res.RetryAfter != -1 {
+		t.Fatalf("expected never allowed, got %+v", res)
+	}
+}
+
+func TestGCRAScript(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	now := time.Now()
+	m.SetTime(now)
+	l := NewLimiter(client)
+	limit := Limit{Rate: 2, Period: time.Second, Burst: 2}
+
+	if res := allowN(t, l, limit, 2); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected the burst to be allowed, got %+v", res)
+	}
+	res := allowN(t, l, limit, 1)
+	if res.Allowed || res.RetryAfter < 490*time.Millisecond || res.RetryAfter > 500*time.Millisecond {
+		t.Fatalf("expected limited for one emission interval, got %+v", res)
+	}
+
+	m.SetTime(now.Add(500 * time.Millisecond))
+	if res := allowN(t, l, limit, 1); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected allowed after one emission interval, got %+v", res)
+	}
+	if res := allowN(t, l, limit, 3); res.Allowed || res.RetryAfter != -1 {
+		t.Fatalf("expected never allowed over the burst, got %+v", res)
+	}
+
+	if err := l.Reset(context.Background(), "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	if res := allowN(t, l, limit, 2); !res.Allowed {
+		t.Fatalf("expected allowed after reset, got %+v", res)
+	}
+}
+
+func TestWait(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	l := NewLimiter(client)
+	limit := Limit{Rate: 1, Period: time.Minute, Algorithm: FixedWindow}
+
+	if err := l.Wait(context.Background(), "user:1", limit); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "user:1", limit); err != ErrLimited {
+		t.Fatalf("expected ErrLimited, got %v", err)
+	}
+
+	m.Close()
+	if err := l.Wait(context.Background(), "user:1", limit); err == nil || err == ErrLimited {
+		t.Fatalf("expected the redis error, got %v", err)
+	}
+}
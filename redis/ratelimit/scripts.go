@@ -0,0 +1,121 @@
+package searatelimit
+
+import searedis "github.com/ponlv/go-kit/redis"
+
+// All scripts return {allowed, remaining, retry_after_ms}, retry_after_ms
+// is a string to keep the fraction and is -1 when never allowed.
+
+// gcraScript implement GCRA, the theoretical arrival time (tat) is
+// stored in seconds since 2017-01-01 to keep float precision.
+// KEYS[1]: key, ARGV: burst, rate, period (seconds), cost
+var gcraScript = searedis.RegisterScript("ratelimit:gcra", `
+redis.replicate_commands()
+
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local emission_interval = period / rate
+local increment = emission_interval * cost
+local burst_offset = emission_interval * burst
+
+if cost > burst then
+  return {0, 0, "-1"}
+end
+
+local jan_1_2017 = 1483228800
+local now = redis.call("TIME")
+now = (now[1] - jan_1_2017) + (now[2] / 1000000)
+
+local tat = redis.call("GET", key)
+if not tat then
+  tat = now
+else
+  tat = tonumber(tat)
+end
+tat = math.max(tat, now)
+
+local new_tat = tat + increment
+local allow_at = new_tat - burst_offset
+local diff = now - allow_at
+
+if diff < 0 then
+  local remaining = math.floor((now - (tat - burst_offset)) / emission_interval)
+  return {0, math.max(remaining, 0), tostring(-diff * 1000)}
+end
+
+local reset_after = new_tat - now
+if reset_after > 0 then
+  redis.call("SET", key, tostring(new_tat), "EX", math.ceil(reset_after))
+end
+
+return {1, math.floor(diff / emission_interval), "0"}
+`)
+
+// slidingWindowScript keep a sorted set of request timestamps in the window.
+// KEYS[1]: key, ARGV: limit, window (ms), cost, member prefix
+var slidingWindowScript = searedis.RegisterScript("ratelimit:sliding_window", `
+redis.replicate_commands()
+
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+if cost > limit then
+  return {0, 0, "-1"}
+end
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+
+if count + cost > limit then
+  -- wait until enough of the oldest requests leave the window
+  local oldest = redis.call("ZRANGE", key, count + cost - limit - 1, count + cost - limit - 1, "WITHSCORES")
+  local retry = window
+  if oldest[2] then
+    retry = tonumber(oldest[2]) + window - now
+  end
+  return {0, limit - count, tostring(retry)}
+end
+
+for i = 1, cost do
+  redis.call("ZADD", key, now, ARGV[4] .. ":" .. i)
+end
+redis.call("PEXPIRE", key, window)
+
+return {1, limit - count - cost, "0"}
+`)
+
+// fixedWindowScript count requests of the current window, rejected
+// requests are not counted.
+// KEYS[1]: key, ARGV: limit, window (ms), cost
+var fixedWindowScript = searedis.RegisterScript("ratelimit:fixed_window", `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+if cost > limit then
+  return {0, 0, "-1"}
+end
+
+local current = redis.call("INCRBY", key, cost)
+local ttl = redis.call("PTTL", key)
+if ttl < 0 then
+  redis.call("PEXPIRE", key, window)
+  ttl = window
+end
+
+if current > limit then
+  redis.call("DECRBY", key, cost)
+  return {0, math.max(limit - (current - cost), 0), tostring(ttl)}
+end
+
+return {1, limit - current, "0"}
+`)
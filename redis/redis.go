@@ -3,8 +3,11 @@ package searedis
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/ponlv/go-kit/plog"
 	plock "github.com/ponlv/go-kit/redis/lock"
 
 	goredislib "github.com/go-redis/redis/v8"
@@ -12,39 +15,92 @@ import (
 	"github.com/go-redsync/redsync/v4/redis/goredis/v8"
 )
 
-var client *goredislib.Client
+var client goredislib.UniversalClient
+
+var logger = plog.NewBizLogger("redis")
 
 func NewPool() redis.Pool {
 	return goredis.NewPool(client)
 }
 
 func ConnectRedisV1(config *RedisConnectionConfig) error {
-	client = goredislib.NewClient(&goredislib.Options{
-		Addr:         config.Addr,
-		Username:     config.UserName,
-		Password:     config.Password,
-		DB:           config.Database,
-		PoolSize:     config.PoolSize,
-		MaxRetries:   2,
-		IdleTimeout:  5 * time.Minute,
-		MinIdleConns: 10,
-		WriteTimeout: time.Duration(600) * time.Second,
-		ReadTimeout:  time.Duration(600) * time.Second,
-		DialTimeout:  time.Duration(600) * time.Second,
-	})
-	_, err := client.Ping(context.Background()).Result()
+	c, err := NewClient(config)
 	if err != nil {
 		return err
 	}
+	_, err = c.Ping(context.Background()).Result()
+	if err != nil {
+		c.Close()
+		return err
+	}
+	if err = LoadScripts(context.Background(), c); err != nil {
+		// SCRIPT LOAD may be disabled, scripts are sent with EVAL on NOSCRIPT
+		logger.Warn().Err(err).Msg("error when load scripts")
+	}
+	client = c
 	plock.InitPool(NewPool())
 
 	return nil
 }
 
-func GetClient() *goredislib.Client {
+// NewClient return new redis client of the config's mode.
+func NewClient(config *RedisConnectionConfig) (goredislib.UniversalClient, error) {
+	opts := universalOptions(config)
+
+	switch config.Mode {
+	case "", ModeStandalone:
+		return goredislib.NewClient(opts.Simple()), nil
+	case ModeSentinel:
+		if opts.MasterName == "" {
+			return nil, errors.New("redis: master name is required in sentinel mode")
+		}
+		return goredislib.NewFailoverClient(opts.Failover()), nil
+	case ModeCluster:
+		return goredislib.NewClusterClient(opts.Cluster()), nil
+	}
+
+	return nil, fmt.Errorf("redis: unknown mode %q", config.Mode)
+}
+
+// universalOptions return the client options of the config with defaults.
+func universalOptions(config *RedisConnectionConfig) *goredislib.UniversalOptions {
+	addrs := config.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{config.Addr}
+	}
+
+	return &goredislib.UniversalOptions{
+		Addrs:            addrs,
+		DB:               config.Database,
+		Username:         config.UserName,
+		Password:         config.Password,
+		SentinelUsername: config.SentinelUserName,
+		SentinelPassword: config.SentinelPassword,
+		MasterName:       config.MasterName,
+		PoolSize:         config.PoolSize,
+		TLSConfig:        config.TLSConfig,
+		MaxRetries:       orDefault(config.MaxRetries, 2),
+		MinIdleConns:     orDefault(config.MinIdleConns, 10),
+		IdleTimeout:      orDefault(config.IdleTimeout, 5*time.Minute),
+		PoolTimeout:      config.PoolTimeout,
+		WriteTimeout:     orDefault(config.WriteTimeout, time.Duration(600)*time.Second),
+		ReadTimeout:      orDefault(config.ReadTimeout, time.Duration(600)*time.Second),
+		DialTimeout:      orDefault(config.DialTimeout, time.Duration(600)*time.Second),
+	}
+}
+
+func GetClient() goredislib.UniversalClient {
 	return client
 }
 
+func orDefault[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
+
 // SetObject Set struct to redis
 // EX used: err = util.SetObject(ctx, "key", userModel, 86400)
 func SetObject(ctx context.Context, key string, value interface{}, expirationSecond int) error {
@@ -1,50 +1,49 @@
+//go:build integration
+
 package searedis
 
 import (
 	"context"
+	"os"
 	"testing"
 )
 
-func TestPushList(t *testing.T) {
-	ConnectRedisV1(&RedisConnectionConfig{
-		Addr:     "aaa174573fcfd43d59bcbc58416e9c44-78f0dc04d017e194.elb.ap-southeast-1.amazonaws.com:6379",
-		UserName: "",
-		Password: "kYRvzpvIze9jyE",
-		Database: 12,
-		PoolSize: 100,
+// connectTestRedis connect the redis of REDIS_ADDR and REDIS_PASSWORD,
+// run with: REDIS_ADDR=localhost:6379 go test -tags integration ./redis/
+func connectTestRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	err := ConnectRedisV1(&RedisConnectionConfig{
+		Addr:     addr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		PoolSize: 10,
 	})
-	LPushList(context.Background(), "nft_token_ids", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPushList(t *testing.T) {
+	connectTestRedis(t)
+	if err := LPushList(context.Background(), "nft_token_ids", 1); err != nil {
+		t.Fatal(err)
+	}
 }
+
 func TestLPopList(t *testing.T) {
-	ConnectRedisV1(&RedisConnectionConfig{
-		Addr:     "aaa174573fcfd43d59bcbc58416e9c44-78f0dc04d017e194.elb.ap-southeast-1.amazonaws.com:6379",
-		UserName: "",
-		Password: "kYRvzpvIze9jyE",
-		Database: 12,
-		PoolSize: 100,
-	})
-	LPopList(context.Background(), "redeem_event")
+	connectTestRedis(t)
+	if _, err := LPopList(context.Background(), "nft_token_ids"); err != nil {
+		t.Fatal(err)
+	}
 }
+
 func TestBulkPushList(t *testing.T) {
-	ConnectRedisV1(&RedisConnectionConfig{
-		Addr:     "aaa174573fcfd43d59bcbc58416e9c44-78f0dc04d017e194.elb.ap-southeast-1.amazonaws.com:6379",
-		UserName: "",
-		Password: "kYRvzpvIze9jyE",
-		Database: 12,
-		PoolSize: 100,
-	})
+	connectTestRedis(t)
 	for i := 1005; i < 1015; i++ {
-		LPushList(context.Background(), "nft_token_ids_test", i)
+		if err := LPushList(context.Background(), "nft_token_ids_test", i); err != nil {
+			t.Fatal(err)
+		}
 	}
 }
-
-func TestShuffleList(t *testing.T) {
-	ConnectRedisV1(&RedisConnectionConfig{
-		Addr:     "aaa174573fcfd43d59bcbc58416e9c44-78f0dc04d017e194.elb.ap-southeast-1.amazonaws.com:6379",
-		UserName: "",
-		Password: "kYRvzpvIze9jyE",
-		Database: 12,
-		PoolSize: 100,
-	})
-	ShuffeList(context.Background(), "nft_token_ids_test")
-}
@@ -0,0 +1,86 @@
+package searedis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// Script is a registered lua script, it's loaded by LoadScripts and called
+// with EVALSHA.
+type Script struct {
+	name   string
+	script *goredislib.Script
+}
+
+var errInvalidScriptResult = errors.New("redis: unexpected script result")
+
+var (
+	scriptsMu sync.Mutex
+	scripts   []*Script
+)
+
+// RegisterScript register a script to be loaded by LoadScripts, it's
+// usually called in a package-level var.
+// EX used: var incrScript = searedis.RegisterScript("incr", `return redis.call("INCR", KEYS[1])`)
+func RegisterScript(name, src string) *Script {
+	s := &Script{name: name, script: goredislib.NewScript(src)}
+
+	scriptsMu.Lock()
+	scripts = append(scripts, s)
+	scriptsMu.Unlock()
+	return s
+}
+
+// LoadScripts load every registered script with SCRIPT LOAD, it's called by
+// ConnectRedisV1, which only logs the error. On cluster, scripts are loaded
+// on every master.
+func LoadScripts(ctx context.Context, c goredislib.Scripter) error {
+	scriptsMu.Lock()
+	all := make([]*Script, len(scripts))
+	copy(all, scripts)
+	scriptsMu.Unlock()
+
+	for _, s := range all {
+		if err := s.script.Load(ctx, c).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name return the name of the script.
+func (s *Script) Name() string {
+	return s.name
+}
+
+// Hash return the sha1 of the script.
+func (s *Script) Hash() string {
+	return s.script.Hash()
+}
+
+// Run call the script with EVALSHA, it falls back to EVAL when the script
+// is not in the server cache, which also caches it.
+func (s *Script) Run(ctx context.Context, c goredislib.Scripter, keys []string, args ...interface{}) *goredislib.Cmd {
+	cmd := s.script.EvalSha(ctx, c, keys, args...)
+	if IsNoScript(cmd.Err()) {
+		cmd = s.script.Eval(ctx, c, keys, args...)
+	}
+	return cmd
+}
+
+// RunWithPipe queue the script in the pipeline, so it can run inside
+// TransactionWithCtx. It's always sent with EVAL: the result is only known
+// once the pipeline is executed, and inside MULTI a NOSCRIPT error would
+// fail the script while the other commands are applied.
+func (s *Script) RunWithPipe(ctx context.Context, pipe goredislib.Pipeliner, keys []string, args ...interface{}) *goredislib.Cmd {
+	return s.script.Eval(ctx, pipe, keys, args...)
+}
+
+// IsNoScript report whether the error is a NOSCRIPT error.
+func IsNoScript(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT ")
+}
@@ -0,0 +1,163 @@
+package searedis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var testScript = RegisterScript("test", `return redis.call("INCR", KEYS[1])`)
+
+// useClient replace the default client for the test.
+func useClient(t *testing.T, c goredislib.UniversalClient) {
+	old := client
+	client = c
+	t.Cleanup(func() { client = old })
+}
+
+func TestIsNoScript(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{goredislib.Nil, false},
+		{errors.New("NOSCRIPT No matching script. Please use EVAL."), true},
+		{errors.New("ERR NOSCRIPT"), false},
+		{errors.New("BUSY Redis is busy running a script"), false},
+	}
+	for _, tt := range tests {
+		if got := IsNoScript(tt.err); got != tt.want {
+			t.Errorf("IsNoScript(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestScriptRunReload(t *testing.T) {
+	f, c := redistest.NewServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "EVALSHA":
+			return errors.New("NOSCRIPT No matching script. Please use EVAL.")
+		case "EVAL":
+			return int64(1)
+		}
+		return errors.New("ERR unknown command")
+	})
+
+	n, err := testScript.Run(context.Background(), c, []string{"counter"}).Int()
+	if err != nil || n != 1 {
+		t.Fatalf("unexpected result %d, %v", n, err)
+	}
+	if cmds := f.Commands(); !reflect.DeepEqual(cmds, []string{"EVALSHA", "EVAL"}) {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+}
+
+func TestScriptRunWithPipe(t *testing.T) {
+	f, c := redistest.NewServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "EVALSHA":
+			return errors.New("NOSCRIPT No matching script. Please use EVAL.")
+		case "EVAL":
+			return int64(1)
+		case "SET", "UNWATCH":
+			return "OK"
+		}
+		return errors.New("ERR unknown command")
+	})
+
+	var cmd *goredislib.Cmd
+	err := TransactionWithClient(context.Background(), c, func(pipe goredislib.Pipeliner) error {
+		pipe.Set(context.Background(), "key", "value", 0)
+		cmd = testScript.RunWithPipe(context.Background(), pipe, []string{"counter"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := cmd.Int(); err != nil || n != 1 {
+		t.Fatalf("unexpected result %d, %v", n, err)
+	}
+	for _, name := range f.Commands() {
+		if name == "EVALSHA" {
+			t.Fatal("EVALSHA should not be queued in a transaction")
+		}
+	}
+}
+
+func TestConnectWithoutScriptLoad(t *testing.T) {
+	_, c := redistest.NewServer(t, func(args []string) interface{} {
+		if strings.ToUpper(args[0]) == "SCRIPT" {
+			return errors.New("NOPERM this user has no permissions to run the 'script' command")
+		}
+		return errors.New("ERR unknown command")
+	})
+	useClient(t, nil)
+
+	err := ConnectRedisV1(&RedisConnectionConfig{Addr: c.(*goredislib.Client).Options().Addr})
+	if err != nil {
+		t.Fatalf("expected to connect without SCRIPT LOAD, got %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if client == nil {
+		t.Fatal("expected the client set")
+	}
+}
+
+func TestCompareAndSet(t *testing.T) {
+	var got []string
+	_, c := redistest.NewServer(t, func(args []string) interface{} {
+		got = args
+		return int64(1)
+	})
+	useClient(t, c)
+
+	ok, err := CompareAndSet(context.Background(), "key", nil, map[string]int{"v": 1}, 2)
+	if err != nil || !ok {
+		t.Fatalf("unexpected result %v, %v", ok, err)
+	}
+	// EVALSHA sha 1 key missing expected value ttl
+	if got[3] != "key" || got[4] != "1" || got[5] != "" || got[6] != `{"v":1}` || got[7] != "2000" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+
+	ok, err = CompareAndSet(context.Background(), "key", map[string]int{"v": 1}, map[string]int{"v": 2}, 0)
+	if err != nil || !ok {
+		t.Fatalf("unexpected result %v, %v", ok, err)
+	}
+	if got[4] != "0" || got[5] != `{"v":1}` || got[7] != "0" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+}
+
+func TestIncrCapped(t *testing.T) {
+	var reply interface{}
+	_, c := redistest.NewServer(t, func(args []string) interface{} {
+		return reply
+	})
+	useClient(t, c)
+
+	tests := []struct {
+		reply     interface{}
+		value     int64
+		increased bool
+		err       error
+	}{
+		{[]interface{}{int64(3), int64(1)}, 3, true, nil},
+		{[]interface{}{int64(10), int64(0)}, 10, false, nil},
+		{[]interface{}{int64(3)}, 0, false, errInvalidScriptResult},
+	}
+	for _, tt := range tests {
+		reply = tt.reply
+		value, increased, err := IncrCapped(context.Background(), "counter", 1, 10, 60)
+		if value != tt.value || increased != tt.increased || err != tt.err {
+			t.Errorf("reply %v: got %d, %v, %v", tt.reply, value, increased, err)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package seasession
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ponlv/go-kit/jwt"
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrInvalidToken is returned by Refresh for unknown, expired or reused refresh tokens.
+	ErrInvalidToken = errors.New("session: invalid refresh token")
+	// ErrNotFound is returned when the session does not exist.
+	ErrNotFound = errors.New("session: not found")
+)
+
+const (
+	defaultPrefix     = "session"
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 30 * 24 * time.Hour
+	// maxUsedHashes is how many rotated refresh token hashes are kept to
+	// detect their reuse.
+	maxUsedHashes = 16
+)
+
+// rotateScript replace the refresh token hash and the access token if the
+// hash matches and keep the old hash in the used hashes. It returns 0 if
+// the hash is a used one, nil if it's unknown.
+// KEYS[1]: session, ARGV: old hash, new hash, token id, token expires at,
+// refreshed at, expires at, ttl (ms), max used hashes
+var rotateScript = searedis.RegisterScript("session:rotate", `
+local used = {}
+for h in string.gmatch(redis.call("HGET", KEYS[1], "usedHashes") or "", "[^,]+") do
+  table.insert(used, h)
+end
+
+if redis.call("HGET", KEYS[1], "refreshHash") ~= ARGV[1] then
+  for _, h in ipairs(used) do
+    if h == ARGV[1] then
+      return 0
+    end
+  end
+  return nil
+end
+
+table.insert(used, ARGV[1])
+while #used > tonumber(ARGV[8]) do
+  table.remove(used, 1)
+end
+redis.call("HSET", KEYS[1], "refreshHash", ARGV[2], "usedHashes", table.concat(used, ","), "tokenId", ARGV[3], "tokenExpiresAt", ARGV[4], "refreshedAt", ARGV[5], "expiresAt", ARGV[6])
+redis.call("PEXPIRE", KEYS[1], ARGV[7])
+return 1
+`)
+
+// Options struct contain options of Store.
+type Options struct {
+	// Prefix of the keys, default to "session".
+	Prefix string
+	// SigningKey and Issuer are passed to jwt.GenerateJWTTokenWithID.
+	SigningKey string
+	Issuer     string
+	// AccessTTL is default to 15 minutes.
+	AccessTTL time.Duration
+	// RefreshTTL is how long a session lives without refresh, default to 30 days.
+	RefreshTTL time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Session is a login of a user on a device.
+type Session struct {
+	ID       string
+	UserID   string
+	DeviceID string
+	Email    string
+	Metadata string
+	// TokenID is the jti of the current access token.
+	TokenID        string
+	TokenExpiresAt time.Time
+	CreatedAt      time.Time
+	RefreshedAt    time.Time
+	ExpiresAt      time.Time
+}
+
+// Tokens is the result of Create and Refresh.
+type Tokens struct {
+	AccessToken string
+	// RefreshToken is only returned once, the store keeps its hash.
+	RefreshToken string
+	Session      *Session
+}
+
+// Store keep sessions and revoked token ids in redis. Call
+// jwt.SetDenylist(store) so jwt.VerifyJWTToken and the grpc
+// authentication reject revoked access tokens.
+type Store struct {
+	opts Options
+}
+
+// NewStore return new session store.
+func NewStore(opts *Options) *Store {
+	s := &Store{}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Prefix == "" {
+		s.opts.Prefix = defaultPrefix
+	}
+	if s.opts.AccessTTL <= 0 {
+		s.opts.AccessTTL = defaultAccessTTL
+	}
+	if s.opts.RefreshTTL <= 0 {
+		s.opts.RefreshTTL = defaultRefreshTTL
+	}
+	return s
+}
+
+// Create start a session of the user on the device, the previous session
+// of the same device is revoked.
+func (s *Store) Create(ctx context.Context, userID, email, metadata, deviceID string) (*Tokens, error) {
+	if deviceID != "" {
+		sessions, err := s.List(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, old := range sessions {
+			if old.DeviceID == deviceID {
+				if err = s.revoke(ctx, old); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:          randomHex(16),
+		UserID:      userID,
+		DeviceID:    deviceID,
+		Email:       email,
+		Metadata:    metadata,
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(s.opts.RefreshTTL),
+	}
+	access, err := s.accessToken(session, now)
+	if err != nil {
+		return nil, err
+	}
+	secret := randomHex(32)
+
+	fields := toHash(session)
+	fields["refreshHash"] = hashSecret(secret)
+
+	pipe := s.client().Pipeline()
+	pipe.HSet(ctx, s.sessionKey(session.ID), fields)
+	pipe.PExpire(ctx, s.sessionKey(session.ID), s.opts.RefreshTTL)
+	pipe.ZAdd(ctx, s.userKey(userID), &goredislib.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.ID})
+	pipe.PExpire(ctx, s.userKey(userID), s.opts.RefreshTTL)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: access, RefreshToken: session.ID + "." + secret, Session: session}, nil
+}
+
+// Refresh rotate the refresh token and issue a new access token, the
+// previous access token is revoked. A refresh token used again after its
+// rotation revokes the session, as it was likely stolen. Unknown tokens
+// only fail with ErrInvalidToken, so the session id alone cannot log the
+// user out.
+func (s *Store) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	parts := strings.SplitN(refreshToken, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.Get(ctx, parts[0])
+	if err == ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	previous := *session
+	session.RefreshedAt = now
+	session.ExpiresAt = now.Add(s.opts.RefreshTTL)
+	access, err := s.accessToken(session, now)
+	if err != nil {
+		return nil, err
+	}
+	secret := randomHex(32)
+
+	rotated, err := rotateScript.Run(ctx, s.client(), []string{s.sessionKey(session.ID)},
+		hashSecret(parts[1]), hashSecret(secret), session.TokenID, unix(session.TokenExpiresAt),
+		unix(session.RefreshedAt), unix(session.ExpiresAt), s.opts.RefreshTTL.Milliseconds(), maxUsedHashes).Int()
+	if err == goredislib.Nil {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rotated == 0 {
+		// the token was already rotated
+		_ = s.Revoke(ctx, session.ID)
+		return nil, ErrInvalidToken
+	}
+
+	pipe := s.client().Pipeline()
+	s.deny(ctx, pipe, previous.TokenID, previous.TokenExpiresAt)
+	pipe.ZAdd(ctx, s.userKey(session.UserID), &goredislib.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.ID})
+	pipe.PExpire(ctx, s.userKey(session.UserID), s.opts.RefreshTTL)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: access, RefreshToken: session.ID + "." + secret, Session: session}, nil
+}
+
+// Get return the session by id.
+func (s *Store) Get(ctx context.Context, sessionID string) (*Session, error) {
+	fields, err := s.client().HGetAll(ctx, s.sessionKey(sessionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrNotFound
+	}
+	return fromHash(fields), nil
+}
+
+// List return active sessions of the user.
+func (s *Store) List(ctx context.Context, userID string) ([]*Session, error) {
+	key := s.userKey(userID)
+	if err := s.client().ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().Unix(), 10)).Err(); err != nil {
+		return nil, err
+	}
+	ids, err := s.client().ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client().Pipeline()
+	cmds := make([]*goredislib.StringStringMapCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, s.sessionKey(id))
+	}
+	if len(ids) > 0 {
+		if _, err = pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	for _, cmd := range cmds {
+		if fields := cmd.Val(); len(fields) > 0 {
+			sessions = append(sessions, fromHash(fields))
+		}
+	}
+	return sessions, nil
+}
+
+// Revoke end the session and revoke its access token.
+func (s *Store) Revoke(ctx context.Context, sessionID string) error {
+	session, err := s.Get(ctx, sessionID)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.revoke(ctx, session)
+}
+
+// RevokeAll end every session of the user.
+func (s *Store) RevokeAll(ctx context.Context, userID string) error {
+	sessions, err := s.List(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err = s.revoke(ctx, session); err != nil {
+			return err
+		}
+	}
+	return s.client().Del(ctx, s.userKey(userID)).Err()
+}
+
+// Deny revoke the token id until it expires.
+func (s *Store) Deny(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	pipe := s.client().Pipeline()
+	s.deny(ctx, pipe, tokenID, expiresAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsRevoked report whether the token id was revoked, it implements jwt.Denylist.
+func (s *Store) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := s.client().Exists(ctx, s.denyKey(tokenID)).Result()
+	return n > 0, err
+}
+
+func (s *Store) revoke(ctx context.Context, session *Session) error {
+	pipe := s.client().Pipeline()
+	pipe.Del(ctx, s.sessionKey(session.ID))
+	pipe.ZRem(ctx, s.userKey(session.UserID), session.ID)
+	s.deny(ctx, pipe, session.TokenID, session.TokenExpiresAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *Store) deny(ctx context.Context, pipe goredislib.Pipeliner, tokenID string, expiresAt time.Time) {
+	ttl := time.Until(expiresAt)
+	if tokenID == "" || ttl <= 0 {
+		return
+	}
+	pipe.Set(ctx, s.denyKey(tokenID), 1, ttl)
+}
+
+// accessToken issue a new access token of the session and set its id.
+func (s *Store) accessToken(session *Session, now time.Time) (string, error) {
+	session.TokenID = randomHex(16)
+	session.TokenExpiresAt = now.Add(s.opts.AccessTTL)
+	return jwt.GenerateJWTTokenWithID(s.opts.SigningKey, session.UserID, session.Email, session.Metadata,
+		s.opts.Issuer, session.TokenID, int(s.opts.AccessTTL.Seconds()))
+}
+
+func (s *Store) client() goredislib.UniversalClient {
+	if s.opts.Client != nil {
+		return s.opts.Client
+	}
+	return searedis.GetClient()
+}
+
+func (s *Store) sessionKey(id string) string   { return s.opts.Prefix + ":s:" + id }
+func (s *Store) userKey(userID string) string  { return s.opts.Prefix + ":u:" + userID }
+func (s *Store) denyKey(tokenID string) string { return s.opts.Prefix + ":deny:" + tokenID }
+
+func toHash(session *Session) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             session.ID,
+		"userId":         session.UserID,
+		"deviceId":       session.DeviceID,
+		"email":          session.Email,
+		"metadata":       session.Metadata,
+		"tokenId":        session.TokenID,
+		"tokenExpiresAt": unix(session.TokenExpiresAt),
+		"createdAt":      unix(session.CreatedAt),
+		"refreshedAt":    unix(session.RefreshedAt),
+		"expiresAt":      unix(session.ExpiresAt),
+	}
+}
+
+func fromHash(fields map[string]string) *Session {
+	return &Session{
+		ID:             fields["id"],
+		UserID:         fields["userId"],
+		DeviceID:       fields["deviceId"],
+		Email:          fields["email"],
+		Metadata:       fields["metadata"],
+		TokenID:        fields["tokenId"],
+		TokenExpiresAt: parseUnix(fields["tokenExpiresAt"]),
+		CreatedAt:      parseUnix(fields["createdAt"]),
+		RefreshedAt:    parseUnix(fields["refreshedAt"]),
+		ExpiresAt:      parseUnix(fields["expiresAt"]),
+	}
+}
+
+func unix(t time.Time) int64 {
+	return t.Unix()
+}
+
+func parseUnix(s string) time.Time {
+	sec, _ := strconv.ParseInt(s, 10, 64)
+	return time.Unix(sec, 0)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,119 @@
+package seasession
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+)
+
+func TestHashRoundTrip(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	session := &Session{
+		ID:             "s1",
+		UserID:         "42",
+		DeviceID:       "iphone",
+		TokenID:        "jti",
+		TokenExpiresAt: now.Add(time.Minute),
+		CreatedAt:      now,
+		RefreshedAt:    now,
+		ExpiresAt:      now.Add(time.Hour),
+	}
+
+	fields := make(map[string]string)
+	for k, v := range toHash(session) {
+		switch v := v.(type) {
+		case string:
+			fields[k] = v
+		case int64:
+			fields[k] = strconv.FormatInt(v, 10)
+		}
+	}
+
+	got := fromHash(fields)
+	if *got != *session {
+		t.Fatalf("expected %+v, got %+v", session, got)
+	}
+}
+
+func TestHashSecret(t *testing.T) {
+	if hashSecret("a") == "a" || hashSecret("a") != hashSecret("a") {
+		t.Fatal("unexpected secret hash")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{SigningKey: "secret", Client: client})
+	ctx := context.Background()
+
+	created, err := s.Create(ctx, "42", "a@b.c", "", "iphone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate
+	refreshed, err := s.Refresh(ctx, created.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RefreshToken == created.RefreshToken || refreshed.Session.TokenID == created.Session.TokenID {
+		t.Fatal("expected new tokens")
+	}
+	if revoked, _ := s.IsRevoked(ctx, created.Session.TokenID); !revoked {
+		t.Fatal("expected the previous access token revoked")
+	}
+
+	// a random secret does not revoke the session
+	sessionID := created.Session.ID
+	if _, err = s.Refresh(ctx, sessionID+"."+randomHex(32)); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if _, err = s.Refresh(ctx, "garbage"); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if _, err = s.Get(ctx, sessionID); err != nil {
+		t.Fatalf("expected the session kept, got %v", err)
+	}
+	current, err := s.Refresh(ctx, refreshed.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reuse of a rotated secret revokes the session
+	if _, err = s.Refresh(ctx, created.RefreshToken); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if _, err = s.Get(ctx, sessionID); err != ErrNotFound {
+		t.Fatalf("expected the session revoked, got %v", err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, current.Session.TokenID); !revoked {
+		t.Fatal("expected the current access token revoked")
+	}
+	if _, err = s.Refresh(ctx, current.RefreshToken); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken after revoke, got %v", err)
+	}
+}
+
+func TestRefreshKeepsUsedHashes(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	s := NewStore(&Options{SigningKey: "secret", Client: client})
+	ctx := context.Background()
+
+	tokens, err := s.Create(ctx, "42", "a@b.c", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxUsedHashes+2; i++ {
+		if tokens, err = s.Refresh(ctx, tokens.RefreshToken); err != nil {
+			t.Fatal(err)
+		}
+	}
+	used := client.HGet(ctx, s.sessionKey(tokens.Session.ID), "usedHashes").Val()
+	if n := len(strings.Split(used, ",")); n != maxUsedHashes {
+		t.Fatalf("expected %d used hashes, got %d", maxUsedHashes, n)
+	}
+}
@@ -0,0 +1,111 @@
+package seasketch
+
+import (
+	"context"
+	"math"
+	"time"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// maxBits is the max size of a redis string in bits (512MB).
+const maxBits = 1 << 32
+
+// Bloom is a bloom filter over a redis bitmap, it uses plain SETBIT and
+// GETBIT so it works without RedisBloom.
+type Bloom struct {
+	key    string
+	bits   uint64
+	hashes int
+	ttl    time.Duration
+	client goredislib.UniversalClient
+}
+
+// BloomOptions struct contain options of Bloom.
+type BloomOptions struct {
+	// TTL of the filter, renewed on every add, 0 means no expiration.
+	TTL time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// NewBloom return new bloom filter sized for capacity items with the false
+// positive rate, e.g. 0.01 for 1%.
+func NewBloom(key string, capacity uint64, falsePositiveRate float64, opts *BloomOptions) *Bloom {
+	bits, hashes := bloomSize(capacity, falsePositiveRate)
+	b := &Bloom{key: key, bits: bits, hashes: hashes}
+	if opts != nil {
+		b.ttl = opts.TTL
+		b.client = opts.Client
+	}
+	return b
+}
+
+// bloomSize return the number of bits and hashes of a filter.
+func bloomSize(capacity uint64, falsePositiveRate float64) (uint64, int) {
+	if capacity == 0 {
+		capacity = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	n := float64(capacity)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Max(1, math.Round(m/n*math.Ln2)))
+	if m > maxBits {
+		m = maxBits
+	}
+	return uint64(m), k
+}
+
+// Add add the item and report whether it was not in the filter yet.
+func (b *Bloom) Add(ctx context.Context, item string) (bool, error) {
+	pipe := b.redis().Pipeline()
+	res := b.AddWithPipe(ctx, pipe, item)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return res.Val()
+}
+
+// AddWithPipe queue the add in the pipeline, the result report whether the
+// item was not in the filter yet.
+func (b *Bloom) AddWithPipe(ctx context.Context, pipe goredislib.Pipeliner, item string) *BoolResult {
+	res := &BoolResult{}
+	for _, pos := range locations(item, b.hashes, b.bits) {
+		res.cmds = append(res.cmds, pipe.SetBit(ctx, b.key, int64(pos), 1))
+	}
+	if b.ttl > 0 {
+		pipe.PExpire(ctx, b.key, b.ttl)
+	}
+	return res
+}
+
+// Exists report whether the item may be in the filter, false is certain.
+func (b *Bloom) Exists(ctx context.Context, item string) (bool, error) {
+	pipe := b.redis().Pipeline()
+	res := b.ExistsWithPipe(ctx, pipe, item)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return res.Val()
+}
+
+// ExistsWithPipe queue the check in the pipeline.
+func (b *Bloom) ExistsWithPipe(ctx context.Context, pipe goredislib.Pipeliner, item string) *BoolResult {
+	res := &BoolResult{all: true}
+	for _, pos := range locations(item, b.hashes, b.bits) {
+		res.cmds = append(res.cmds, pipe.GetBit(ctx, b.key, int64(pos)))
+	}
+	return res
+}
+
+// Clear delete the filter.
+func (b *Bloom) Clear(ctx context.Context) error {
+	return b.redis().Del(ctx, b.key).Err()
+}
+
+func (b *Bloom) redis() goredislib.UniversalClient {
+	return redisClient(b.client)
+}
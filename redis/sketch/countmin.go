@@ -0,0 +1,140 @@
+package seasketch
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// CountMin is a Count-Min sketch over a redis hash, it estimates item
+// frequencies in constant memory and keeps the top items in a sorted set.
+// Estimates are never lower than the real count.
+type CountMin struct {
+	key   string
+	width uint64
+	depth int
+	opts  CountMinOptions
+}
+
+// CountMinOptions struct contain options of CountMin.
+type CountMinOptions struct {
+	// TopK is the number of heavy hitters kept, 0 means disabled.
+	TopK int64
+	// TTL of the sketch, renewed on every increment, 0 means no expiration.
+	TTL time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// NewCountMin return new sketch whose estimates are within epsilon times
+// the total count of the real count with probability 1-delta,
+// e.g. 0.001 and 0.01.
+func NewCountMin(key string, epsilon, delta float64, opts *CountMinOptions) *CountMin {
+	width, depth := countMinSize(epsilon, delta)
+	s := &CountMin{key: key, width: width, depth: depth}
+	if opts != nil {
+		s.opts = *opts
+	}
+	return s
+}
+
+func countMinSize(epsilon, delta float64) (uint64, int) {
+	if epsilon <= 0 || epsilon >= 1 {
+		epsilon = 0.001
+	}
+	if delta <= 0 || delta >= 1 {
+		delta = 0.01
+	}
+	return uint64(math.Ceil(math.E / epsilon)), int(math.Ceil(math.Log(1 / delta)))
+}
+
+// Incr increase the count of the item and return its estimate, the heavy
+// hitters are updated with it.
+func (s *CountMin) Incr(ctx context.Context, item string, n int64) (int64, error) {
+	pipe := s.redis().Pipeline()
+	res := s.IncrWithPipe(ctx, pipe, item, n)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	estimate, err := res.Val()
+	if err != nil || s.opts.TopK <= 0 {
+		return estimate, err
+	}
+
+	pipe = s.redis().Pipeline()
+	pipe.ZAdd(ctx, s.topKey(), &goredislib.Z{Score: float64(estimate), Member: item})
+	pipe.ZRemRangeByRank(ctx, s.topKey(), 0, -s.opts.TopK-1)
+	if s.opts.TTL > 0 {
+		pipe.PExpire(ctx, s.topKey(), s.opts.TTL)
+	}
+	_, err = pipe.Exec(ctx)
+	return estimate, err
+}
+
+// IncrWithPipe queue the increment in the pipeline, the result is the
+// estimate after the increment. The heavy hitters are not updated.
+func (s *CountMin) IncrWithPipe(ctx context.Context, pipe goredislib.Pipeliner, item string, n int64) *IntResult {
+	res := &IntResult{}
+	for _, field := range s.fields(item) {
+		res.values = append(res.values, pipe.HIncrBy(ctx, s.key, field, n).Result)
+	}
+	if s.opts.TTL > 0 {
+		pipe.PExpire(ctx, s.key, s.opts.TTL)
+	}
+	return res
+}
+
+// Estimate return the estimated count of the item.
+func (s *CountMin) Estimate(ctx context.Context, item string) (int64, error) {
+	pipe := s.redis().Pipeline()
+	res := s.EstimateWithPipe(ctx, pipe, item)
+	if _, err := pipe.Exec(ctx); err != nil && err != goredislib.Nil {
+		return 0, err
+	}
+	return res.Val()
+}
+
+// EstimateWithPipe queue the estimate in the pipeline.
+func (s *CountMin) EstimateWithPipe(ctx context.Context, pipe goredislib.Pipeliner, item string) *IntResult {
+	res := &IntResult{}
+	for _, field := range s.fields(item) {
+		res.values = append(res.values, pipe.HGet(ctx, s.key, field).Int64)
+	}
+	return res
+}
+
+// Top return the heavy hitters with their estimates, highest first.
+func (s *CountMin) Top(ctx context.Context) ([]goredislib.Z, error) {
+	return s.redis().ZRevRangeWithScores(ctx, s.topKey(), 0, s.opts.TopK-1).Result()
+}
+
+// Clear delete the sketch and the heavy hitters.
+func (s *CountMin) Clear(ctx context.Context) error {
+	pipe := s.redis().Pipeline()
+	pipe.Del(ctx, s.key)
+	pipe.Del(ctx, s.topKey())
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// fields return the hash field of the item in every row.
+func (s *CountMin) fields(item string) []string {
+	cols := locations(item, s.depth, s.width)
+	fields := make([]string, s.depth)
+	for row, col := range cols {
+		fields[row] = strconv.Itoa(row) + ":" + strconv.FormatUint(col, 10)
+	}
+	return fields
+}
+
+func (s *CountMin) topKey() string {
+	return s.key + ":top"
+}
+
+func (s *CountMin) redis() goredislib.UniversalClient {
+	return redisClient(s.opts.Client)
+}
@@ -0,0 +1,114 @@
+package seasketch
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// Counter count unique items per time bucket with HyperLogLog, counts over
+// a time range merge the buckets.
+type Counter struct {
+	name   string
+	opts   CounterOptions
+	bucket time.Duration
+}
+
+// CounterOptions struct contain options of Counter.
+type CounterOptions struct {
+	// Bucket is the time resolution, default to 1 hour.
+	Bucket time.Duration
+	// Retention is how long a bucket is kept, default to 30 days.
+	Retention time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// NewCounter return new unique counter.
+func NewCounter(name string, opts *CounterOptions) *Counter {
+	c := &Counter{name: name}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Bucket <= 0 {
+		c.opts.Bucket = time.Hour
+	}
+	if c.opts.Retention <= 0 {
+		c.opts.Retention = 30 * 24 * time.Hour
+	}
+	return c
+}
+
+// Add count the items in the current bucket.
+func (c *Counter) Add(ctx context.Context, items ...string) error {
+	pipe := c.redis().Pipeline()
+	c.AddWithPipe(ctx, pipe, time.Now(), items...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddWithPipe queue counting the items in the bucket of t in the pipeline.
+func (c *Counter) AddWithPipe(ctx context.Context, pipe goredislib.Pipeliner, t time.Time, items ...string) {
+	if len(items) == 0 {
+		return
+	}
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		values[i] = item
+	}
+
+	key := c.key(t)
+	pipe.PFAdd(ctx, key, values...)
+	pipe.PExpireAt(ctx, key, c.bucketStart(t).Add(c.opts.Bucket+c.opts.Retention))
+}
+
+// Count return the number of unique items between from and to, both included.
+func (c *Counter) Count(ctx context.Context, from, to time.Time) (int64, error) {
+	keys := c.keys(from, to)
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return c.redis().PFCount(ctx, keys...).Result()
+}
+
+// Merge store the union of the buckets between from and to in dest, e.g.
+// to keep daily uniques after hourly buckets expired. On cluster, dest
+// must share the "{name}" hash tag of the buckets.
+func (c *Counter) Merge(ctx context.Context, dest string, from, to time.Time, ttl time.Duration) error {
+	keys := c.keys(from, to)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := c.redis().TxPipeline()
+	pipe.PFMerge(ctx, dest, keys...)
+	if ttl > 0 {
+		pipe.PExpire(ctx, dest, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *Counter) keys(from, to time.Time) []string {
+	keys := make([]string, 0)
+	for t := c.bucketStart(from); !t.After(to); t = t.Add(c.opts.Bucket) {
+		keys = append(keys, c.key(t))
+	}
+	return keys
+}
+
+func (c *Counter) bucketStart(t time.Time) time.Time {
+	return t.Truncate(c.opts.Bucket)
+}
+
+// key return the bucket key of t, keys share the "{name}" hash tag so
+// they can be counted together on cluster.
+func (c *Counter) key(t time.Time) string {
+	return "hll:{" + c.name + "}:" + strconv.FormatInt(c.bucketStart(t).Unix(), 10)
+}
+
+func (c *Counter) redis() goredislib.UniversalClient {
+	return redisClient(c.opts.Client)
+}
@@ -0,0 +1,221 @@
+package seasketch
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultGrowth    = 2
+	tighteningRatio  = 0.5
+	maxScalingLayers = 32
+)
+
+// scalableAddScript check every layer and add the item to the last one, it
+// returns 1 if added, 0 if it exists and -1 if the layers changed since
+// they were read. The layer that fills the last one adds the next layer.
+// The ttl is renewed on every layer, so the older ones live as long as the
+// filter.
+// KEYS: meta hash, layers
+// ARGV: capacity of the last layer, max layers, ttl (ms), then for each
+// layer the number of positions followed by the positions
+var scalableAddScript = searedis.RegisterScript("sketch:scalable_add", `
+local layers = tonumber(redis.call("HGET", KEYS[1], "layers") or "0") + 1
+if layers ~= #KEYS - 1 then
+  return -1
+end
+
+local arg = 4
+local positions
+for i = 2, #KEYS do
+  local k = tonumber(ARGV[arg])
+  local found = true
+  positions = {}
+  for j = 1, k do
+    positions[j] = ARGV[arg + j]
+    if found and redis.call("GETBIT", KEYS[i], positions[j]) == 0 then
+      found = false
+    end
+  end
+  if found then
+    return 0
+  end
+  arg = arg + k + 1
+end
+
+for _, pos in ipairs(positions) do
+  redis.call("SETBIT", KEYS[#KEYS], pos, 1)
+end
+local count = redis.call("HINCRBY", KEYS[1], "count:" .. (layers - 1), 1)
+if count == tonumber(ARGV[1]) and layers < tonumber(ARGV[2]) then
+  redis.call("HINCRBY", KEYS[1], "layers", 1)
+end
+if tonumber(ARGV[3]) > 0 then
+  for _, key in ipairs(KEYS) do
+    redis.call("PEXPIRE", key, ARGV[3])
+  end
+end
+return 1
+`)
+
+// ScalableBloom is a bloom filter that adds a bigger layer when the
+// current one is full, so the capacity does not need to be known upfront.
+// Each layer has a tighter false positive rate so the overall rate stays
+// under the configured one. Adds run in a script, on cluster the key must
+// have a hash tag such as "{visitors}" so every layer is on the same slot.
+type ScalableBloom struct {
+	key      string
+	capacity uint64
+	rate     float64
+	growth   uint64
+	ttl      time.Duration
+	client   goredislib.UniversalClient
+}
+
+// ScalableBloomOptions struct contain options of ScalableBloom.
+type ScalableBloomOptions struct {
+	// Growth is the capacity multiplier of each new layer, default to 2.
+	Growth uint64
+	// TTL of the filter, renewed on every add, 0 means no expiration.
+	TTL time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// NewScalableBloom return new scalable bloom filter, capacity is the size
+// of the first layer.
+func NewScalableBloom(key string, capacity uint64, falsePositiveRate float64, opts *ScalableBloomOptions) *ScalableBloom {
+	b := &ScalableBloom{key: key, capacity: capacity, rate: falsePositiveRate, growth: defaultGrowth}
+	if opts != nil {
+		if opts.Growth > 1 {
+			b.growth = opts.Growth
+		}
+		b.ttl = opts.TTL
+		b.client = opts.Client
+	}
+	if b.capacity == 0 {
+		b.capacity = 1
+	}
+	return b
+}
+
+// Add add the item and report whether it was not in the filter yet.
+func (b *ScalableBloom) Add(ctx context.Context, item string) (bool, error) {
+	for {
+		layers, err := b.layers(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		keys, args := b.addArgs(layers, item)
+		n, err := scalableAddScript.Run(ctx, b.redis(), keys, args...).Int()
+		if err != nil {
+			return false, err
+		}
+		// otherwise a layer was added meanwhile, retry with the new layers
+		if n >= 0 {
+			return n == 1, nil
+		}
+	}
+}
+
+// addArgs return the keys and args of scalableAddScript.
+func (b *ScalableBloom) addArgs(layers []*Bloom, item string) ([]string, []interface{}) {
+	keys := []string{b.metaKey()}
+	args := []interface{}{b.layerCapacity(len(layers) - 1), maxScalingLayers, b.ttl.Milliseconds()}
+	for _, layer := range layers {
+		keys = append(keys, layer.key)
+		positions := locations(item, layer.hashes, layer.bits)
+		args = append(args, len(positions))
+		for _, pos := range positions {
+			args = append(args, pos)
+		}
+	}
+	return keys, args
+}
+
+// Exists report whether the item may be in the filter, false is certain.
+func (b *ScalableBloom) Exists(ctx context.Context, item string) (bool, error) {
+	layers, err := b.layers(ctx)
+	if err != nil {
+		return false, err
+	}
+	return b.exists(ctx, layers, item)
+}
+
+// Clear delete every layer of the filter.
+func (b *ScalableBloom) Clear(ctx context.Context) error {
+	layers, err := b.layers(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{b.metaKey()}
+	for _, layer := range layers {
+		keys = append(keys, layer.key)
+	}
+	pipe := b.redis().Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (b *ScalableBloom) exists(ctx context.Context, layers []*Bloom, item string) (bool, error) {
+	pipe := b.redis().Pipeline()
+	results := make([]*BoolResult, len(layers))
+	for i, layer := range layers {
+		results[i] = layer.ExistsWithPipe(ctx, pipe, item)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	for _, res := range results {
+		if ok, err := res.Val(); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// layers return the bloom filters of every layer.
+func (b *ScalableBloom) layers(ctx context.Context) ([]*Bloom, error) {
+	n, err := b.redis().HGet(ctx, b.metaKey(), "layers").Int()
+	if err != nil && err != goredislib.Nil {
+		return nil, err
+	}
+	// the meta hash only stores the layers added after the first one
+	n++
+
+	layers := make([]*Bloom, n)
+	for i := range layers {
+		rate := b.rate * (1 - tighteningRatio) * math.Pow(tighteningRatio, float64(i))
+		layers[i] = NewBloom(b.key+":"+strconv.Itoa(i), b.layerCapacity(i), rate,
+			&BloomOptions{TTL: b.ttl, Client: b.client})
+	}
+	return layers, nil
+}
+
+func (b *ScalableBloom) layerCapacity(i int) uint64 {
+	capacity := b.capacity
+	for ; i > 0; i-- {
+		capacity *= b.growth
+	}
+	return capacity
+}
+
+func (b *ScalableBloom) metaKey() string {
+	return b.key + ":meta"
+}
+
+func (b *ScalableBloom) redis() goredislib.UniversalClient {
+	return redisClient(b.client)
+}
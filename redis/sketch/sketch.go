@@ -0,0 +1,83 @@
+package seasketch
+
+import (
+	"hash/fnv"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// BoolResult is the result of a pipelined operation, it's valid after the
+// pipeline is executed.
+type BoolResult struct {
+	cmds []*goredislib.IntCmd
+	// all report whether every bit must be set, otherwise whether any bit was unset
+	all bool
+}
+
+// Val return the result, see the operation that created it.
+func (r *BoolResult) Val() (bool, error) {
+	for _, cmd := range r.cmds {
+		bit, err := cmd.Result()
+		if err != nil {
+			return false, err
+		}
+		if r.all && bit == 0 {
+			return false, nil
+		}
+		if !r.all && bit == 0 {
+			return true, nil
+		}
+	}
+	return r.all, nil
+}
+
+// IntResult is the result of a pipelined count, it's valid after the
+// pipeline is executed.
+type IntResult struct {
+	values []func() (int64, error)
+}
+
+// Val return the smallest value of the commands, missing values count as 0.
+func (r *IntResult) Val() (int64, error) {
+	var min int64 = -1
+	for _, value := range r.values {
+		v, err := value()
+		if err != nil && err != goredislib.Nil {
+			return 0, err
+		}
+		if min < 0 || v < min {
+			min = v
+		}
+	}
+	if min < 0 {
+		return 0, nil
+	}
+	return min, nil
+}
+
+// locations return k positions in [0, m) of the item with double hashing.
+func locations(item string, k int, m uint64) []uint64 {
+	h1 := fnv.New64a()
+	_, _ = h1.Write([]byte(item))
+	a := h1.Sum64()
+
+	h2 := fnv.New64()
+	_, _ = h2.Write([]byte(item))
+	// odd step so positions do not repeat when m is a power of 2
+	b := h2.Sum64() | 1
+
+	positions := make([]uint64, k)
+	for i := range positions {
+		positions[i] = (a + uint64(i)*b) % m
+	}
+	return positions
+}
+
+func redisClient(client goredislib.UniversalClient) goredislib.UniversalClient {
+	if client != nil {
+		return client
+	}
+	return searedis.GetClient()
+}
@@ -0,0 +1,122 @@
+package seasketch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+func TestBloomSize(t *testing.T) {
+	bits, hashes := bloomSize(1000, 0.01)
+	if bits != 9586 || hashes != 7 {
+		t.Fatalf("unexpected size: %d bits, %d hashes", bits, hashes)
+	}
+}
+
+func TestLocations(t *testing.T) {
+	a := locations("user:42", 7, 9586)
+	b := locations("user:42", 7, 9586)
+	for i := range a {
+		if a[i] != b[i] || a[i] >= 9586 {
+			t.Fatalf("unexpected locations: %v %v", a, b)
+		}
+	}
+}
+
+func TestBoolResult(t *testing.T) {
+	bits := func(values ...int64) []*goredislib.IntCmd {
+		cmds := make([]*goredislib.IntCmd, len(values))
+		for i, v := range values {
+			cmds[i] = goredislib.NewIntCmd(context.Background())
+			cmds[i].SetVal(v)
+		}
+		return cmds
+	}
+
+	if ok, _ := (&BoolResult{cmds: bits(1, 0, 1), all: true}).Val(); ok {
+		t.Fatal("exists should be false when a bit is unset")
+	}
+	if ok, _ := (&BoolResult{cmds: bits(1, 1), all: true}).Val(); !ok {
+		t.Fatal("exists should be true when every bit is set")
+	}
+	if ok, _ := (&BoolResult{cmds: bits(1, 0)}).Val(); !ok {
+		t.Fatal("add should be new when a bit was unset")
+	}
+	if ok, _ := (&BoolResult{cmds: bits(1, 1)}).Val(); ok {
+		t.Fatal("add should not be new when every bit was set")
+	}
+}
+
+func TestCountMinSize(t *testing.T) {
+	width, depth := countMinSize(0.001, 0.01)
+	if width != 2719 || depth != 5 {
+		t.Fatalf("unexpected size: %d x %d", width, depth)
+	}
+}
+
+func TestCounterKeys(t *testing.T) {
+	c := NewCounter("visitors", &CounterOptions{Bucket: time.Hour})
+	from := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	keys := c.keys(from, from.Add(2*time.Hour))
+	if len(keys) != 3 || keys[0] != "hll:{visitors}:1792396800" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
+
+func TestScalableAddArgs(t *testing.T) {
+	b := NewScalableBloom("{seen}", 100, 0.01, &ScalableBloomOptions{TTL: time.Minute})
+	layers := []*Bloom{
+		NewBloom("{seen}:0", 100, 0.005, nil),
+		NewBloom("{seen}:1", 200, 0.0025, nil),
+	}
+
+	keys, args := b.addArgs(layers, "user:42")
+	if len(keys) != 3 || keys[0] != "{seen}:meta" || keys[2] != "{seen}:1" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	if args[0] != uint64(200) || args[1] != maxScalingLayers || args[2] != int64(60000) {
+		t.Fatalf("unexpected args: %v", args[:3])
+	}
+	// each layer is its number of positions followed by the positions
+	first := args[3].(int)
+	if first != layers[0].hashes || args[4+first].(int) != layers[1].hashes || len(args) != 5+first+layers[1].hashes {
+		t.Fatalf("unexpected layer args: %v", args[3:])
+	}
+}
+
+func TestScalableBloomTTL(t *testing.T) {
+	m, client := redistest.NewMiniredis(t)
+	b := NewScalableBloom("{seen}", 2, 0.01, &ScalableBloomOptions{TTL: time.Minute, Client: client})
+	ctx := context.Background()
+
+	// the second item fills the first layer
+	for _, item := range []string{"a", "b"} {
+		if added, err := b.Add(ctx, item); !added || err != nil {
+			t.Fatalf("unexpected add of %s: %v, %v", item, added, err)
+		}
+	}
+	m.FastForward(40 * time.Second)
+	if added, err := b.Add(ctx, "c"); !added || err != nil {
+		t.Fatalf("unexpected add of c: %v, %v", added, err)
+	}
+	if !m.Exists("{seen}:1") {
+		t.Fatal("expected c added to the second layer")
+	}
+
+	// the add renewed the first layer too
+	m.FastForward(40 * time.Second)
+	for _, item := range []string{"a", "b", "c"} {
+		if ok, err := b.Exists(ctx, item); !ok || err != nil {
+			t.Fatalf("expected %s in the filter, got %v, %v", item, ok, err)
+		}
+	}
+
+	m.FastForward(time.Minute)
+	if ok, _ := b.Exists(ctx, "a"); ok {
+		t.Fatal("expected the filter expired")
+	}
+}
@@ -0,0 +1,57 @@
+package seastream
+
+import (
+	"context"
+	"encoding/json"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// BodyField is the stream entry field that holds the message body.
+const BodyField = "body"
+
+// Producer add messages to a stream.
+type Producer struct {
+	client goredislib.UniversalClient
+	stream string
+	maxLen int64
+}
+
+// NewProducer return new producer of the stream, the stream is trimmed to
+// about maxLen entries on every add, 0 means no trimming. nil client means
+// the client of searedis.ConnectRedisV1.
+func NewProducer(client goredislib.UniversalClient, stream string, maxLen int64) *Producer {
+	return &Producer{client: client, stream: stream, maxLen: maxLen}
+}
+
+// Publish add the body to the stream and return the entry id.
+func (p *Producer) Publish(ctx context.Context, body []byte) (string, error) {
+	args := &goredislib.XAddArgs{
+		Stream: p.stream,
+		Values: []interface{}{BodyField, body},
+	}
+	if p.maxLen > 0 {
+		// "~" lets redis trim whole macro nodes, which is much cheaper than exact trimming
+		args.MaxLen = p.maxLen
+		args.Approx = true
+	}
+	return redisClient(p.client).XAdd(ctx, args).Result()
+}
+
+// PublishObject add the value encoded as JSON to the stream, same as SetObject.
+func (p *Producer) PublishObject(ctx context.Context, value interface{}) (string, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return p.Publish(ctx, body)
+}
+
+func redisClient(client goredislib.UniversalClient) goredislib.UniversalClient {
+	if client != nil {
+		return client
+	}
+	return searedis.GetClient()
+}
@@ -0,0 +1,432 @@
+package seastream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+var logger = plog.NewBizLogger("redis-stream")
+
+var (
+	// ErrClosed is returned by HandleMessages when the worker was closed.
+	ErrClosed = errors.New("stream: worker is closed")
+	// ErrRunning is returned by HandleMessages when it's already running.
+	ErrRunning = errors.New("stream: worker is already running")
+)
+
+const (
+	defaultBatchSize     = 10
+	defaultBlock         = 5 * time.Second
+	defaultClaimIdle     = time.Minute
+	defaultMaxDeliveries = 5
+	retryDelay           = time.Second
+	ackTimeout           = 5 * time.Second
+)
+
+// Config struct contain options of Worker.
+type Config struct {
+	Stream string
+	Group  string
+	// Consumer is the consumer name in the group, default to "<hostname>-<pid>".
+	// It must be unique per running worker.
+	Consumer string
+	// Concurrency is the number of messages handled at the same time, default to 1.
+	Concurrency int
+	// BatchSize is the max number of messages read or claimed at once, default to 10.
+	BatchSize int64
+	// Block is how long a read waits for new messages, default to 5 seconds.
+	Block time.Duration
+	// ClaimIdle is how long a message stays pending before it's reclaimed
+	// by another consumer, default to 1 minute. It's also the delay before
+	// a failed message is retried.
+	ClaimIdle time.Duration
+	// ClaimInterval is how often pending messages are reclaimed, default to ClaimIdle/2.
+	ClaimInterval time.Duration
+	// MaxDeliveries is how many times a message is delivered before it's
+	// moved to the dead-letter stream, default to 5, negative value disables it.
+	MaxDeliveries int64
+	// DeadLetterStream is default to "<stream>:dead".
+	DeadLetterStream string
+	// DeadLetterMaxLen trim the dead-letter stream, 0 means no trimming.
+	DeadLetterMaxLen int64
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Worker consume a stream in a consumer group. Messages are acked when
+// the handler succeeds, failed messages stay pending and are retried
+// after ClaimIdle.
+type Worker struct {
+	conf Config
+
+	mu       sync.Mutex
+	running  bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewWorker return new worker of the stream.
+func NewWorker(conf *Config) (*Worker, error) {
+	if conf == nil || conf.Stream == "" || conf.Group == "" {
+		return nil, errors.New("stream: stream and group are required")
+	}
+
+	c := *conf
+	if c.Consumer == "" {
+		host, _ := os.Hostname()
+		c.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.Block <= 0 {
+		c.Block = defaultBlock
+	}
+	if c.ClaimIdle <= 0 {
+		c.ClaimIdle = defaultClaimIdle
+	}
+	if c.ClaimInterval <= 0 {
+		c.ClaimInterval = c.ClaimIdle / 2
+	}
+	if c.MaxDeliveries == 0 {
+		c.MaxDeliveries = defaultMaxDeliveries
+	}
+	if c.DeadLetterStream == "" {
+		c.DeadLetterStream = c.Stream + ":dead"
+	}
+
+	return &Worker{
+		conf: c,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}, nil
+}
+
+// HandleMessages consume the stream and call f for each message body, it
+// blocks until ctx is done or Close is called. In-flight messages are
+// finished before it returns, messages read but not started yet stay
+// pending and are reclaimed by other consumers.
+func (w *Worker) HandleMessages(ctx context.Context, f func(d []byte) error) error {
+	w.mu.Lock()
+	if w.running {
+		w.mu.Unlock()
+		return ErrRunning
+	}
+	select {
+	case <-w.stop:
+		w.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	w.running = true
+	w.mu.Unlock()
+	defer close(w.done)
+
+	if err := w.createGroup(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	messages := make(chan goredislib.XMessage)
+
+	var handlers sync.WaitGroup
+	for i := 0; i < w.conf.Concurrency; i++ {
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			for msg := range messages {
+				w.handle(msg, f)
+			}
+		}()
+	}
+
+	var loops sync.WaitGroup
+	loops.Add(2)
+	go func() {
+		defer loops.Done()
+		w.read(ctx, messages)
+	}()
+	go func() {
+		defer loops.Done()
+		w.reclaim(ctx, messages)
+	}()
+
+	loops.Wait()
+	close(messages)
+	handlers.Wait()
+	return nil
+}
+
+// Close stop the worker and wait for in-flight messages.
+func (w *Worker) Close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	w.mu.Lock()
+	running := w.running
+	w.mu.Unlock()
+	if running {
+		<-w.done
+	}
+}
+
+func (w *Worker) createGroup(ctx context.Context) error {
+	// start from "0" so messages added before the group existed are not lost
+	err := w.client().XGroupCreateMkStream(ctx, w.conf.Stream, w.conf.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (w *Worker) read(ctx context.Context, messages chan<- goredislib.XMessage) {
+	for ctx.Err() == nil {
+		streams, err := w.client().XReadGroup(ctx, &goredislib.XReadGroupArgs{
+			Group:    w.conf.Group,
+			Consumer: w.conf.Consumer,
+			Streams:  []string{w.conf.Stream, ">"},
+			Count:    w.conf.BatchSize,
+			Block:    w.conf.Block,
+		}).Result()
+		if err != nil {
+			if err == goredislib.Nil || ctx.Err() != nil {
+				continue
+			}
+			logger.Error().Err(err).Var("stream", w.conf.Stream).Msg("error when read messages")
+			sleep(ctx, retryDelay)
+			continue
+		}
+
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *Worker) reclaim(ctx context.Context, messages chan<- goredislib.XMessage) {
+	ticker := time.NewTicker(w.conf.ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := "0-0"
+		for {
+			next, claimed, err := w.autoClaim(ctx, start)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error().Err(err).Var("stream", w.conf.Stream).Msg("error when reclaim messages")
+				}
+				break
+			}
+
+			claimed, err = w.deadLetter(ctx, claimed)
+			if err != nil && ctx.Err() == nil {
+				logger.Error().Err(err).Var("stream", w.conf.Stream).Msg("error when move messages to dead-letter stream")
+			}
+
+			for _, msg := range claimed {
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// autoClaim claim messages pending for longer than ClaimIdle. The reply is
+// parsed here because redis 7 added a third element that the client does
+// not support yet.
+func (w *Worker) autoClaim(ctx context.Context, start string) (string, []goredislib.XMessage, error) {
+	res, err := w.client().Do(ctx, "xautoclaim", w.conf.Stream, w.conf.Group, w.conf.Consumer,
+		w.conf.ClaimIdle.Milliseconds(), start, "count", w.conf.BatchSize).Result()
+	if err != nil {
+		return "", nil, err
+	}
+	return parseAutoClaim(res)
+}
+
+// deadLetter move messages delivered more than MaxDeliveries times to the
+// dead-letter stream and return the others.
+func (w *Worker) deadLetter(ctx context.Context, claimed []goredislib.XMessage) ([]goredislib.XMessage, error) {
+	if w.conf.MaxDeliveries < 0 || len(claimed) == 0 {
+		return claimed, nil
+	}
+
+	pipe := w.client().Pipeline()
+	cmds := make([]*goredislib.XPendingExtCmd, len(claimed))
+	for i, msg := range claimed {
+		cmds[i] = pipe.XPendingExt(ctx, &goredislib.XPendingExtArgs{
+			Stream: w.conf.Stream,
+			Group:  w.conf.Group,
+			Start:  msg.ID,
+			End:    msg.ID,
+			Count:  1,
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return claimed, err
+	}
+
+	alive := claimed[:0]
+	dead := make([]goredislib.XMessage, 0)
+	deliveries := make([]int64, 0)
+	for i, msg := range claimed {
+		pending := cmds[i].Val()
+		// the claim already counted the next delivery
+		if len(pending) == 1 && pending[0].RetryCount > w.conf.MaxDeliveries {
+			dead = append(dead, msg)
+			deliveries = append(deliveries, pending[0].RetryCount-1)
+			continue
+		}
+		alive = append(alive, msg)
+	}
+	if len(dead) == 0 {
+		return alive, nil
+	}
+
+	// XADD before XACK, so a message is never lost between both streams,
+	// a message that failed to be added stays pending and is retried on
+	// the next claim
+	pipe = w.client().Pipeline()
+	adds := make([]*goredislib.StringCmd, len(dead))
+	for i, msg := range dead {
+		args := &goredislib.XAddArgs{
+			Stream: w.conf.DeadLetterStream,
+			Values: []interface{}{
+				BodyField, msg.Values[BodyField],
+				"stream", w.conf.Stream,
+				"id", msg.ID,
+				"deliveries", deliveries[i],
+			},
+		}
+		if w.conf.DeadLetterMaxLen > 0 {
+			args.MaxLen = w.conf.DeadLetterMaxLen
+			args.Approx = true
+		}
+		adds[i] = pipe.XAdd(ctx, args)
+	}
+	_, err := pipe.Exec(ctx)
+
+	ids := make([]string, 0, len(dead))
+	for i, msg := range dead {
+		if adds[i].Err() != nil {
+			continue
+		}
+		ids = append(ids, msg.ID)
+		logger.Warn().Var("stream", w.conf.Stream).Var("id", msg.ID).Msg("move message to dead-letter stream")
+	}
+	if len(ids) > 0 {
+		if ackErr := w.client().XAck(ctx, w.conf.Stream, w.conf.Group, ids...).Err(); ackErr != nil {
+			err = ackErr
+		}
+	}
+	return alive, err
+}
+
+func (w *Worker) handle(msg goredislib.XMessage, f func(d []byte) error) {
+	body := messageBody(msg)
+
+	if err := f(body); err != nil {
+		// keep it pending, it's retried after ClaimIdle
+		logger.Error().Err(err).Var("stream", w.conf.Stream).Var("id", msg.ID).Msg("error when handle a message")
+		return
+	}
+
+	// ack even when shutting down, so finished messages are not handled twice
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+	if err := w.client().XAck(ctx, w.conf.Stream, w.conf.Group, msg.ID).Err(); err != nil {
+		logger.Error().Err(err).Var("stream", w.conf.Stream).Var("id", msg.ID).Msg("error when ack")
+	}
+}
+
+func (w *Worker) client() goredislib.UniversalClient {
+	return redisClient(w.conf.Client)
+}
+
+func messageBody(msg goredislib.XMessage) []byte {
+	switch v := msg.Values[BodyField].(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	}
+	return nil
+}
+
+// parseAutoClaim parse XAUTOCLAIM reply: next start id, claimed messages
+// and, since redis 7, deleted ids.
+func parseAutoClaim(res interface{}) (string, []goredislib.XMessage, error) {
+	reply, ok := res.([]interface{})
+	if !ok || len(reply) < 2 {
+		return "", nil, errors.New("stream: unexpected xautoclaim reply")
+	}
+
+	next, _ := reply[0].(string)
+	entries, _ := reply[1].([]interface{})
+
+	messages := make([]goredislib.XMessage, 0, len(entries))
+	for _, entry := range entries {
+		// redis 6.2 return nil entries for deleted messages
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) != 2 {
+			continue
+		}
+		id, _ := fields[0].(string)
+		values, _ := fields[1].([]interface{})
+
+		msg := goredislib.XMessage{ID: id, Values: make(map[string]interface{}, len(values)/2)}
+		for i := 0; i+1 < len(values); i += 2 {
+			key, _ := values[i].(string)
+			msg.Values[key] = values[i+1]
+		}
+		messages = append(messages, msg)
+	}
+	return next, messages, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
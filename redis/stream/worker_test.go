@@ -0,0 +1,115 @@
+package seastream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ponlv/go-kit/internal/redistest"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+func TestParseAutoClaim(t *testing.T) {
+	reply := []interface{}{
+		"1700000000000-1",
+		[]interface{}{
+			[]interface{}{"1700000000000-0", []interface{}{"body", "hello"}},
+			nil,
+		},
+		[]interface{}{"1690000000000-0"},
+	}
+
+	next, messages, err := parseAutoClaim(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "1700000000000-1" {
+		t.Fatalf("unexpected next id: %s", next)
+	}
+	if len(messages) != 1 || messages[0].ID != "1700000000000-0" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	if string(messageBody(messages[0])) != "hello" {
+		t.Fatalf("unexpected body: %v", messages[0].Values)
+	}
+
+	if _, _, err = parseAutoClaim("OK"); err == nil {
+		t.Fatal("expected error for invalid reply")
+	}
+}
+
+func TestNewWorkerDefaults(t *testing.T) {
+	if _, err := NewWorker(&Config{Stream: "orders"}); err == nil {
+		t.Fatal("expected error without group")
+	}
+
+	w, err := NewWorker(&Config{Stream: "orders", Group: "billing", ClaimIdle: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.conf.Consumer == "" || w.conf.Concurrency != 1 || w.conf.ClaimInterval != 5*time.Second {
+		t.Fatalf("unexpected config: %+v", w.conf)
+	}
+	if w.conf.DeadLetterStream != "orders:dead" || w.conf.MaxDeliveries != defaultMaxDeliveries {
+		t.Fatalf("unexpected dead-letter config: %+v", w.conf)
+	}
+}
+
+func TestCloseBeforeStart(t *testing.T) {
+	w, err := NewWorker(&Config{Stream: "orders", Group: "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if err = w.HandleMessages(context.Background(), func(d []byte) error { return nil }); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	_, client := redistest.NewMiniredis(t)
+	ctx := context.Background()
+	w, err := NewWorker(&Config{Stream: "orders", Group: "billing", Consumer: "c1", MaxDeliveries: 1, Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.XGroupCreateMkStream(ctx, "orders", "billing", "0")
+	for _, body := range []string{"a", "b"} {
+		client.XAdd(ctx, &goredislib.XAddArgs{Stream: "orders", Values: []interface{}{BodyField, body}})
+	}
+	read, err := client.XReadGroup(ctx, &goredislib.XReadGroupArgs{Group: "billing", Consumer: "c1", Streams: []string{"orders", ">"}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{read[0].Messages[0].ID, read[0].Messages[1].ID}
+	// the second delivery goes over MaxDeliveries
+	claimed, err := client.XClaim(ctx, &goredislib.XClaimArgs{Stream: "orders", Group: "billing", Consumer: "c1", Messages: ids}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// messages that can not be added to the dead-letter stream stay pending
+	client.Set(ctx, "orders:dead", "not a stream", 0)
+	alive, err := w.deadLetter(ctx, claimed)
+	if err == nil || len(alive) != 0 {
+		t.Fatalf("expected the XADD error without alive messages, got %v, %v", alive, err)
+	}
+	if n := client.XPending(ctx, "orders", "billing").Val().Count; n != 2 {
+		t.Fatalf("expected 2 pending messages, got %d", n)
+	}
+
+	client.Del(ctx, "orders:dead")
+	claimed, _ = client.XClaim(ctx, &goredislib.XClaimArgs{Stream: "orders", Group: "billing", Consumer: "c1", Messages: ids}).Result()
+	if _, err = w.deadLetter(ctx, claimed); err != nil {
+		t.Fatal(err)
+	}
+	if n := client.XPending(ctx, "orders", "billing").Val().Count; n != 0 {
+		t.Fatalf("expected no pending messages, got %d", n)
+	}
+	dead := client.XRange(ctx, "orders:dead", "-", "+").Val()
+	if len(dead) != 2 || dead[0].Values["id"] != ids[0] || dead[0].Values[BodyField] != "a" {
+		t.Fatalf("unexpected dead-letter stream: %+v", dead)
+	}
+}
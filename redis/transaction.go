@@ -12,7 +12,7 @@ func TransactionWithCtx(ctx context.Context, f TransactionFunc) error {
 	return TransactionWithClient(ctx, client, f)
 }
 
-func TransactionWithClient(ctx context.Context, client *goredislib.Client, f TransactionFunc) error {
+func TransactionWithClient(ctx context.Context, client goredislib.UniversalClient, f TransactionFunc) error {
 	err := client.Watch(ctx, func(tx *redis.Tx) error {
 
 		_, err := tx.TxPipelined(ctx, f)
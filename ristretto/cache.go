@@ -0,0 +1,306 @@
+package ristretto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNameUsed is returned by New when a cache with the same name exists.
+var ErrNameUsed = errors.New("ristretto: cache name already used")
+
+// Key is the key types supported by ristretto.
+type Key interface {
+	string | uint64 | int | int64 | int32 | uint32 | byte
+}
+
+// Config struct contain options of Cache.
+type Config[V any] struct {
+	// Name register the cache so it can be found with Lookup, empty means not registered.
+	Name string
+	// NumCounters is the number of keys tracked for admission, about 10x
+	// the max number of items. Default to 1e6.
+	NumCounters int64
+	// MaxCost is the capacity of the cache in cost units, default to 1 << 26.
+	MaxCost int64
+	// BufferItems is default to 64.
+	BufferItems int64
+	// Cost return the cost of a value, default to 1 per item.
+	// Use EncodedSize to weight values by their encoded size.
+	Cost func(value V) int64
+	// TTL is the default ttl of Set, 0 means no expiration.
+	TTL time.Duration
+	// Metrics enable Cache.Metrics, it has a small overhead.
+	Metrics bool
+	// LoadTimeout bound the shared loader call of GetOrLoad, it does not
+	// depend on the context of the caller that started it. Default to 30 seconds.
+	LoadTimeout time.Duration
+}
+
+// Cache is a typed in-process cache, values are stored as is without encoding.
+type Cache[K Key, V any] struct {
+	name  string
+	inc   *ristretto.Cache
+	conf  Config[V]
+	group singleflight.Group
+	index *index[K, V]
+
+	snapMu sync.Mutex
+	snap   *snapshotter
+}
+
+// entry is the value stored in ristretto, it keeps the original key
+// because ristretto callbacks only receive hashed keys.
+type entry[K Key, V any] struct {
+	key   K
+	value V
+	tags  []string
+	// hits rank the keys saved by snapshots
+	hits uint32
+}
+
+var (
+	namedMu sync.Mutex
+	named   = make(map[string]interface{})
+)
+
+// New return new typed cache.
+// EX used: users, err := ristretto.New[string, *User](ristretto.Config[*User]{Name: "users", MaxCost: 10000})
+func New[K Key, V any](conf Config[V]) (*Cache[K, V], error) {
+	if conf.NumCounters <= 0 {
+		conf.NumCounters = 1e6
+	}
+	if conf.MaxCost <= 0 {
+		conf.MaxCost = 1 << 26
+	}
+	if conf.BufferItems <= 0 {
+		conf.BufferItems = 64
+	}
+	if conf.LoadTimeout <= 0 {
+		conf.LoadTimeout = 30 * time.Second
+	}
+
+	c := &Cache[K, V]{name: conf.Name, conf: conf, index: newIndex[K, V]()}
+	rconf := &ristretto.Config{
+		NumCounters: conf.NumCounters,
+		MaxCost:     conf.MaxCost,
+		BufferItems: conf.BufferItems,
+		Metrics:     conf.Metrics,
+		// costs are in the units of Config.Cost, not bytes of internal storage
+		IgnoreInternalCost: true,
+		// called when an entry is rejected, evicted, expired, replaced or deleted
+		OnExit: func(value interface{}) {
+			if e, ok := value.(*entry[K, V]); ok {
+				c.index.remove(e)
+			}
+		},
+	}
+	if conf.Cost != nil {
+		rconf.Cost = func(value interface{}) int64 {
+			return conf.Cost(value.(*entry[K, V]).value)
+		}
+	}
+
+	if conf.Name != "" {
+		namedMu.Lock()
+		defer namedMu.Unlock()
+		if _, ok := named[conf.Name]; ok {
+			return nil, ErrNameUsed
+		}
+	}
+
+	inc, err := ristretto.NewCache(rconf)
+	if err != nil {
+		return nil, err
+	}
+	c.inc = inc
+
+	if conf.Name != "" {
+		named[conf.Name] = c
+	}
+	return c, nil
+}
+
+// Lookup return the cache registered with the name, false if it does not
+// exist or has other key and value types.
+func Lookup[K Key, V any](name string) (*Cache[K, V], bool) {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	c, ok := named[name].(*Cache[K, V])
+	return c, ok
+}
+
+// EncodedSize return a cost function that weight values by their JSON size,
+// for caches whose MaxCost is in bytes. Values are still stored as is.
+func EncodedSize[V any]() func(value V) int64 {
+	return func(value V) int64 {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return 1
+		}
+		return int64(len(data))
+	}
+}
+
+// Name return the name of the cache.
+func (c *Cache[K, V]) Name() string {
+	return c.name
+}
+
+// Get return the cached value, the bool result is false if key not exists.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	if v, ok := c.inc.Get(key); ok {
+		if e, ok := v.(*entry[K, V]); ok {
+			atomic.AddUint32(&e.hits, 1)
+			return e.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Set cache the value with the default ttl, it report false if the value
+// was dropped. Sets are applied asynchronously, call Wait to apply them.
+func (c *Cache[K, V]) Set(key K, value V) bool {
+	return c.SetWithTTL(key, value, c.conf.TTL)
+}
+
+// SetWithTTL cache the value with the ttl, 0 means no expiration.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	return c.set(&entry[K, V]{key: key, value: value}, ttl)
+}
+
+// SetWithTags cache the value with the ttl and tags, e.g. "user:42" or
+// "tenant:7", so it can be removed with InvalidateTag.
+func (c *Cache[K, V]) SetWithTags(key K, value V, ttl time.Duration, tags ...string) bool {
+	return c.set(&entry[K, V]{key: key, value: value, tags: tags}, ttl)
+}
+
+func (c *Cache[K, V]) set(e *entry[K, V], ttl time.Duration) bool {
+	var cost int64 = 1
+	if c.conf.Cost != nil {
+		// computed by Config.Cost when the item is applied
+		cost = 0
+	}
+
+	// indexed first so an invalidation racing with the admission still sees the key
+	c.index.add(e)
+	if !c.inc.SetWithTTL(e.key, e, cost, ttl) {
+		c.index.remove(e)
+		return false
+	}
+	return true
+}
+
+// GetOrLoad return the cached value, or call loader and cache its result
+// with the default ttl. Concurrent loads of the same key share one loader
+// call, it gets the values of ctx but is only canceled by LoadTimeout, so a
+// caller giving up does not fail the others.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+
+	ch := c.group.DoChan(fmt.Sprint(key), func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(detach(ctx), c.conf.LoadTimeout)
+		defer cancel()
+
+		v, err := loader(loadCtx)
+		if err != nil {
+			return v, err
+		}
+		c.Set(key, v)
+		return v, nil
+	})
+
+	select {
+	case res := <-ch:
+		v, _ := res.Val.(V)
+		return v, res.Err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// detachedCtx keep the values of its parent without its deadline and cancellation.
+type detachedCtx struct {
+	context.Context
+}
+
+func (detachedCtx) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedCtx) Done() <-chan struct{}       { return nil }
+func (detachedCtx) Err() error                  { return nil }
+
+func detach(ctx context.Context) context.Context {
+	return detachedCtx{ctx}
+}
+
+// Delete remove the key.
+func (c *Cache[K, V]) Delete(key K) {
+	c.index.delete(key)
+	c.inc.Del(key)
+}
+
+// InvalidateTag remove the keys having any of the tags and return their number.
+func (c *Cache[K, V]) InvalidateTag(tags ...string) int {
+	keys := c.index.takeTags(tags)
+	for _, key := range keys {
+		c.inc.Del(key)
+	}
+	return len(keys)
+}
+
+// InvalidatePrefix remove the keys starting with prefix and return their
+// number. Prefixes ending with PrefixSeparator, e.g. "user:42:", use the
+// index, other prefixes scan every key.
+func (c *Cache[K, V]) InvalidatePrefix(prefix string) int {
+	keys := c.index.takePrefix(prefix)
+	for _, key := range keys {
+		c.inc.Del(key)
+	}
+	return len(keys)
+}
+
+// Clear remove every key.
+func (c *Cache[K, V]) Clear() {
+	c.index.reset()
+	c.inc.Clear()
+}
+
+// Wait block until buffered sets are applied.
+func (c *Cache[K, V]) Wait() {
+	c.inc.Wait()
+}
+
+// Metrics return the statistics of the cache, such as Ratio and
+// KeysEvicted, nil if Config.Metrics is false.
+func (c *Cache[K, V]) Metrics() *ristretto.Metrics {
+	return c.inc.Metrics
+}
+
+// Instance return the underlying cache, e.g. for GetTTL. Its values are
+// internal wrappers, read and write them through the Cache methods.
+func (c *Cache[K, V]) Instance() *ristretto.Cache {
+	return c.inc
+}
+
+// Close save the last snapshot if enabled, stop the cache and unregister its name.
+func (c *Cache[K, V]) Close() {
+	c.closeSnapshot()
+	if c.name != "" {
+		namedMu.Lock()
+		if named[c.name] == c {
+			delete(named, c.name)
+		}
+		namedMu.Unlock()
+	}
+	c.inc.Close()
+}
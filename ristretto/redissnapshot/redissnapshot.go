@@ -0,0 +1,51 @@
+// Package redissnapshot save the snapshots of ristretto caches in redis, so
+// a new pod can warm up from the snapshot of another one.
+// EX used: restored, err := users.EnableSnapshot(ctx, ristretto.SnapshotConfig{Store: &redissnapshot.Store{TTL: time.Hour}})
+package redissnapshot
+
+import (
+	"context"
+	"time"
+
+	searedis "github.com/ponlv/go-kit/redis"
+
+	goredislib "github.com/go-redis/redis/v8"
+)
+
+// Store save snapshots as redis strings, it implements ristretto.SnapshotStore.
+type Store struct {
+	// Prefix of the keys, default to "ristretto:snapshot:".
+	Prefix string
+	// TTL of the snapshots, 0 means no expiration.
+	TTL time.Duration
+	// Client is default to the client of searedis.ConnectRedisV1.
+	Client goredislib.UniversalClient
+}
+
+// Save set the snapshot key.
+func (s *Store) Save(ctx context.Context, name string, data []byte) error {
+	return s.redis().Set(ctx, s.key(name), data, s.TTL).Err()
+}
+
+// Load get the snapshot key, nil if it does not exist.
+func (s *Store) Load(ctx context.Context, name string) ([]byte, error) {
+	data, err := s.redis().Get(ctx, s.key(name)).Bytes()
+	if err == goredislib.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *Store) key(name string) string {
+	if s.Prefix == "" {
+		return "ristretto:snapshot:" + name
+	}
+	return s.Prefix + name
+}
+
+func (s *Store) redis() goredislib.UniversalClient {
+	if s.Client != nil {
+		return s.Client
+	}
+	return searedis.GetClient()
+}
@@ -1,6 +1,7 @@
 package ristretto
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -10,16 +11,23 @@ import (
 
 var ErrNotFound = errors.New("ristretto: not found")
 
-//Ristretto ...
+// Ristretto ...
 type Ristretto struct {
-	inc *ristretto.Cache
+	cache *Cache[string, []byte]
 }
 
 var ristrettoCache Ristretto
 
-func (r *Ristretto) Instance() *ristretto.Cache { return r.inc }
+// Instance return the underlying cache.
+//
+// Deprecated: values are stored wrapped, so values set on the instance are
+// not visible through Get and its values cannot be read directly. Use Cache.
+func (r *Ristretto) Instance() *ristretto.Cache { return r.cache.Instance() }
 
-//GetInc ...
+// Cache return the typed cache storing the encoded values.
+func (r *Ristretto) Cache() *Cache[string, []byte] { return r.cache }
+
+// GetInc ...
 func GetInc() *Ristretto {
 	if ristrettoCache == (Ristretto{}) {
 		ristrettoCache.constructor()
@@ -31,7 +39,7 @@ func GetInc() *Ristretto {
 
 func (r *Ristretto) constructor() {
 	var err error
-	r.inc, err = ristretto.NewCache(&ristretto.Config{
+	r.cache, err = New[string, []byte](Config[[]byte]{
 		NumCounters: 1e7,           // number of keys to track frequency of (10M).
 		MaxCost:     (1 << 30) * 1, // maximum cost of cache (1GB).
 		BufferItems: 64,            // number of keys per Get buffer.
@@ -48,7 +56,7 @@ func (r *Ristretto) Set(key string, data interface{}) (err error) {
 		return err
 	}
 
-	r.inc.SetWithTTL(key, jsonStr, 1, 0)
+	r.cache.SetWithTTL(key, jsonStr, 0)
 	return
 }
 
@@ -58,24 +66,28 @@ func (r *Ristretto) SetWithTTL(key string, data interface{}, ttl int64) (err err
 		return err
 	}
 
-	r.inc.SetWithTTL(key, jsonStr, 1, time.Duration(ttl)*time.Second)
+	r.cache.SetWithTTL(key, jsonStr, time.Duration(ttl)*time.Second)
+	return
+}
+
+// SetWithTags cache the data with the ttl in seconds and tags, 0 means no expiration.
+// EX used: err := ristretto.GetInc().SetWithTags("user:42:profile", profile, 60, "user:42", "tenant:7")
+func (r *Ristretto) SetWithTags(key string, data interface{}, ttl int64, tags ...string) (err error) {
+	jsonStr, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	r.cache.SetWithTags(key, jsonStr, time.Duration(ttl)*time.Second, tags...)
 	return
 }
 
 func (r *Ristretto) Get(key string, value interface{}) (data []byte, err error) {
-	val, has := r.inc.Get(key)
+	data, has := r.cache.Get(key)
 	if !has {
 		err = ErrNotFound
 		return
 	}
-	switch d := val.(type) {
-	case string:
-		data = []byte(d)
-	case []byte:
-		data = d
-	default:
-		data, err = json.Marshal(d)
-	}
 
 	err = json.Unmarshal(data, &value)
 	if err != nil {
@@ -84,5 +96,22 @@ func (r *Ristretto) Get(key string, value interface{}) (data []byte, err error)
 	return
 }
 
-func (r *Ristretto) Delete(key string) (err error) { r.inc.Del(key); return }
-func (r *Ristretto) Reset() (err error)            { r.inc.Clear(); return }
+func (r *Ristretto) Delete(key string) (err error) { r.cache.Delete(key); return }
+func (r *Ristretto) Reset() (err error)            { r.cache.Clear(); return }
+
+// InvalidateTag remove the keys having any of the tags and return their number.
+func (r *Ristretto) InvalidateTag(tags ...string) int { return r.cache.InvalidateTag(tags...) }
+
+// InvalidatePrefix remove the keys starting with prefix and return their number.
+func (r *Ristretto) InvalidatePrefix(prefix string) int { return r.cache.InvalidatePrefix(prefix) }
+
+// EnableSnapshot restore the last snapshot and save a new one every interval, see Cache.EnableSnapshot.
+func (r *Ristretto) EnableSnapshot(ctx context.Context, conf SnapshotConfig) (int, error) {
+	if conf.Name == "" {
+		conf.Name = r.String()
+	}
+	return r.cache.EnableSnapshot(ctx, conf)
+}
+
+// SaveSnapshot save a snapshot now, e.g. on shutdown.
+func (r *Ristretto) SaveSnapshot(ctx context.Context) error { return r.cache.SaveSnapshot(ctx) }
@@ -1,9 +1,13 @@
 package ristretto
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -31,3 +35,206 @@ func TestAddKey(t *testing.T) {
 	log.Println(a, b)
 	//assert.Equal(t, a, b)
 }
+
+type user struct {
+	Name string `json:"name"`
+}
+
+func TestTypedCache(t *testing.T) {
+	c, err := New[string, *user](Config[*user]{Name: "users", Cost: EncodedSize[*user](), Metrics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = New[string, *user](Config[*user]{Name: "users"}); err != ErrNameUsed {
+		t.Fatalf("expected ErrNameUsed, got %v", err)
+	}
+	if found, ok := Lookup[string, *user]("users"); !ok || found != c {
+		t.Fatal("expected named cache")
+	}
+	if _, ok := Lookup[int, *user]("users"); ok {
+		t.Fatal("lookup with other types should fail")
+	}
+
+	u := &user{Name: "a"}
+	c.Set("1", u)
+	c.Wait()
+	if got, ok := c.Get("1"); !ok || got != u {
+		t.Fatalf("expected the same pointer, got %v", got)
+	}
+	if c.Metrics() == nil || c.Metrics().Hits() != 1 {
+		t.Fatal("expected metrics with one hit")
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	c, err := New[int, string](Config[string]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var calls int32
+	loader := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrLoad(context.Background(), 7, loader); err != nil || v != "value" {
+				t.Errorf("unexpected result: %q %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected one loader call, got %d", calls)
+	}
+}
+
+func TestGetOrLoadDetached(t *testing.T) {
+	c, err := New[int, string](Config[string]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	loaderErr := make(chan error, 2)
+	var once sync.Once
+	loader := func(ctx context.Context) (string, error) {
+		once.Do(func() { close(started) })
+		<-release
+		loaderErr <- ctx.Err()
+		return "value", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, 7, loader)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		v, _ := c.GetOrLoad(context.Background(), 7, loader)
+		second <- v
+	}()
+
+	// the first caller gives up without failing the second one
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("expected the first caller to be canceled, got %v", err)
+	}
+	close(release)
+
+	if err := <-loaderErr; err != nil {
+		t.Fatalf("loader should not be canceled, got %v", err)
+	}
+	if v := <-second; v != "value" {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c, err := New[string, int](Config[int]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetWithTags("user:42:profile", 1, 0, "user:42", "tenant:7")
+	c.SetWithTags("user:42:orders", 2, 0, "user:42")
+	c.SetWithTags("user:43:profile", 3, 0, "tenant:7")
+	c.Set("order:1", 4)
+	c.Wait()
+
+	if n := c.InvalidateTag("user:42"); n != 2 {
+		t.Fatalf("expected 2 keys invalidated, got %d", n)
+	}
+	if _, ok := c.Get("user:42:profile"); ok {
+		t.Fatal("expected tagged key removed")
+	}
+	if n := c.InvalidateTag("user:42"); n != 0 {
+		t.Fatalf("expected tag emptied, got %d", n)
+	}
+
+	if n := c.InvalidatePrefix("user:"); n != 1 {
+		t.Fatalf("expected 1 key invalidated, got %d", n)
+	}
+	if n := c.InvalidatePrefix("ord"); n != 1 {
+		t.Fatalf("expected 1 key invalidated, got %d", n)
+	}
+	if n := c.InvalidateTag("tenant:7"); n != 0 {
+		t.Fatalf("expected index emptied, got %d", n)
+	}
+
+	c.SetWithTags("user:1", 1, time.Millisecond, "short")
+	c.Wait()
+	c.SetWithTags("user:1", 2, 0, "long")
+	c.Wait()
+	if n := c.InvalidateTag("short"); n != 0 {
+		t.Fatalf("expected replaced tags removed, got %d", n)
+	}
+	if n := c.InvalidateTag("long"); n != 1 {
+		t.Fatalf("expected new tags indexed, got %d", n)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	store := &FileStore{Dir: t.TempDir()}
+	conf := SnapshotConfig{Name: "snap", Store: store, Interval: time.Hour, MaxKeys: 2}
+
+	c, err := New[string, *user](Config[*user]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.EnableSnapshot(context.Background(), conf); err != nil || n != 0 {
+		t.Fatalf("expected empty restore, got %d %v", n, err)
+	}
+	c.SetWithTags("a", &user{Name: "a"}, time.Hour, "team:1")
+	c.Set("b", &user{Name: "b"})
+	c.Set("c", &user{Name: "c"})
+	c.Wait()
+	c.Get("a")
+	c.Get("b")
+	c.Get("b")
+	c.Close()
+
+	restored, err := New[string, *user](Config[*user]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if n, err := restored.EnableSnapshot(context.Background(), conf); err != nil || n != 2 {
+		t.Fatalf("expected 2 hottest keys restored, got %d %v", n, err)
+	}
+	if u, ok := restored.Get("a"); !ok || u.Name != "a" {
+		t.Fatalf("unexpected value: %v", u)
+	}
+	if ttl, ok := restored.Instance().GetTTL("a"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("expected remaining ttl, got %v", ttl)
+	}
+	if _, ok := restored.Get("c"); ok {
+		t.Fatal("expected cold key not restored")
+	}
+	if n := restored.InvalidateTag("team:1"); n != 1 {
+		t.Fatalf("expected tags restored, got %d", n)
+	}
+
+	conf.MaxAge = time.Nanosecond
+	old, _ := New[string, *user](Config[*user]{})
+	defer old.Close()
+	if n, _ := old.EnableSnapshot(context.Background(), conf); n != 0 {
+		t.Fatalf("expected old snapshot ignored, got %d", n)
+	}
+}
@@ -0,0 +1,303 @@
+package ristretto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ponlv/go-kit/plog"
+)
+
+var logger = plog.NewBizLogger("ristretto")
+
+var (
+	// ErrSnapshotEnabled is returned by EnableSnapshot when it was already called.
+	ErrSnapshotEnabled = errors.New("ristretto: snapshot already enabled")
+	// ErrSnapshotName is returned by EnableSnapshot when neither the snapshot nor the cache has a name.
+	ErrSnapshotName = errors.New("ristretto: snapshot name is required")
+)
+
+// SnapshotStore save and load snapshots by name.
+type SnapshotStore interface {
+	Save(ctx context.Context, name string, data []byte) error
+	// Load return nil data when the snapshot does not exist.
+	Load(ctx context.Context, name string) ([]byte, error)
+}
+
+// SnapshotConfig struct contain options of the snapshots of a cache.
+type SnapshotConfig struct {
+	// Name of the snapshot, default to the name of the cache.
+	Name string
+	// Store is required, e.g. FileStore or redissnapshot.Store to share
+	// snapshots between pods.
+	Store SnapshotStore
+	// Interval between snapshots, default to 5 minutes. A snapshot is also
+	// taken by Close.
+	Interval time.Duration
+	// MaxKeys is the number of hottest keys saved, default to 10000.
+	MaxKeys int
+	// MaxSize is the max size of a snapshot in bytes, bigger snapshots are
+	// truncated when saved and ignored when loaded. Default to 64MB.
+	MaxSize int
+	// MaxAge ignore older snapshots when loaded, default to 1 hour.
+	MaxAge time.Duration
+	// Timeout of the snapshot taken by Close, default to 10 seconds.
+	Timeout time.Duration
+}
+
+type snapshotter struct {
+	conf SnapshotConfig
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type snapshotHeader struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type snapshotEntry[K Key, V any] struct {
+	Key   K        `json:"k"`
+	Value V        `json:"v"`
+	TTL   int64    `json:"ttl,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type snapshot[K Key, V any] struct {
+	snapshotHeader
+	Entries []snapshotEntry[K, V] `json:"entries"`
+}
+
+// EnableSnapshot restore the last snapshot of the cache and save a new one
+// every interval and on Close, it return the number of restored keys.
+// Values must be JSON encodable.
+// EX used: restored, err := users.EnableSnapshot(ctx, ristretto.SnapshotConfig{Store: &ristretto.FileStore{Dir: "/var/cache/app"}})
+func (c *Cache[K, V]) EnableSnapshot(ctx context.Context, conf SnapshotConfig) (int, error) {
+	if conf.Name == "" {
+		conf.Name = c.name
+	}
+	if conf.Name == "" {
+		return 0, ErrSnapshotName
+	}
+	if conf.Store == nil {
+		return 0, errors.New("ristretto: snapshot store is required")
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = 5 * time.Minute
+	}
+	if conf.MaxKeys <= 0 {
+		conf.MaxKeys = 10000
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = 64 << 20
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = time.Hour
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = 10 * time.Second
+	}
+
+	s := &snapshotter{conf: conf, stop: make(chan struct{}), done: make(chan struct{})}
+	c.snapMu.Lock()
+	if c.snap != nil {
+		c.snapMu.Unlock()
+		return 0, ErrSnapshotEnabled
+	}
+	c.snap = s
+	c.snapMu.Unlock()
+
+	restored, err := c.restore(ctx, conf)
+	go c.snapshotLoop(s)
+	return restored, err
+}
+
+// SaveSnapshot save a snapshot now, EnableSnapshot must be called first.
+func (c *Cache[K, V]) SaveSnapshot(ctx context.Context) error {
+	c.snapMu.Lock()
+	s := c.snap
+	c.snapMu.Unlock()
+	if s == nil {
+		return errors.New("ristretto: snapshot is not enabled")
+	}
+	return c.save(ctx, s.conf)
+}
+
+func (c *Cache[K, V]) snapshotLoop(s *snapshotter) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.conf.Timeout)
+			if err := c.save(ctx, s.conf); err != nil {
+				logger.Error().Err(err).Var("snapshot", s.conf.Name).Msg("error when save snapshot")
+			}
+			cancel()
+		}
+	}
+}
+
+// closeSnapshot stop the loop and save the last snapshot.
+func (c *Cache[K, V]) closeSnapshot() {
+	c.snapMu.Lock()
+	s := c.snap
+	c.snapMu.Unlock()
+	if s == nil {
+		return
+	}
+
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.conf.Timeout)
+		defer cancel()
+		if err := c.save(ctx, s.conf); err != nil {
+			logger.Error().Err(err).Var("snapshot", s.conf.Name).Msg("error when save snapshot")
+		}
+	})
+}
+
+// save encode the hottest keys with their remaining ttl, up to MaxKeys
+// keys and MaxSize bytes.
+func (c *Cache[K, V]) save(ctx context.Context, conf SnapshotConfig) error {
+	c.index.mu.Lock()
+	entries := make([]*entry[K, V], 0, len(c.index.entries))
+	for _, e := range c.index.entries {
+		entries = append(entries, e)
+	}
+	c.index.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return atomic.LoadUint32(&entries[i].hits) > atomic.LoadUint32(&entries[j].hits)
+	})
+
+	header, err := json.Marshal(snapshotHeader{Name: conf.Name, CreatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	// the header fields are kept at the top level of the snapshot object
+	var buf bytes.Buffer
+	buf.Write(header[:len(header)-1])
+	buf.WriteString(`,"entries":[`)
+	count := 0
+	for _, e := range entries {
+		if count >= conf.MaxKeys {
+			break
+		}
+		// skip keys rejected, expired or not admitted yet
+		ttl, ok := c.inc.GetTTL(e.key)
+		if !ok {
+			continue
+		}
+
+		data, err := json.Marshal(snapshotEntry[K, V]{Key: e.key, Value: e.value, TTL: int64(ttl), Tags: e.tags})
+		if err != nil {
+			return err
+		}
+		if buf.Len()+len(data)+3 > conf.MaxSize {
+			break
+		}
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(data)
+		count++
+	}
+	buf.WriteString("]}")
+
+	return conf.Store.Save(ctx, conf.Name, buf.Bytes())
+}
+
+// restore set the keys of the last snapshot with their remaining ttl.
+func (c *Cache[K, V]) restore(ctx context.Context, conf SnapshotConfig) (int, error) {
+	data, err := conf.Store.Load(ctx, conf.Name)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	if len(data) > conf.MaxSize {
+		logger.Warn().Var("snapshot", conf.Name).Var("size", len(data)).Msg("ignore snapshot bigger than max size")
+		return 0, nil
+	}
+
+	var snap snapshot[K, V]
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return 0, err
+	}
+	age := time.Since(snap.CreatedAt)
+	if age > conf.MaxAge {
+		logger.Warn().Var("snapshot", conf.Name).Var("age", age.String()).Msg("ignore snapshot older than max age")
+		return 0, nil
+	}
+
+	restored := 0
+	for _, e := range snap.Entries {
+		ttl := time.Duration(e.TTL)
+		if ttl > 0 {
+			if ttl -= age; ttl <= 0 {
+				continue
+			}
+		}
+		if c.set(&entry[K, V]{key: e.Key, value: e.Value, tags: e.Tags}, ttl) {
+			restored++
+		}
+	}
+	c.inc.Wait()
+	return restored, nil
+}
+
+// FileStore save snapshots as files of a directory.
+type FileStore struct {
+	Dir string
+}
+
+// Save write the snapshot to a temporary file and rename it, so readers
+// never see a partial snapshot.
+func (s *FileStore) Save(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(name))
+}
+
+// Load read the snapshot file, nil if it does not exist.
+func (s *FileStore) Load(ctx context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *FileStore) path(name string) string {
+	return filepath.Join(s.Dir, name+".snapshot.json")
+}
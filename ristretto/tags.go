@@ -0,0 +1,167 @@
+package ristretto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PrefixSeparator split keys into the segments indexed for InvalidatePrefix,
+// e.g. "user:42:profile" is indexed under "user:" and "user:42:".
+const PrefixSeparator = ":"
+
+// index map tags and key prefixes to the keys of a Cache. Entries are added
+// when they are set and removed from the ristretto OnExit callback, which
+// covers rejections, evictions, expirations and deletes. Callbacks compare the
+// entry pointer so a late callback of an old value does not remove a newer one.
+type index[K Key, V any] struct {
+	mu       sync.Mutex
+	entries  map[K]*entry[K, V]
+	tags     map[string]map[K]struct{}
+	prefixes map[string]map[K]struct{}
+}
+
+func newIndex[K Key, V any]() *index[K, V] {
+	return &index[K, V]{
+		entries:  make(map[K]*entry[K, V]),
+		tags:     make(map[string]map[K]struct{}),
+		prefixes: make(map[string]map[K]struct{}),
+	}
+}
+
+// add index the entry, replacing the previous entry of its key.
+func (idx *index[K, V]) add(e *entry[K, V]) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if prev, ok := idx.entries[e.key]; ok {
+		idx.unlink(prev)
+	}
+	idx.entries[e.key] = e
+	for _, tag := range e.tags {
+		link(idx.tags, tag, e.key)
+	}
+	for _, prefix := range prefixesOf(keyString(e.key)) {
+		link(idx.prefixes, prefix, e.key)
+	}
+}
+
+// remove drop the entry if it is still the indexed one of its key.
+func (idx *index[K, V]) remove(e *entry[K, V]) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if cur, ok := idx.entries[e.key]; ok && cur == e {
+		idx.unlink(e)
+	}
+}
+
+// delete drop the entry of the key.
+func (idx *index[K, V]) delete(key K) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if cur, ok := idx.entries[key]; ok {
+		idx.unlink(cur)
+	}
+}
+
+// takeTags drop and return the keys having any of the tags.
+func (idx *index[K, V]) takeTags(tags []string) []K {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	keys := make([]K, 0)
+	for _, tag := range tags {
+		for key := range idx.tags[tag] {
+			keys = append(keys, key)
+			idx.unlink(idx.entries[key])
+		}
+	}
+	return keys
+}
+
+// takePrefix drop and return the keys starting with prefix. Prefixes ending
+// with PrefixSeparator are looked up in the index, others scan every key.
+func (idx *index[K, V]) takePrefix(prefix string) []K {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	keys := make([]K, 0)
+	if strings.HasSuffix(prefix, PrefixSeparator) {
+		for key := range idx.prefixes[prefix] {
+			keys = append(keys, key)
+		}
+	} else {
+		for key := range idx.entries {
+			if strings.HasPrefix(keyString(key), prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		idx.unlink(idx.entries[key])
+	}
+	return keys
+}
+
+func (idx *index[K, V]) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.entries = make(map[K]*entry[K, V])
+	idx.tags = make(map[string]map[K]struct{})
+	idx.prefixes = make(map[string]map[K]struct{})
+}
+
+// unlink remove the entry from every map, the lock must be held.
+func (idx *index[K, V]) unlink(e *entry[K, V]) {
+	delete(idx.entries, e.key)
+	for _, tag := range e.tags {
+		unlink(idx.tags, tag, e.key)
+	}
+	for _, prefix := range prefixesOf(keyString(e.key)) {
+		unlink(idx.prefixes, prefix, e.key)
+	}
+}
+
+func link[K Key](groups map[string]map[K]struct{}, name string, key K) {
+	keys, ok := groups[name]
+	if !ok {
+		keys = make(map[K]struct{})
+		groups[name] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func unlink[K Key](groups map[string]map[K]struct{}, name string, key K) {
+	if keys, ok := groups[name]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(groups, name)
+		}
+	}
+}
+
+// prefixesOf return the prefixes of key ending with PrefixSeparator.
+func prefixesOf(key string) []string {
+	var prefixes []string
+	for i := strings.Index(key, PrefixSeparator); i >= 0; {
+		end := i + len(PrefixSeparator)
+		prefixes = append(prefixes, key[:end])
+		next := strings.Index(key[end:], PrefixSeparator)
+		if next < 0 {
+			break
+		}
+		i = end + next
+	}
+	return prefixes
+}
+
+func keyString[K Key](key K) string {
+	if s, ok := interface{}(key).(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}